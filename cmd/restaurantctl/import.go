@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/importer"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runImport imports a file like POST /imports does. It exits with 1 when rows have errors, nothing is written
+// then.
+func runImport(ctx context.Context, args []string) int {
+	flags := newFlags("import")
+	dryRun := flags.Bool("dry-run", false, "check the file and report what would change without writing")
+	format := flags.String("format", "", "csv or json, taken from the file extension when not given")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	var batch importer.Batch
+	switch *format {
+	case "csv":
+		batch, err = importer.ParseCSV(file)
+	case "json":
+		batch, err = importer.ParseJSON(file)
+	default:
+		fmt.Fprintln(os.Stderr, "format must be csv or json")
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s cannot be read: %s\n", path, err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	result, err := importer.Run(ctx, batch, *dryRun, "restaurantctl")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "menu could not be imported: %s\n", err)
+		return 1
+	}
+
+	printJSON(result)
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand of restaurantctl, it returns the exit status.
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) int
+}
+
+var commands map[string]command
+
+// the commands are set up in init because their flag sets print the usage lines of commands
+func init() {
+	commands = map[string]command{
+		"create-admin": {
+			usage: "create-admin -email -first-name -second-name -phone [-password]   create a user with the ADMIN role",
+			run:   runCreateAdmin,
+		},
+		"reset-password": {
+			usage: "reset-password -email [-password]   set a new password for a user",
+			run:   runResetPassword,
+		},
+		"seed": {
+			usage: "seed [-tables 10] [-guests 4]   add demo menus, foods and tables",
+			run:   runSeed,
+		},
+		"import": {
+			usage: "import [-dry-run] [-format csv|json] <file>   import menus, categories and foods",
+			run:   runImport,
+		},
+		"recompute-totals": {
+			usage: "recompute-totals [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-all]   reprice the invoices of a period",
+			run:   runRecomputeTotals,
+		},
+		"migrate": {
+			usage: "migrate status | up [-to N] | down -to N   apply or undo schema migrations",
+			run:   runMigrate,
+		},
+		"maintenance": {
+			usage: "maintenance <task>... | all   run indexes or publish-due",
+			run:   runMaintenance,
+		},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	os.Exit(cmd.run(context.Background(), os.Args[2:]))
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = "  restaurantctl " + commands[name].usage
+	}
+	fmt.Fprintf(os.Stderr, "usage:\n%s\n\nthe database is MONGODB_URL and MONGODB_DATABASE, like for the server\n", strings.Join(lines, "\n"))
+}
+
+// newFlags makes a flag set for a command that reports its own usage line.
+func newFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: restaurantctl %s\n", commands[name].usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maintenanceTasks are what the server does on start or in the background, runnable on their own.
+var maintenanceTasks = map[string]func(ctx context.Context) error{
+	"indexes": func(ctx context.Context) error {
+		return database.EnsureIndexes(database.Client)
+	},
+	"publish-due": func(ctx context.Context) error {
+		controllers.PublishDue(ctx)
+		return nil
+	},
+}
+
+// runRecomputeTotals reprices the invoices created between the days -from and -to in the restaurant timezone.
+func runRecomputeTotals(ctx context.Context, args []string) int {
+	flags := newFlags("recompute-totals")
+	from := flags.String("from", "", "first day as YYYY-MM-DD, today when not given")
+	to := flags.String("to", "", "last day as YYYY-MM-DD, the first day when not given")
+	all := flags.Bool("all", false, "also reprice paid invoices, not only open ones and those without totals")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	location, err := helpers.RestaurantLocation()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "RESTAURANT_TIMEZONE is not a valid timezone")
+		return 1
+	}
+
+	first, err := parseDay(*from, time.Now().In(location), location)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "from: %s\n", err)
+		return 2
+	}
+	last, err := parseDay(*to, first, location)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "to: %s\n", err)
+		return 2
+	}
+	if last.Before(first) {
+		fmt.Fprintln(os.Stderr, "to must not be before from")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	repriced, err := controllers.RecomputeInvoiceTotals(ctx, first, last.AddDate(0, 0, 1), *all)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "totals were recomputed for %d invoices before failing: %s\n", repriced, err)
+		return 1
+	}
+
+	fmt.Printf("totals were recomputed for %d invoices\n", repriced)
+	return 0
+}
+
+// runMaintenance runs the named tasks in order, or every task with "all".
+func runMaintenance(ctx context.Context, args []string) int {
+	flags := newFlags("maintenance")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	names := flags.Args()
+	if len(names) == 1 && names[0] == "all" {
+		names = maintenanceTaskNames()
+	}
+	if len(names) == 0 {
+		flags.Usage()
+		return 2
+	}
+	for _, name := range names {
+		if _, ok := maintenanceTasks[name]; !ok {
+			fmt.Fprintf(os.Stderr, "task %s must be one of %s or all\n", name, strings.Join(maintenanceTaskNames(), ", "))
+			return 2
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	for _, name := range names {
+		if err := maintenanceTasks[name](ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed: %s\n", name, err)
+			return 1
+		}
+		fmt.Printf("%s done\n", name)
+	}
+	return 0
+}
+
+func maintenanceTaskNames() []string {
+	names := make([]string, 0, len(maintenanceTasks))
+	for name := range maintenanceTasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseDay reads a YYYY-MM-DD day as its midnight in location, an empty value is the day of fallback.
+func parseDay(value string, fallback time.Time, location *time.Location) (time.Time, error) {
+	if value == "" {
+		year, month, day := fallback.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, location), nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		return day, fmt.Errorf("%s must be a day like 2006-01-02", value)
+	}
+	return day, nil
+}
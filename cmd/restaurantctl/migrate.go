@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/migrations"
+	"os"
+	"time"
+)
+
+// runMigrate shows, applies or undoes the schema migrations. The server applies them on start too, unless
+// MIGRATE_ON_START is false.
+func runMigrate(ctx context.Context, args []string) int {
+	flags := newFlags("migrate")
+	to := flags.Int("to", -1, "version to migrate to, the latest for up")
+	if len(args) == 0 {
+		flags.Usage()
+		return 2
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+
+	var done []migrations.Migration
+	var err error
+	switch args[0] {
+	case "status":
+		statuses, err := migrations.List(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrations cannot be listed: %s\n", err)
+			return 1
+		}
+		printJSON(statuses)
+		return 0
+	case "up":
+		target := *to
+		if target < 0 {
+			target = 0
+		}
+		done, err = migrations.Up(ctx, target)
+	case "down":
+		if *to < 0 {
+			fmt.Fprintln(os.Stderr, "down needs -to, 0 undoes every migration")
+			return 2
+		}
+		done, err = migrations.Down(ctx, *to)
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	for _, migration := range done {
+		fmt.Printf("%s %d %s\n", args[0], migration.Version, migration.Name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(done) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+	return 0
+}
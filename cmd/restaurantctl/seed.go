@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/importer"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"strings"
+	"time"
+)
+
+var tableCollection = database.OpenCollection(database.Client, "table")
+
+// demoMenu is imported like any other file, its skus start with demo- so it does not collide with real ones.
+const demoMenu = `{
+	"menus": [
+		{"sku": "demo-lunch", "name": "Lunch", "category": "Main"},
+		{"sku": "demo-drinks", "name": "Drinks", "category": "Bar"}
+	],
+	"categories": [
+		{"sku": "demo-starters", "name": "Starters", "display_order": 1, "station": "KITCHEN"},
+		{"sku": "demo-mains", "name": "Mains", "display_order": 2, "station": "KITCHEN"},
+		{"sku": "demo-desserts", "name": "Desserts", "display_order": 3, "station": "KITCHEN"},
+		{"sku": "demo-beverages", "name": "Beverages", "display_order": 4, "station": "BAR"}
+	],
+	"foods": [
+		{"sku": "demo-tomato-soup", "name": "Tomato soup", "price": 5.5, "menu_sku": "demo-lunch", "category_sku": "demo-starters", "allergens": ["CELERY"], "dietary_tags": ["VEGAN"]},
+		{"sku": "demo-bruschetta", "name": "Bruschetta", "price": 6, "menu_sku": "demo-lunch", "category_sku": "demo-starters", "allergens": ["GLUTEN"], "dietary_tags": ["VEGETARIAN"]},
+		{"sku": "demo-burger", "name": "Beef burger", "price": 13.9, "menu_sku": "demo-lunch", "category_sku": "demo-mains", "allergens": ["GLUTEN", "MILK", "MUSTARD", "SESAME"]},
+		{"sku": "demo-salmon", "name": "Grilled salmon", "price": 17.5, "menu_sku": "demo-lunch", "category_sku": "demo-mains", "allergens": ["FISH"], "dietary_tags": ["GLUTEN_FREE"]},
+		{"sku": "demo-risotto", "name": "Mushroom risotto", "price": 12, "menu_sku": "demo-lunch", "category_sku": "demo-mains", "allergens": ["MILK"], "dietary_tags": ["VEGETARIAN", "GLUTEN_FREE"]},
+		{"sku": "demo-tiramisu", "name": "Tiramisu", "price": 6.5, "menu_sku": "demo-lunch", "category_sku": "demo-desserts", "allergens": ["EGGS", "MILK", "GLUTEN"]},
+		{"sku": "demo-lemonade", "name": "Lemonade", "price": 3.5, "menu_sku": "demo-drinks", "category_sku": "demo-beverages", "dietary_tags": ["VEGAN"]},
+		{"sku": "demo-espresso", "name": "Espresso", "price": 2.2, "menu_sku": "demo-drinks", "category_sku": "demo-beverages", "dietary_tags": ["VEGAN"]}
+	]
+}`
+
+// runSeed imports the demo menu and adds the tables that do not exist yet, so it can be run again safely.
+func runSeed(ctx context.Context, args []string) int {
+	flags := newFlags("seed")
+	tables := flags.Int("tables", 10, "number of tables, numbered from 1")
+	guests := flags.Int("guests", 4, "seats of each new table")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	batch, err := importer.ParseJSON(strings.NewReader(demoMenu))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "demo menu cannot be read: %s\n", err)
+		return 1
+	}
+	result, err := importer.Run(ctx, batch, false, "restaurantctl")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "demo menu was not imported: %s\n", err)
+		return 1
+	}
+	if len(result.Errors) > 0 {
+		printJSON(result)
+		return 1
+	}
+
+	created, err := seedTables(ctx, *tables, *guests)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tables were not created: %s\n", err)
+		return 1
+	}
+
+	printJSON(struct {
+		Import importer.Result `json:"import"`
+		Tables int             `json:"tables_created"`
+	}{result, created})
+	return 0
+}
+
+func seedTables(ctx context.Context, count int, guests int) (int, error) {
+	var existing []models.Table
+
+	cursor, err := tableCollection.Find(ctx, bson.M{"table_number": bson.M{"$lte": count}})
+	if err != nil {
+		return 0, err
+	}
+	if err = cursor.All(ctx, &existing); err != nil {
+		return 0, err
+	}
+	numbers := map[int]bool{}
+	for _, table := range existing {
+		if table.TableNumber != nil {
+			numbers[*table.TableNumber] = true
+		}
+	}
+
+	created := 0
+	for number := 1; number <= count; number++ {
+		if numbers[number] {
+			continue
+		}
+
+		tableNumber, numberOfGuests := number, guests
+		table := models.Table{TableNumber: &tableNumber, NumberOfGuests: &numberOfGuests}
+		table.ID = primitive.NewObjectID()
+		table.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+		table.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+		table.TableId = table.ID.Hex()
+
+		if _, err := tableCollection.InsertOne(ctx, table); err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
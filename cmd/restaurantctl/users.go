@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"os"
+	"strings"
+)
+
+var validate = validator.New()
+
+// runCreateAdmin creates a user with the ADMIN role, which sign up cannot give. Without -password the
+// password is read from the first line of stdin so it does not end up in the shell history.
+func runCreateAdmin(ctx context.Context, args []string) int {
+	flags := newFlags("create-admin")
+	email := flags.String("email", "", "email to log in with")
+	firstName := flags.String("first-name", "", "first name")
+	secondName := flags.String("second-name", "", "second name")
+	phone := flags.String("phone", "", "phone number")
+	passwordFlag := flags.String("password", "", "password, read from stdin when not given")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	password, err := readPassword(*passwordFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	user := models.User{
+		FirstName:  firstName,
+		SecondName: secondName,
+		Password:   &password,
+		Email:      email,
+		Phone:      phone,
+	}
+	if err := validate.Struct(user); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	result, err := controllers.CreateUser(ctx, user, models.RoleAdmin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "admin user was not created: %s\n", err)
+		return 1
+	}
+
+	printJSON(result)
+	return 0
+}
+
+func runResetPassword(ctx context.Context, args []string) int {
+	flags := newFlags("reset-password")
+	email := flags.String("email", "", "email of the user")
+	passwordFlag := flags.String("password", "", "new password, read from stdin when not given")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *email == "" {
+		flags.Usage()
+		return 2
+	}
+
+	password, err := readPassword(*passwordFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = controllers.ResetPassword(ctx, *email, password)
+	if errors.Is(err, controllers.ErrUserNotFound) {
+		fmt.Fprintf(os.Stderr, "no user has the email %s\n", *email)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "password was not reset: %s\n", err)
+		return 1
+	}
+
+	fmt.Printf("password of %s was reset\n", *email)
+	return 0
+}
+
+func readPassword(password string) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if line = strings.TrimRight(line, "\r\n"); line == "" {
+		if err != nil {
+			return "", fmt.Errorf("password cannot be read: %s", err)
+		}
+		return "", fmt.Errorf("password is required")
+	}
+	return line, nil
+}
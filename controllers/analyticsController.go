@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/reporting"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GetProductMix reports units, revenue, cost and margin per food and category for the days ?from= to ?to=,
+// narrowed by ?menu_id= and ?category_id=. With ?format=csv the foods are exported, or the categories with
+// ?by=category.
+func GetProductMix(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	location, err := helpers.RestaurantLocation()
+	if err != nil {
+		http.Error(w, "RESTAURANT_TIMEZONE is not a valid timezone", http.StatusInternalServerError)
+		return
+	}
+
+	filter, err := productMixFilter(r, location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mix, err := reporting.ProductMixReport(ctx, filter)
+	if err != nil {
+		msg := "error occurred while computing the product mix"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if wantsCSV(r) {
+		name := "product-mix"
+		write := reporting.WriteFoodSalesCSV
+		if r.FormValue("by") == "category" {
+			name = "category-mix"
+			write = reporting.WriteCategorySalesCSV
+		}
+		writeCSVHeader(w, name, filter, location)
+		if err := write(w, mix); err != nil {
+			log.Printf("product mix export failed: %s", err)
+		}
+		return
+	}
+
+	mixJSON, err := json.Marshal(mix)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(mixJSON)
+}
+
+// GetSalesHeatmap reports the sales by weekday and hour for the days ?from= to ?to=, with the same filters
+// and export as GetProductMix.
+func GetSalesHeatmap(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	location, err := helpers.RestaurantLocation()
+	if err != nil {
+		http.Error(w, "RESTAURANT_TIMEZONE is not a valid timezone", http.StatusInternalServerError)
+		return
+	}
+
+	filter, err := productMixFilter(r, location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	heatmap, err := reporting.SalesHeatmap(ctx, filter, location)
+	if err != nil {
+		msg := "error occurred while computing the sales heatmap"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if wantsCSV(r) {
+		writeCSVHeader(w, "sales-heatmap", filter, location)
+		if err := reporting.WriteHeatmapCSV(w, heatmap); err != nil {
+			log.Printf("sales heatmap export failed: %s", err)
+		}
+		return
+	}
+
+	heatmapJSON, err := json.Marshal(heatmap)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(heatmapJSON)
+}
+
+func productMixFilter(r *http.Request, location *time.Location) (reporting.ProductMixFilter, error) {
+	var filter reporting.ProductMixFilter
+
+	first, last, err := reportDays(r, location)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Start = first
+	filter.End = last.AddDate(0, 0, 1)
+	filter.MenuId = r.FormValue("menu_id")
+	filter.CategoryId = r.FormValue("category_id")
+	return filter, nil
+}
+
+func wantsCSV(r *http.Request) bool {
+	if format := r.FormValue("format"); format != "" {
+		return strings.EqualFold(format, "csv")
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+func writeCSVHeader(w http.ResponseWriter, name string, filter reporting.ProductMixFilter, location *time.Location) {
+	filename := fmt.Sprintf("%s-%s-%s.csv", name, filter.Start.In(location).Format("2006-01-02"), filter.End.In(location).AddDate(0, 0, -1).Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+}
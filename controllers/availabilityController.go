@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"log"
+	"net/http"
+	"time"
+)
+
+// EightySixFood marks a food as sold out until the kitchen brings it back.
+func EightySixFood(w http.ResponseWriter, r *http.Request) {
+	setFoodAvailable(w, r, false)
+}
+
+func RestoreFood(w http.ResponseWriter, r *http.Request) {
+	setFoodAvailable(w, r, true)
+}
+
+func setFoodAvailable(w http.ResponseWriter, r *http.Request, available bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	foodId := vars["food_id"]
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	result, err := foodCollection.UpdateOne(
+		ctx,
+		bson.M{"food_id": foodId},
+		bson.D{
+			{"$set", bson.D{
+				{"available", available},
+				{"updated_at", updatedAt},
+			}},
+		},
+	)
+	if err != nil {
+		msg := "Food availability update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "food was not found", http.StatusNotFound)
+		return
+	}
+
+	writeFoodAvailability(ctx, w, foodId)
+}
+
+// SetRemainingPortions starts or stops counting the portions of a food, a null count stops counting.
+func SetRemainingPortions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var request struct {
+		RemainingPortions *int `json:"remaining_portions" validate:"omitempty,gte=0"`
+	}
+
+	vars := mux.Vars(r)
+	foodId := vars["food_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	result, err := foodCollection.UpdateOne(
+		ctx,
+		bson.M{"food_id": foodId},
+		bson.D{
+			{"$set", bson.D{
+				{"remaining_portions", request.RemainingPortions},
+				{"updated_at", updatedAt},
+			}},
+		},
+	)
+	if err != nil {
+		msg := "Food portions update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "food was not found", http.StatusNotFound)
+		return
+	}
+
+	writeFoodAvailability(ctx, w, foodId)
+}
+
+func GetFoodAvailability(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var foods []models.Food
+
+	result, err := foodCollection.Find(ctx, bson.M{})
+	if err != nil {
+		msg := "error occurred while listing food availability"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if err = result.All(ctx, &foods); err != nil {
+		log.Fatal(err)
+	}
+
+	availability := make([]helpers.FoodAvailabilityEvent, 0, len(foods))
+	for _, food := range foods {
+		availability = append(availability, helpers.FoodAvailability(food))
+	}
+
+	availabilityJSON, err := json.Marshal(availability)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(availabilityJSON)
+}
+
+// StreamFoodAvailability sends availability changes as server-sent events until the client disconnects.
+// Clients load GET /foods/availability first and apply the events on top of it.
+func StreamFoodAvailability(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events := helpers.FoodAvailabilityEvents.Subscribe()
+	defer helpers.FoodAvailabilityEvents.Unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// comments keep proxies from closing an idle stream
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case event := <-events:
+			eventJSON, err := json.Marshal(event)
+			if err != nil {
+				log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+			}
+			fmt.Fprintf(w, "event: availability\ndata: %s\n\n", eventJSON)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeFoodAvailability(ctx context.Context, w http.ResponseWriter, foodId string) {
+	event, err := publishFoodAvailability(ctx, foodId)
+	if err != nil {
+		msg := "error occurred while fetching the food availability"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(eventJSON)
+}
+
+// publishFoodAvailability tells the connected devices about the current availability of the food.
+func publishFoodAvailability(ctx context.Context, foodId string) (helpers.FoodAvailabilityEvent, error) {
+	var food models.Food
+
+	if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+		return helpers.FoodAvailabilityEvent{}, err
+	}
+
+	event := helpers.FoodAvailability(food)
+	helpers.FoodAvailabilityEvents.Publish(event)
+	return event, nil
+}
+
+// reservePortions takes the ordered portions off the foods that count them. The filter only matches while
+// enough portions are left, so concurrent orders cannot oversell. On failure the portions taken so far are
+// given back.
+func reservePortions(ctx context.Context, orderItems []OrderItemRequest, foods []models.Food) error {
+	for i, orderItem := range orderItems {
+		if foods[i].RemainingPortions == nil {
+			continue
+		}
+
+		result, err := foodCollection.UpdateOne(
+			ctx,
+			bson.M{"food_id": foods[i].FoodId, "remaining_portions": bson.M{"$gte": orderItem.Count}},
+			bson.D{{"$inc", bson.D{{"remaining_portions", -orderItem.Count}}}},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			err = fmt.Errorf("%s sold out while ordering", *foods[i].Name)
+		}
+		if err != nil {
+			releasePortions(ctx, orderItems[:i], foods[:i])
+			return err
+		}
+		publishFoodAvailability(ctx, foods[i].FoodId)
+	}
+	return nil
+}
+
+func releasePortions(ctx context.Context, orderItems []OrderItemRequest, foods []models.Food) {
+	for i, orderItem := range orderItems {
+		if foods[i].RemainingPortions == nil {
+			continue
+		}
+		releaseFoodPortions(ctx, foods[i].FoodId, orderItem.Count)
+	}
+}
+
+// releaseFoodPortions gives portions back, unless the food stopped counting them in the meantime.
+func releaseFoodPortions(ctx context.Context, foodId string, count int) {
+	_, err := foodCollection.UpdateOne(
+		ctx,
+		bson.M{"food_id": foodId, "remaining_portions": bson.M{"$ne": nil}},
+		bson.D{{"$inc", bson.D{{"remaining_portions", count}}}},
+	)
+	if err != nil {
+		log.Printf("portions of food %s were not given back: %s", foodId, err)
+		return
+	}
+	publishFoodAvailability(ctx, foodId)
+}
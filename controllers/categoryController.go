@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+var categoryCollection = database.OpenCollection(database.Client, "category")
+
+// GetCategories lists the categories in display order, only the subcategories of ?parent_id= when given.
+// ?parent_id= with an empty value lists the top level categories.
+func GetCategories(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if _, ok := r.URL.Query()["parent_id"]; ok {
+		if parentId := r.FormValue("parent_id"); parentId != "" {
+			filter["parent_id"] = parentId
+		} else {
+			filter["parent_id"] = nil
+		}
+	}
+
+	result, err := categoryCollection.Find(ctx, filter)
+	if err != nil {
+		msg := "error occurred while listing the categories"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	allCategories := []models.Category{}
+	if err = result.All(ctx, &allCategories); err != nil {
+		log.Fatal(err)
+	}
+
+	helpers.SortCategories(allCategories)
+	locales := helpers.RequestedLocales(r)
+	for i := range allCategories {
+		helpers.LocalizeCategory(&allCategories[i], locales)
+	}
+
+	allCategoriesJSON, err := json.Marshal(allCategories)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(allCategoriesJSON)
+}
+
+func GetCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	categoryId := vars["category_id"]
+
+	var category models.Category
+	if err := categoryCollection.FindOne(ctx, bson.M{"category_id": categoryId}).Decode(&category); err != nil {
+		http.Error(w, "category was not found", http.StatusNotFound)
+		return
+	}
+
+	locale := helpers.LocalizeCategory(&category, helpers.RequestedLocales(r))
+	w.Header().Set("Content-Language", locale)
+
+	categoryJSON, err := json.Marshal(category)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(categoryJSON)
+}
+
+func CreateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var category models.Category
+
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if validationErr := validate.Struct(category); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if category.ParentId != nil && *category.ParentId == "" {
+		category.ParentId = nil
+	}
+	if category.ParentId != nil {
+		if count, err := categoryCollection.CountDocuments(ctx, bson.M{"category_id": category.ParentId}); err != nil || count == 0 {
+			http.Error(w, "parent category was not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if status, err := checkCategoryName(ctx, *category.Name, category.ParentId, ""); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	category.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	category.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	category.ID = primitive.NewObjectID()
+	category.CategoryId = category.ID.Hex()
+
+	result, insertErr := categoryCollection.InsertOne(ctx, category)
+	if insertErr != nil {
+		msg := fmt.Sprintf("Category was not created")
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+// UpdateCategory changes the sent fields of a category. An empty parent_id moves the category to the top level.
+func UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var category struct {
+		Name         *string                               `json:"name" validate:"omitempty,min=2,max=100"`
+		ParentId     *string                               `json:"parent_id"`
+		DisplayOrder *int                                  `json:"display_order"`
+		Station      *string                               `json:"station" validate:"omitempty,max=50"`
+		Translations map[string]models.CategoryTranslation `json:"translations" validate:"dive,keys,bcp47_language_tag,endkeys"`
+	}
+	var current models.Category
+
+	vars := mux.Vars(r)
+	categoryId := vars["category_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if validationErr := validate.Struct(category); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := categoryCollection.FindOne(ctx, bson.M{"category_id": categoryId}).Decode(&current); err != nil {
+		http.Error(w, "category was not found", http.StatusNotFound)
+		return
+	}
+
+	var updateObj primitive.D
+
+	parentId := current.ParentId
+	if category.ParentId != nil {
+		parentId = category.ParentId
+		if *parentId == "" {
+			parentId = nil
+		}
+		if parentId != nil {
+			categories, err := loadCategories(ctx)
+			if err != nil {
+				msg := "error occurred while checking the parent category"
+				http.Error(w, msg, http.StatusInternalServerError)
+				return
+			}
+			if _, ok := categories[*parentId]; !ok {
+				http.Error(w, "parent category was not found", http.StatusBadRequest)
+				return
+			}
+			for _, ancestor := range helpers.CategoryPath(categories, *parentId) {
+				if ancestor.CategoryId == categoryId {
+					http.Error(w, "a category cannot be moved below itself", http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		updateObj = append(updateObj, bson.E{"parent_id", parentId})
+	}
+
+	if category.Name != nil || category.ParentId != nil {
+		name := *current.Name
+		if category.Name != nil {
+			name = *category.Name
+			updateObj = append(updateObj, bson.E{"name", category.Name})
+		}
+		if status, err := checkCategoryName(ctx, name, parentId, categoryId); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+
+	if category.DisplayOrder != nil {
+		updateObj = append(updateObj, bson.E{"display_order", category.DisplayOrder})
+	}
+
+	if category.Station != nil {
+		updateObj = append(updateObj, bson.E{"station", category.Station})
+	}
+
+	if category.Translations != nil {
+		updateObj = append(updateObj, bson.E{"translations", category.Translations})
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+	result, err := categoryCollection.UpdateOne(
+		ctx,
+		bson.M{"category_id": categoryId},
+		bson.D{
+			{"$set", updateObj},
+		},
+	)
+	if err != nil {
+		msg := "Category update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+// DeleteCategory removes a category that has neither subcategories nor foods.
+func DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	categoryId := vars["category_id"]
+
+	children, err := categoryCollection.CountDocuments(ctx, bson.M{"parent_id": categoryId})
+	var foods int64
+	if err == nil {
+		foods, err = foodCollection.CountDocuments(ctx, bson.M{"category_id": categoryId})
+	}
+	if err != nil {
+		msg := "error occurred while checking the category"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if children > 0 {
+		http.Error(w, "category has subcategories, move or delete them first", http.StatusConflict)
+		return
+	}
+	if foods > 0 {
+		http.Error(w, "category has foods, move them to another category first", http.StatusConflict)
+		return
+	}
+
+	result, err := categoryCollection.DeleteOne(ctx, bson.M{"category_id": categoryId})
+	if err != nil {
+		msg := "Category was not deleted"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if result.DeletedCount == 0 {
+		http.Error(w, "category was not found", http.StatusNotFound)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+// checkCategoryName keeps sibling categories from sharing a name, however it is capitalized.
+func checkCategoryName(ctx context.Context, name string, parentId *string, categoryId string) (int, error) {
+	filter := bson.M{"name": name, "parent_id": parentId, "category_id": bson.M{"$ne": categoryId}}
+
+	count, err := categoryCollection.CountDocuments(ctx, filter, options.Count().SetCollation(&options.Collation{Locale: "en", Strength: 2}))
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("error occurred while checking the category name")
+	}
+	if count > 0 {
+		return http.StatusConflict, fmt.Errorf("category %s already exists there", name)
+	}
+	return http.StatusOK, nil
+}
+
+// checkCategory makes sure a food is put into a category that exists.
+func checkCategory(ctx context.Context, categoryId *string) error {
+	if categoryId == nil || *categoryId == "" {
+		return nil
+	}
+	count, err := categoryCollection.CountDocuments(ctx, bson.M{"category_id": categoryId})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("category %s was not found", *categoryId)
+	}
+	return nil
+}
+
+// loadCategories reads all categories by id, there are few enough to walk the hierarchy in memory.
+func loadCategories(ctx context.Context) (map[string]models.Category, error) {
+	var categories []models.Category
+
+	result, err := categoryCollection.Find(ctx, bson.M{})
+	if err == nil {
+		err = result.All(ctx, &categories)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[string]models.Category, len(categories))
+	for _, category := range categories {
+		byId[category.CategoryId] = category
+	}
+	return byId, nil
+}
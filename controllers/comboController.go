@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ComboSelection is a food chosen for a slot of an ordered combo.
+type ComboSelection struct {
+	SlotId    string                    `json:"slot_id" validate:"required"`
+	FoodId    string                    `json:"food_id" validate:"required"`
+	Portion   *string                   `json:"portion"`
+	Modifiers []models.SelectedModifier `json:"modifiers" validate:"dive"`
+	Notes     []models.Note             `json:"notes"`
+}
+
+var comboCollection = database.OpenCollection(database.Client, "combo")
+
+// GetCombos lists the combos in display order, only those of ?menu_id= when given.
+func GetCombos(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if menuId := r.FormValue("menu_id"); menuId != "" {
+		filter["menu_id"] = menuId
+	}
+
+	result, err := comboCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{"display_order", 1}, {"name", 1}}))
+	if err != nil {
+		msg := "error occurred while listing the combos"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	allCombos := []models.Combo{}
+	if err = result.All(ctx, &allCombos); err != nil {
+		log.Fatal(err)
+	}
+
+	allCombosJSON, err := json.Marshal(allCombos)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(allCombosJSON)
+}
+
+func GetCombo(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	comboId := vars["combo_id"]
+
+	var combo models.Combo
+	if err := comboCollection.FindOne(ctx, bson.M{"combo_id": comboId}).Decode(&combo); err != nil {
+		http.Error(w, "combo was not found", http.StatusNotFound)
+		return
+	}
+
+	comboJSON, err := json.Marshal(combo)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(comboJSON)
+}
+
+func CreateCombo(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var combo models.Combo
+
+	if err := json.NewDecoder(r.Body).Decode(&combo); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defaultSlotChoices(combo.Slots)
+	if validationErr := validate.Struct(combo); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := checkComboSlots(ctx, combo.Slots); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if combo.MenuId != nil {
+		if count, err := menuCollection.CountDocuments(ctx, bson.M{"menu_id": combo.MenuId}); err != nil || count == 0 {
+			http.Error(w, "menu was not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := checkCategory(ctx, combo.CategoryId); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	combo.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	combo.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	combo.ID = primitive.NewObjectID()
+	combo.ComboId = combo.ID.Hex()
+	var num = toFixed(*combo.Price, 2)
+	combo.Price = &num
+
+	result, insertErr := comboCollection.InsertOne(ctx, combo)
+	if insertErr != nil {
+		msg := fmt.Sprintf("Combo was not created")
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+// UpdateCombo changes the sent fields of a combo. Slots replace all slots, ordered combos keep what was chosen.
+func UpdateCombo(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var request struct {
+		models.Combo
+		DisplayOrder *int `json:"display_order"`
+	}
+
+	vars := mux.Vars(r)
+	comboId := vars["combo_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	combo := request.Combo
+
+	var updateObj primitive.D
+
+	if combo.Name != nil {
+		if validationErr := validate.StructPartial(combo, "Name"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"name", combo.Name})
+	}
+
+	if combo.Description != nil {
+		if validationErr := validate.StructPartial(combo, "Description"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"description", combo.Description})
+	}
+
+	if combo.Price != nil {
+		if validationErr := validate.StructPartial(combo, "Price"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		var num = toFixed(*combo.Price, 2)
+		updateObj = append(updateObj, bson.E{"price", num})
+	}
+
+	if combo.Slots != nil {
+		defaultSlotChoices(combo.Slots)
+		if validationErr := validate.StructPartial(combo, "Slots"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := checkComboSlots(ctx, combo.Slots); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"slots", combo.Slots})
+	}
+
+	if combo.MenuId != nil {
+		if *combo.MenuId == "" {
+			combo.MenuId = nil
+		} else if count, err := menuCollection.CountDocuments(ctx, bson.M{"menu_id": combo.MenuId}); err != nil || count == 0 {
+			http.Error(w, "menu was not found", http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"menu_id", combo.MenuId})
+	}
+
+	if combo.CategoryId != nil {
+		if err := checkCategory(ctx, combo.CategoryId); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if *combo.CategoryId == "" {
+			combo.CategoryId = nil
+		}
+		updateObj = append(updateObj, bson.E{"category_id", combo.CategoryId})
+	}
+
+	if request.DisplayOrder != nil {
+		updateObj = append(updateObj, bson.E{"display_order", request.DisplayOrder})
+	}
+
+	if combo.Available != nil {
+		updateObj = append(updateObj, bson.E{"available", combo.Available})
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+	result, err := comboCollection.UpdateOne(
+		ctx,
+		bson.M{"combo_id": comboId},
+		bson.D{
+			{"$set", updateObj},
+		},
+	)
+	if err != nil {
+		msg := "Combo update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "combo was not found", http.StatusNotFound)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+// defaultSlotChoices makes a slot without a number of choices take one food.
+func defaultSlotChoices(slots []models.ComboSlot) {
+	for i := range slots {
+		if slots[i].Choices == 0 {
+			slots[i].Choices = 1
+		}
+	}
+}
+
+// checkComboSlots gives the slots ids and makes sure every option is a food offered in that portion.
+func checkComboSlots(ctx context.Context, slots []models.ComboSlot) error {
+	if err := helpers.PrepareComboSlots(slots); err != nil {
+		return err
+	}
+
+	for _, slot := range slots {
+		for _, option := range slot.Options {
+			var food models.Food
+			if err := foodCollection.FindOne(ctx, bson.M{"food_id": option.FoodId}).Decode(&food); err != nil {
+				return fmt.Errorf("food %s of %s was not found", option.FoodId, slot.Name)
+			}
+			if _, err := helpers.ResolveVariant(food, option.Portion); err != nil {
+				return fmt.Errorf("%s of %s: %s", *food.Name, slot.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// expandCombos puts the child items of the ordered combos right after them. The combo items get their ids
+// here, so that the children can point to them. The prices of the combo items, the combo price plus the
+// upcharges of the choices, are returned by order item id.
+func expandCombos(ctx context.Context, requests []OrderItemRequest, at time.Time) ([]OrderItemRequest, map[string]float64, int, error) {
+	expanded := make([]OrderItemRequest, 0, len(requests))
+	prices := map[string]float64{}
+
+	for _, request := range requests {
+		if request.ComboId == nil {
+			if len(request.Selections) > 0 {
+				return nil, nil, http.StatusBadRequest, fmt.Errorf("selections are only taken for combos")
+			}
+			expanded = append(expanded, request)
+			continue
+		}
+
+		var combo models.Combo
+		if err := comboCollection.FindOne(ctx, bson.M{"combo_id": request.ComboId}).Decode(&combo); err != nil {
+			return nil, nil, http.StatusNotFound, fmt.Errorf("combo %s was not found", *request.ComboId)
+		}
+		if combo.Available != nil && !*combo.Available {
+			return nil, nil, http.StatusConflict, fmt.Errorf("%s is not available", *combo.Name)
+		}
+		if combo.MenuId != nil {
+			var menu models.Menu
+			err := menuCollection.FindOne(ctx, bson.M{"menu_id": combo.MenuId}).Decode(&menu)
+			if err != nil && err != mongo.ErrNoDocuments {
+				return nil, nil, http.StatusInternalServerError, fmt.Errorf("menu of %s could not be loaded", *combo.Name)
+			}
+			if err == nil {
+				if active, _ := helpers.MenuActive(menu, at); !active {
+					return nil, nil, http.StatusConflict, fmt.Errorf("%s is not served at this time, %s is not active", *combo.Name, menu.Name)
+				}
+			}
+		}
+
+		request.ID = primitive.NewObjectID()
+		request.OrderItemId = request.ID.Hex()
+		request.FoodId = nil
+		request.Portion = nil
+		request.Modifiers = nil
+
+		var children []OrderItemRequest
+		counts := map[string]int{}
+		upcharge := 0.0
+
+		for _, selection := range request.Selections {
+			if validationErr := validate.Struct(selection); validationErr != nil {
+				return nil, nil, http.StatusBadRequest, validationErr
+			}
+			slot, option, err := helpers.FindComboOption(combo, selection.SlotId, selection.FoodId, selection.Portion)
+			if err != nil {
+				return nil, nil, http.StatusBadRequest, fmt.Errorf("%s: %s", *combo.Name, err)
+			}
+			counts[slot.SlotId]++
+			upcharge += option.Upcharge
+
+			foodId, slotId, parentId := option.FoodId, slot.SlotId, request.OrderItemId
+			child := OrderItemRequest{Notes: selection.Notes}
+			child.FoodId = &foodId
+			child.Portion = option.Portion
+			child.Count = request.Count
+			child.Modifiers = selection.Modifiers
+			child.ComboId = request.ComboId
+			child.SlotId = &slotId
+			child.ParentItemId = &parentId
+			children = append(children, child)
+		}
+
+		if err := helpers.CheckComboChoices(combo, counts); err != nil {
+			return nil, nil, http.StatusBadRequest, err
+		}
+
+		prices[request.OrderItemId] = helpers.RoundMoney(*combo.Price + upcharge)
+		request.Selections = nil
+
+		expanded = append(expanded, request)
+		expanded = append(expanded, children...)
+	}
+
+	return expanded, prices, http.StatusOK, nil
+}
+
+// allocateComboPrices moves the price of combo children onto their combo. The modifiers chosen for the
+// children are charged on the combo, which is then allocated over the children by what they cost alone.
+func allocateComboPrices(orderItems []OrderItemRequest, unitPrices []float64, modifierDeltas []float64) {
+	for i, orderItem := range orderItems {
+		if orderItem.ComboId == nil || orderItem.ParentItemId != nil {
+			continue
+		}
+
+		var children []int
+		var weights []float64
+		for j := i + 1; j < len(orderItems) && orderItems[j].ParentItemId != nil && *orderItems[j].ParentItemId == orderItem.OrderItemId; j++ {
+			children = append(children, j)
+			weights = append(weights, unitPrices[j])
+			unitPrices[i] += modifierDeltas[j]
+		}
+		unitPrices[i] = helpers.RoundMoney(unitPrices[i])
+
+		for k, share := range helpers.AllocatePrice(unitPrices[i], weights) {
+			share := share
+			orderItems[children[k]].AllocatedPrice = &share
+			unitPrices[children[k]] = 0
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/export"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"github.com/menyasosali/restaurant-manage-backend-go/reporting"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exportRange is the period of an export, the days from first to last in the restaurant timezone.
+type exportRange struct {
+	start    time.Time
+	end      time.Time
+	first    time.Time
+	last     time.Time
+	location *time.Location
+}
+
+// exportDataset produces the rows of an export one by one, each with a value for every column.
+type exportDataset struct {
+	columns []string
+	rows    func(ctx context.Context, period exportRange, emit func(row map[string]interface{}) error) error
+}
+
+var exportDatasets = map[string]exportDataset{
+	"invoices": {
+		columns: []string{"invoice_id", "invoice_number", "order_id", "created_at", "payment_status", "payment_method", "subtotal", "discount_total", "tax", "total", "amount_paid", "amount_refunded", "voided_at", "voided_by", "void_reason"},
+		rows:    exportInvoices,
+	},
+	"orders": {
+		columns: []string{"order_id", "order_date", "table_id", "order_item_id", "parent_item_id", "food_id", "combo_id", "name", "portion", "count", "unit_price", "allocated_price", "line_total", "status", "void_reason", "created_at"},
+		rows:    exportOrderItems,
+	},
+	"payments": {
+		columns: []string{"payment_id", "invoice_id", "payment_method", "amount", "tip", "refunded_amount", "received_by", "created_at"},
+		rows:    exportPayments,
+	},
+	"z-reports": {
+		columns: []string{"number", "period_start", "period_end", "gross_sales", "discounts", "net_sales", "taxes", "total", "tips", "invoice_count", "covers", "refund_count", "refunded", "item_voids", "item_voids_amount", "invoice_voids", "invoice_voids_amount", "generated_by"},
+		rows:    exportZReports,
+	},
+	"daily-sales": {
+		columns: []string{"date", "gross_sales", "discounts", "net_sales", "taxes", "total", "tips", "invoice_count", "covers"},
+		rows:    exportDailySales,
+	},
+	"product-mix": {
+		columns: []string{"food_id", "name", "category", "units", "revenue", "average_price", "unit_cost", "cost", "margin", "unit_margin", "margin_rate", "mix_share", "quadrant"},
+		rows:    exportProductMix,
+	},
+}
+
+// ExportData streams a dataset as ?format=csv (the default) or xlsx. The days ?from= to ?to= pick the records
+// by their creation, ?columns= lists the columns to export in their order.
+func ExportData(w http.ResponseWriter, r *http.Request) {
+	// long ranges take a while to stream to slow clients
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	name := vars["dataset"]
+
+	dataset, ok := exportDatasets[name]
+	if !ok {
+		names := make([]string, 0, len(exportDatasets))
+		for datasetName := range exportDatasets {
+			names = append(names, datasetName)
+		}
+		sort.Strings(names)
+		msg := fmt.Sprintf("dataset %s does not exist, exports are available for %s", name, strings.Join(names, ", "))
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+
+	format := strings.ToLower(r.FormValue("format"))
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if format != export.FormatCSV && format != export.FormatXLSX {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+
+	columns, err := export.Columns(r.FormValue("columns"), dataset.columns)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	location, err := helpers.RestaurantLocation()
+	if err != nil {
+		http.Error(w, "RESTAURANT_TIMEZONE is not a valid timezone", http.StatusInternalServerError)
+		return
+	}
+
+	first, last, err := reportDays(r, location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	period := exportRange{start: first, end: last.AddDate(0, 0, 1), first: first, last: last, location: location}
+
+	filename := fmt.Sprintf("%s-%s-%s.%s", name, first.Format("2006-01-02"), last.Format("2006-01-02"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	// from here on the status is sent, a failure can only cut the file short
+	writer, err := export.NewWriter(format, w, name)
+	if err == nil {
+		header := make([]interface{}, len(columns))
+		for i, column := range columns {
+			header[i] = column
+		}
+		err = writer.WriteRow(header)
+	}
+	if err == nil {
+		err = dataset.rows(ctx, period, func(row map[string]interface{}) error {
+			return writer.WriteRow(export.Row(row, columns))
+		})
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		log.Printf("export of %s failed: %s", name, err)
+	}
+}
+
+func exportInvoices(ctx context.Context, period exportRange, emit func(row map[string]interface{}) error) error {
+	if err := refreshInvoiceTotals(ctx, period.start, period.end); err != nil {
+		return err
+	}
+
+	cursor, err := invoiceCollection.Find(
+		ctx,
+		bson.M{"created_at": bson.M{"$gte": period.start, "$lt": period.end}},
+		options.Find().SetSort(bson.D{{"created_at", 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var invoice models.Invoice
+		if err := cursor.Decode(&invoice); err != nil {
+			return err
+		}
+		err := emit(map[string]interface{}{
+			"invoice_id":      invoice.InvoiceId,
+			"invoice_number":  invoice.InvoiceNumber,
+			"order_id":        invoice.OrderId,
+			"created_at":      invoice.CreatedAt,
+			"payment_status":  invoice.PaymentStatus,
+			"payment_method":  invoice.PaymentMethod,
+			"subtotal":        invoice.Subtotal,
+			"discount_total":  invoice.DiscountTotal,
+			"tax":             invoice.Tax,
+			"total":           invoice.Total,
+			"amount_paid":     invoice.AmountPaid,
+			"amount_refunded": invoice.AmountRefunded,
+			"voided_at":       invoice.VoidedAt,
+			"voided_by":       invoice.VoidedBy,
+			"void_reason":     invoice.VoidReason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// exportOrderItems lists the order items with their order, one row per item. The foods of a combo follow the
+// combo with their share of its price in allocated_price.
+func exportOrderItems(ctx context.Context, period exportRange, emit func(row map[string]interface{}) error) error {
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.D{{"created_at", bson.D{{"$gte", period.start}, {"$lt", period.end}}}}}},
+		{{"$sort", bson.D{{"created_at", 1}, {"order_id", 1}}}},
+		{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}},
+		{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}},
+		{{"$lookup", bson.D{{"from", "combo"}, {"localField", "combo_id"}, {"foreignField", "combo_id"}, {"as", "combo"}}}},
+		{{"$addFields", bson.D{
+			{"order_date", bson.D{{"$arrayElemAt", bson.A{"$order.order_date", 0}}}},
+			{"table_id", bson.D{{"$arrayElemAt", bson.A{"$order.table_id", 0}}}},
+			{"food_name", bson.D{{"$arrayElemAt", bson.A{"$food.name", 0}}}},
+			{"combo_name", bson.D{{"$arrayElemAt", bson.A{"$combo.name", 0}}}},
+		}}},
+		{{"$project", bson.D{{"order", 0}, {"food", 0}, {"combo", 0}}}},
+	}
+
+	cursor, err := orderItemCollection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item struct {
+			models.OrderItem `bson:",inline"`
+			OrderDate        *time.Time `bson:"order_date"`
+			TableId          *string    `bson:"table_id"`
+			FoodName         *string    `bson:"food_name"`
+			ComboName        *string    `bson:"combo_name"`
+		}
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+
+		name := item.FoodName
+		if item.ComboId != nil && item.ParentItemId == nil {
+			name = item.ComboName
+		}
+		var lineTotal float64
+		if item.UnitPrice != nil {
+			lineTotal = helpers.RoundMoney(*item.UnitPrice * float64(item.Count))
+		}
+
+		err := emit(map[string]interface{}{
+			"order_id":        item.OrderId,
+			"order_date":      item.OrderDate,
+			"table_id":        item.TableId,
+			"order_item_id":   item.OrderItemId,
+			"parent_item_id":  item.ParentItemId,
+			"food_id":         item.FoodId,
+			"combo_id":        item.ComboId,
+			"name":            name,
+			"portion":         item.Portion,
+			"count":           item.Count,
+			"unit_price":      item.UnitPrice,
+			"allocated_price": item.AllocatedPrice,
+			"line_total":      lineTotal,
+			"status":          item.Status,
+			"void_reason":     item.VoidReason,
+			"created_at":      item.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func exportPayments(ctx context.Context, period exportRange, emit func(row map[string]interface{}) error) error {
+	cursor, err := paymentCollection.Find(
+		ctx,
+		bson.M{"created_at": bson.M{"$gte": period.start, "$lt": period.end}},
+		options.Find().SetSort(bson.D{{"created_at", 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var payment models.Payment
+		if err := cursor.Decode(&payment); err != nil {
+			return err
+		}
+		err := emit(map[string]interface{}{
+			"payment_id":      payment.PaymentId,
+			"invoice_id":      payment.InvoiceId,
+			"payment_method":  payment.PaymentMethod,
+			"amount":          payment.Amount,
+			"tip":             payment.Tip,
+			"refunded_amount": payment.RefundedAmount,
+			"received_by":     payment.ReceivedBy,
+			"created_at":      payment.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func exportZReports(ctx context.Context, period exportRange, emit func(row map[string]interface{}) error) error {
+	reports, err := reporting.ZReportsClosed(ctx, period.start, period.end)
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		err := emit(map[string]interface{}{
+			"number":               report.Number,
+			"period_start":         report.PeriodStart,
+			"period_end":           report.PeriodEnd,
+			"gross_sales":          report.GrossSales,
+			"discounts":            report.Discounts,
+			"net_sales":            report.NetSales,
+			"taxes":                report.Taxes,
+			"total":                report.Total,
+			"tips":                 report.Tips,
+			"invoice_count":        report.InvoiceCount,
+			"covers":               report.Covers,
+			"refund_count":         report.Refunds.Count,
+			"refunded":             report.Refunds.Amount,
+			"item_voids":           report.ItemVoids.Count,
+			"item_voids_amount":    report.ItemVoids.Amount,
+			"invoice_voids":        report.InvoiceVoids.Count,
+			"invoice_voids_amount": report.InvoiceVoids.Amount,
+			"generated_by":         report.GeneratedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportDailySales(ctx context.Context, period exportRange, emit func(row map[string]interface{}) error) error {
+	if err := refreshInvoiceTotals(ctx, period.start, period.end); err != nil {
+		return err
+	}
+
+	report, err := reporting.Summary(ctx, period.first, period.last, period.location)
+	if err != nil {
+		return err
+	}
+
+	for _, day := range report.Days {
+		err := emit(map[string]interface{}{
+			"date":          day.Date,
+			"gross_sales":   day.GrossSales,
+			"discounts":     day.Discounts,
+			"net_sales":     day.NetSales,
+			"taxes":         day.Taxes,
+			"total":         day.Total,
+			"tips":          day.Tips,
+			"invoice_count": day.InvoiceCount,
+			"covers":        day.Covers,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportProductMix(ctx context.Context, period exportRange, emit func(row map[string]interface{}) error) error {
+	mix, err := reporting.ProductMixReport(ctx, reporting.ProductMixFilter{Start: period.start, End: period.end})
+	if err != nil {
+		return err
+	}
+
+	for _, food := range mix.Foods {
+		err := emit(map[string]interface{}{
+			"food_id":       food.FoodId,
+			"name":          food.Name,
+			"category":      food.Category,
+			"units":         food.Units,
+			"revenue":       food.Revenue,
+			"average_price": food.AveragePrice,
+			"unit_cost":     food.UnitCost,
+			"cost":          food.Cost,
+			"margin":        food.Margin,
+			"unit_margin":   food.UnitMargin,
+			"margin_rate":   food.MarginRate,
+			"mix_share":     food.MixShare,
+			"quadrant":      food.Quadrant,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
 	"github.com/menyasosali/restaurant-manage-backend-go/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,6 +20,11 @@ import (
 	"time"
 )
 
+type FoodPage struct {
+	TotalCount int           `bson:"total_count" json:"total_count"`
+	FoodItems  []models.Food `bson:"food_items" json:"food_items"`
+}
+
 var foodCollection = database.OpenCollection(database.Client, "food")
 var validate = validator.New()
 
@@ -26,6 +32,12 @@ func GetFoods(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
+	filter, err := foodFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	recordPerPage, err := strconv.Atoi(r.FormValue("recordPerPage"))
 	if err != nil || recordPerPage < 1 {
 		recordPerPage = 10
@@ -36,10 +48,12 @@ func GetFoods(w http.ResponseWriter, r *http.Request) {
 	}
 
 	startIndex := (page - 1) * recordPerPage
-	startIndex, err = strconv.Atoi(r.FormValue("startIndex"))
+	if index, err := strconv.Atoi(r.FormValue("startIndex")); err == nil && index >= 0 {
+		startIndex = index
+	}
 
-	matchStage := bson.D{{"match", bson.D{}}}
-	groupStage := bson.D{{"group", bson.D{{"_id", bson.D{{"_id", "null"}}},
+	matchStage := bson.D{{"$match", filter}}
+	groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"_id", "null"}}},
 		{"total_count", bson.D{{"$sum", 1}}},
 		{"data", bson.D{{"$push", "$$ROOT"}}},
 	}}}
@@ -59,13 +73,25 @@ func GetFoods(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := "error occurred while listing food items"
 		http.Error(w, msg, http.StatusInternalServerError)
+		return
 	}
-	var allFoods []bson.M
+	var allFoods []FoodPage
 	if err = result.All(ctx, &allFoods); err != nil {
 		log.Fatal(err)
 	}
 
-	allFoodsJSON, err := json.Marshal(allFoods[0])
+	// nothing matched the filter
+	response := FoodPage{FoodItems: []models.Food{}}
+	if len(allFoods) > 0 {
+		response = allFoods[0]
+	}
+
+	locales := helpers.RequestedLocales(r)
+	for i := range response.FoodItems {
+		helpers.LocalizeFood(&response.FoodItems[i], locales)
+	}
+
+	allFoodsJSON, err := json.Marshal(response)
 	if err != nil {
 		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
 	}
@@ -74,6 +100,119 @@ func GetFoods(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func GetMenuFoods(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	menuId := vars["menu_id"]
+
+	filter, err := foodFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter["menu_id"] = menuId
+
+	var menu models.Menu
+	if err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu); err != nil {
+		http.Error(w, "menu was not found", http.StatusNotFound)
+		return
+	}
+
+	// ?category_id= lists the foods of the category and its subcategories
+	if categoryId := r.FormValue("category_id"); categoryId != "" {
+		categories, err := loadCategories(ctx)
+		if err != nil {
+			http.Error(w, "error occurred while listing the categories", http.StatusInternalServerError)
+			return
+		}
+		categoryIds := []string{}
+		for id := range categories {
+			for _, ancestor := range helpers.CategoryPath(categories, id) {
+				if ancestor.CategoryId == categoryId {
+					categoryIds = append(categoryIds, id)
+					break
+				}
+			}
+		}
+		filter["category_id"] = bson.M{"$in": categoryIds}
+	}
+
+	cursor, err := foodCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{"display_order", 1}, {"name", 1}}))
+	if err != nil {
+		http.Error(w, "error occurred while listing the menu food items", http.StatusInternalServerError)
+		return
+	}
+
+	foods := []models.Food{}
+	if err := cursor.All(ctx, &foods); err != nil {
+		log.Fatal(err)
+	}
+
+	locales := helpers.RequestedLocales(r)
+	for i := range foods {
+		helpers.LocalizeFood(&foods[i], locales)
+	}
+
+	foodsJSON, err := json.Marshal(foods)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(foodsJSON)
+}
+
+// foodFilter builds the food query from the label parameters: tags and exclude_tags take dietary tags,
+// exclude_allergens lists allergens the food must not contain and max_spice caps the spice level.
+// Lists are comma separated, e.g. ?tags=VEGAN,GLUTEN_FREE&exclude_allergens=NUTS
+func foodFilter(r *http.Request) (bson.M, error) {
+	filter := bson.M{}
+	var conditions []bson.M
+
+	tags, err := helpers.ParseLabels(r.FormValue("tags"), models.DietaryTags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		conditions = append(conditions, bson.M{"dietary_tags": bson.M{"$all": tags}})
+	}
+
+	excludeTags, err := helpers.ParseLabels(r.FormValue("exclude_tags"), models.DietaryTags)
+	if err != nil {
+		return nil, err
+	}
+	if len(excludeTags) > 0 {
+		conditions = append(conditions, bson.M{"dietary_tags": bson.M{"$nin": excludeTags}})
+	}
+
+	excludeAllergens, err := helpers.ParseLabels(r.FormValue("exclude_allergens"), models.Allergens)
+	if err != nil {
+		return nil, err
+	}
+	if len(excludeAllergens) > 0 {
+		conditions = append(conditions, bson.M{"allergens": bson.M{"$nin": excludeAllergens}})
+	}
+
+	if value := r.FormValue("max_spice"); value != "" {
+		maxSpice, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("max_spice must be a number")
+		}
+		// foods without a spice level are not spicy
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"spice_level": bson.M{"$lte": maxSpice}},
+			bson.M{"spice_level": nil},
+		}})
+	}
+
+	if len(conditions) > 0 {
+		filter["$and"] = conditions
+	}
+	return filter, nil
+}
+
 func GetFood(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
@@ -84,8 +223,12 @@ func GetFood(w http.ResponseWriter, r *http.Request) {
 	err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food)
 	if err != nil {
 		http.Error(w, "error occurred while fetching the food item", http.StatusInternalServerError)
+		return
 	}
 
+	locale := helpers.LocalizeFood(&food, helpers.RequestedLocales(r))
+	w.Header().Set("Content-Language", locale)
+
 	foodJSON, err := json.Marshal(food)
 	if err != nil {
 		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
@@ -112,15 +255,36 @@ func CreateFood(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := menuCollection.FindOne(ctx, bson.M{"menu_id": menu.MenuId}).Decode(&menu); err != nil {
+	if err := helpers.PrepareModifierGroups(food.ModifierGroups); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := helpers.ValidateVariants(food.Variants); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.MenuId}).Decode(&menu); err != nil {
 		msg := fmt.Sprintf("menu was not found")
-		http.Error(w, msg, http.StatusInternalServerError)
+		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
+
+	if food.CategoryId != nil && *food.CategoryId == "" {
+		food.CategoryId = nil
+	}
+	if err := checkCategory(ctx, food.CategoryId); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	food.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
 	food.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
 	food.ID = primitive.NewObjectID()
 	food.FoodId = food.ID.Hex()
+	// images are only set by UploadFoodImage
+	food.Image = nil
 	var num = toFixed(*food.Price, 2)
 	food.Price = &num
 
@@ -131,6 +295,16 @@ func CreateFood(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := recordFoodPrice(ctx, &models.FoodPrice{
+		FoodId:    food.FoodId,
+		Price:     food.Price,
+		Variants:  food.Variants,
+		Source:    models.FoodPriceSourceCreate,
+		ChangedBy: r.Header.Get("uid"),
+	}); err != nil {
+		log.Printf("price history of food %s was not started: %s", food.FoodId, err)
+	}
+
 	resJSON, err := json.Marshal(result)
 	if err != nil {
 		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
@@ -144,19 +318,27 @@ func CreateFood(w http.ResponseWriter, r *http.Request) {
 func UpdateFood(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
-	var food models.Food
+	var request struct {
+		models.Food
+		// a pointer, so that moving a food to the top with 0 is told apart from not sending it
+		DisplayOrder *int `json:"display_order"`
+	}
 	var menu models.Menu
 
-	if err := json.NewDecoder(r.Body).Decode(&food); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	food := request.Food
 
 	vars := mux.Vars(r)
 
 	foodId := vars["food_id"]
 	filter := bson.M{"food_id": foodId}
 
+	var current models.Food
+	foodCollection.FindOne(ctx, filter).Decode(&current)
+
 	var updateObj primitive.D
 
 	if food.Name != nil {
@@ -164,6 +346,8 @@ func UpdateFood(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if food.Price != nil {
+		var num = toFixed(*food.Price, 2)
+		food.Price = &num
 		updateObj = append(updateObj, bson.E{"price", food.Price})
 	}
 
@@ -171,6 +355,70 @@ func UpdateFood(w http.ResponseWriter, r *http.Request) {
 		updateObj = append(updateObj, bson.E{"food_image", food.FoodImage})
 	}
 
+	if food.Description != nil {
+		if validationErr := validate.StructPartial(food, "Description"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"description", food.Description})
+	}
+
+	if food.Translations != nil {
+		if validationErr := validate.StructPartial(food, "Translations"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"translations", food.Translations})
+	}
+
+	if food.Variants != nil {
+		if validationErr := validate.Var(food.Variants, "dive"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := helpers.ValidateVariants(food.Variants); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"variants", food.Variants})
+	}
+
+	if food.ModifierGroups != nil {
+		if validationErr := validate.Var(food.ModifierGroups, "dive"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := helpers.PrepareModifierGroups(food.ModifierGroups); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"modifier_groups", food.ModifierGroups})
+	}
+
+	if food.Allergens != nil {
+		if validationErr := validate.StructPartial(food, "Allergens"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"allergens", food.Allergens})
+	}
+
+	if food.DietaryTags != nil {
+		if validationErr := validate.StructPartial(food, "DietaryTags"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"dietary_tags", food.DietaryTags})
+	}
+
+	if food.SpiceLevel != nil {
+		if validationErr := validate.StructPartial(food, "SpiceLevel"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"spice_level", food.SpiceLevel})
+	}
+
 	if food.MenuId != nil {
 		err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.MenuId}).Decode(&menu)
 		if err != nil {
@@ -178,11 +426,26 @@ func UpdateFood(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, msg, http.StatusInternalServerError)
 			return
 		}
-		updateObj = append(updateObj, bson.E{"menu", food.MenuId})
+		updateObj = append(updateObj, bson.E{"menu_id", food.MenuId})
+	}
+
+	if food.CategoryId != nil {
+		if err := checkCategory(ctx, food.CategoryId); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if *food.CategoryId == "" {
+			food.CategoryId = nil
+		}
+		updateObj = append(updateObj, bson.E{"category_id", food.CategoryId})
+	}
+
+	if request.DisplayOrder != nil {
+		updateObj = append(updateObj, bson.E{"display_order", request.DisplayOrder})
 	}
 
 	food.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
-	updateObj = append(updateObj, bson.E{"update_at", food.UpdatedAt})
+	updateObj = append(updateObj, bson.E{"updated_at", food.UpdatedAt})
 
 	upset := true
 
@@ -201,6 +464,26 @@ func UpdateFood(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := "Food update failed"
 		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if food.Price != nil || food.Variants != nil {
+		price := models.FoodPrice{
+			FoodId:    foodId,
+			Price:     food.Price,
+			Variants:  food.Variants,
+			Source:    models.FoodPriceSourceUpdate,
+			ChangedBy: r.Header.Get("uid"),
+		}
+		if price.Price == nil {
+			price.Price = current.Price
+		}
+		if price.Variants == nil {
+			price.Variants = current.Variants
+		}
+		if err := recordFoodPrice(ctx, &price); err != nil {
+			log.Printf("price change of food %s was not recorded: %s", foodId, err)
+		}
 	}
 
 	resultJson, err := json.Marshal(result)
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+var foodPriceCollection = database.OpenCollection(database.Client, "food_price")
+
+func GetFoodPriceHistory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	foodId := vars["food_id"]
+
+	result, err := foodPriceCollection.Find(
+		ctx,
+		bson.M{"food_id": foodId},
+		options.Find().SetSort(bson.D{{"effective_from", -1}, {"created_at", -1}}),
+	)
+	if err != nil {
+		msg := "error occurred while listing the food price history"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	allPrices := []models.FoodPrice{}
+	if err = result.All(ctx, &allPrices); err != nil {
+		log.Fatal(err)
+	}
+
+	allPricesJSON, err := json.Marshal(allPrices)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(allPricesJSON)
+}
+
+// CreateFoodPrice changes the price of a food from EffectiveFrom on, right away when it is empty or past.
+func CreateFoodPrice(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var price models.FoodPrice
+
+	vars := mux.Vars(r)
+	foodId := vars["food_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&price); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if validationErr := validate.Struct(price); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := helpers.ValidateVariants(price.Variants); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if count, err := foodCollection.CountDocuments(ctx, bson.M{"food_id": foodId}); err != nil || count == 0 {
+		http.Error(w, "food was not found", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	price.FoodId = foodId
+	price.Source = models.FoodPriceSourceScheduled
+	price.ChangedBy = r.Header.Get("uid")
+	if price.EffectiveFrom.IsZero() || !price.EffectiveFrom.After(now) {
+		price.EffectiveFrom = now
+	}
+	num := toFixed(*price.Price, 2)
+	price.Price = &num
+
+	if price.EffectiveFrom.After(now) {
+		if err := recordFoodPrice(ctx, &price); err != nil {
+			msg := "Food price was not created"
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+	} else if err := applyFoodPrice(ctx, &price); err != nil {
+		msg := "Food price was not changed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	priceJSON, err := json.Marshal(price)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(priceJSON)
+}
+
+// recordFoodPrice adds an entry to the price history. Entries effective now are marked applied, the food
+// has to be updated by the caller.
+func recordFoodPrice(ctx context.Context, price *models.FoodPrice) error {
+	price.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	if price.EffectiveFrom.IsZero() {
+		price.EffectiveFrom = time.Now()
+	}
+	price.Applied = !price.EffectiveFrom.After(time.Now())
+	price.ID = primitive.NewObjectID()
+	price.FoodPriceId = price.ID.Hex()
+
+	_, err := foodPriceCollection.InsertOne(ctx, price)
+	return err
+}
+
+// applyFoodPrice records a price that is effective now and sets it on the food.
+func applyFoodPrice(ctx context.Context, price *models.FoodPrice) error {
+	if err := recordFoodPrice(ctx, price); err != nil {
+		return err
+	}
+
+	updateObj := bson.D{{"price", price.Price}, {"updated_at", price.CreatedAt}}
+	if price.Variants != nil {
+		updateObj = append(updateObj, bson.E{"variants", price.Variants})
+	}
+
+	_, err := foodCollection.UpdateOne(ctx, bson.M{"food_id": price.FoodId}, bson.D{{"$set", updateObj}})
+	return err
+}
+
+// applyDueFoodPrices sets the scheduled prices that became effective on their foods.
+func applyDueFoodPrices(ctx context.Context) {
+	var due []models.FoodPrice
+
+	result, err := foodPriceCollection.Find(
+		ctx,
+		bson.M{"applied": false, "effective_from": bson.M{"$lte": time.Now()}},
+		options.Find().SetSort(bson.D{{"effective_from", 1}}),
+	)
+	if err == nil {
+		err = result.All(ctx, &due)
+	}
+	if err != nil {
+		log.Printf("scheduled food prices were not loaded: %s", err)
+		return
+	}
+
+	for _, price := range due {
+		// marking the entry first keeps two instances from applying it twice
+		marked, err := foodPriceCollection.UpdateOne(
+			ctx,
+			bson.M{"food_price_id": price.FoodPriceId, "applied": false},
+			bson.D{{"$set", bson.D{{"applied", true}}}},
+		)
+		if err != nil || marked.ModifiedCount == 0 {
+			continue
+		}
+
+		updateObj := bson.D{{"price", price.Price}, {"updated_at", time.Now()}}
+		if price.Variants != nil {
+			updateObj = append(updateObj, bson.E{"variants", price.Variants})
+		}
+		if _, err := foodCollection.UpdateOne(ctx, bson.M{"food_id": price.FoodId}, bson.D{{"$set", updateObj}}); err != nil {
+			log.Printf("scheduled price %s of food %s was not applied: %s", price.FoodPriceId, price.FoodId, err)
+		}
+	}
+}
+
+// currentFoodPrice gives the food the price and variants of its price history at the moment. Foods created
+// before the history was kept use the price on the food.
+func currentFoodPrice(ctx context.Context, food *models.Food, at time.Time) error {
+	var price models.FoodPrice
+
+	err := foodPriceCollection.FindOne(
+		ctx,
+		bson.M{"food_id": food.FoodId, "effective_from": bson.M{"$lte": at}},
+		options.FindOne().SetSort(bson.D{{"effective_from", -1}, {"created_at", -1}}),
+	).Decode(&price)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	food.Price = price.Price
+	if price.Variants != nil {
+		food.Variants = price.Variants
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"github.com/menyasosali/restaurant-manage-backend-go/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// Blobs keeps the uploaded images, it is chosen with STORAGE_DRIVER.
+var Blobs storage.BlobStore = openBlobStore()
+
+func openBlobStore() storage.BlobStore {
+	store, err := storage.NewBlobStoreFromEnv()
+	if err != nil {
+		log.Panicf("cannot open blob store: %s", err)
+	}
+	return store
+}
+
+// UploadFoodImage replaces the image of a food with the "image" file of a multipart form.
+func UploadFoodImage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var food models.Food
+
+	vars := mux.Vars(r)
+	foodId := vars["food_id"]
+
+	if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+		http.Error(w, "food was not found", http.StatusNotFound)
+		return
+	}
+
+	processed, status, err := readImageUpload(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	image, err := storeImage(ctx, "foods/"+foodId, processed)
+	if err != nil {
+		msg := "food image was not stored"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	_, err = foodCollection.UpdateOne(
+		ctx,
+		bson.M{"food_id": foodId},
+		bson.D{{"$set", bson.D{{"image", image}, {"food_image", image.Url}, {"updated_at", updatedAt}}}},
+	)
+	if err != nil {
+		deleteImage(ctx, &image)
+		msg := "food image update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	deleteImage(ctx, food.Image)
+
+	imageJSON, err := json.Marshal(image)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(imageJSON)
+}
+
+// UploadUserAvatar replaces the avatar of a user with the "image" file of a multipart form. Users change
+// their own avatar, admins the avatar of anyone.
+func UploadUserAvatar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	vars := mux.Vars(r)
+	userId := vars["user_id"]
+
+	if r.Header.Get("uid") != userId && !helpers.HasRole(r, models.RoleAdmin) {
+		http.Error(w, "only admins can change the avatar of other users", http.StatusForbidden)
+		return
+	}
+
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+		http.Error(w, "user was not found", http.StatusNotFound)
+		return
+	}
+
+	processed, status, err := readImageUpload(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	image, err := storeImage(ctx, "avatars/"+userId, processed)
+	if err != nil {
+		msg := "avatar was not stored"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	_, err = userCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userId},
+		bson.D{{"$set", bson.D{{"avatar_image", image}, {"avatar", image.Url}, {"updated_at", updatedAt}}}},
+	)
+	if err != nil {
+		deleteImage(ctx, &image)
+		msg := "avatar update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	deleteImage(ctx, user.AvatarImage)
+
+	imageJSON, err := json.Marshal(image)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(imageJSON)
+}
+
+// readImageUpload reads and processes the "image" file of a multipart form. It returns the status code to
+// answer with when the upload is not accepted.
+func readImageUpload(w http.ResponseWriter, r *http.Request) (helpers.ProcessedImage, int, error) {
+	var processed helpers.ProcessedImage
+
+	// room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, helpers.MAX_IMAGE_BYTES+64<<10)
+
+	file, header, err := r.FormFile("image")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return processed, http.StatusRequestEntityTooLarge, fmt.Errorf("image must not be larger than %d bytes", helpers.MAX_IMAGE_BYTES)
+	}
+	if err != nil {
+		return processed, http.StatusBadRequest, fmt.Errorf("a multipart form with an image file is required")
+	}
+	defer file.Close()
+
+	if declared := header.Header.Get("Content-Type"); declared != "" {
+		mediaType, _, _ := mime.ParseMediaType(declared)
+		if mediaType != "application/octet-stream" && !containsString(helpers.ImageContentTypes, mediaType) {
+			return processed, http.StatusUnsupportedMediaType, helpers.ErrUnsupportedImage
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, helpers.MAX_IMAGE_BYTES+1))
+	if err != nil {
+		return processed, http.StatusBadRequest, err
+	}
+	if int64(len(data)) > helpers.MAX_IMAGE_BYTES {
+		return processed, http.StatusRequestEntityTooLarge, fmt.Errorf("image must not be larger than %d bytes", helpers.MAX_IMAGE_BYTES)
+	}
+
+	processed, err = helpers.ProcessImage(data)
+	if errors.Is(err, helpers.ErrUnsupportedImage) {
+		return processed, http.StatusUnsupportedMediaType, err
+	}
+	if err != nil {
+		return processed, http.StatusBadRequest, err
+	}
+	return processed, http.StatusOK, nil
+}
+
+// storeImage puts the renditions below a new folder of prefix, so replaced images never linger in caches.
+func storeImage(ctx context.Context, prefix string, processed helpers.ProcessedImage) (models.Image, error) {
+	image := models.Image{
+		ContentType: processed.ContentType,
+		Width:       processed.Width,
+		Height:      processed.Height,
+		Size:        len(processed.Original.Data),
+	}
+	image.UploadedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	folder := prefix + "/" + primitive.NewObjectID().Hex() + "/"
+	urls := []*string{&image.Url, &image.ThumbnailUrl, &image.WebpUrl, &image.ThumbnailWebpUrl}
+	renditions := []helpers.ImageRendition{processed.Original, processed.Thumbnail, processed.Webp, processed.ThumbnailWebp}
+
+	for i, rendition := range renditions {
+		key := folder + rendition.Name
+		if err := Blobs.Put(ctx, key, bytes.NewReader(rendition.Data), rendition.ContentType); err != nil {
+			deleteImage(ctx, &image)
+			return image, err
+		}
+		image.Keys = append(image.Keys, key)
+		*urls[i] = Blobs.URL(key)
+	}
+	return image, nil
+}
+
+// deleteImage removes the stored renditions of a replaced image, failures only leave unused files behind.
+func deleteImage(ctx context.Context, image *models.Image) {
+	if image == nil {
+		return
+	}
+	for _, key := range image.Keys {
+		if err := Blobs.Delete(ctx, key); err != nil {
+			log.Printf("image %s was not deleted: %s", key, err)
+		}
+	}
+}
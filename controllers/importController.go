@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/importer"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MAX_IMPORT_BYTES is the largest import file accepted, a few thousand foods fit easily.
+const MAX_IMPORT_BYTES = 10 << 20
+
+// ImportMenu imports menus, categories and foods from a CSV or JSON file, sent as the body or as the "file" of
+// a multipart form. Records are matched by sku, so the same file can be imported again after a change.
+// With ?dry_run=true nothing is written and the result tells what the import would do.
+func ImportMenu(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	// read from the query, parsing the form here would read the upload before its size is limited
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	batch, status, err := readImport(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	result, err := importer.Run(ctx, batch, dryRun, r.Header.Get("uid"))
+	if err != nil {
+		msg := fmt.Sprintf("menu could not be imported: %s", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(result.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.Write(resultJSON)
+}
+
+// readImport parses the import file, its format is told by the content type or by the extension of an
+// uploaded file. It returns the status code to answer with when the file cannot be read.
+func readImport(w http.ResponseWriter, r *http.Request) (importer.Batch, int, error) {
+	var batch importer.Batch
+
+	// room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_IMPORT_BYTES+64<<10)
+
+	var body io.Reader = r.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return batch, http.StatusRequestEntityTooLarge, fmt.Errorf("import file must not be larger than %d bytes", MAX_IMPORT_BYTES)
+		}
+		if err != nil {
+			return batch, http.StatusBadRequest, fmt.Errorf("a multipart form with a file is required")
+		}
+		defer file.Close()
+
+		body = file
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".csv":
+			mediaType = "text/csv"
+		case ".json":
+			mediaType = "application/json"
+		default:
+			mediaType, _, _ = mime.ParseMediaType(header.Header.Get("Content-Type"))
+		}
+	}
+
+	var err error
+	switch mediaType {
+	case "text/csv":
+		batch, err = importer.ParseCSV(body)
+	case "application/json":
+		batch, err = importer.ParseJSON(body)
+	default:
+		return batch, http.StatusUnsupportedMediaType, fmt.Errorf("import file must be text/csv or application/json")
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return batch, http.StatusRequestEntityTooLarge, fmt.Errorf("import file must not be larger than %d bytes", MAX_IMPORT_BYTES)
+	}
+	if err != nil {
+		return batch, http.StatusBadRequest, fmt.Errorf("import file cannot be read: %s", err)
+	}
+	return batch, http.StatusOK, nil
+}
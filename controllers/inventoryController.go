@@ -0,0 +1,581 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type StockAdjustment struct {
+	// Quantity is added for deliveries, taken away for waste and is the counted stock for counts.
+	Quantity float64 `json:"quantity" validate:"gte=0"`
+	Reason   string  `json:"reason" validate:"required,eq=DELIVERY|eq=WASTE|eq=COUNT"`
+	Comment  *string `json:"comment" validate:"omitempty,max=200"`
+}
+
+var ingredientCollection = database.OpenCollection(database.Client, "ingredient")
+var recipeCollection = database.OpenCollection(database.Client, "recipe")
+var stockMovementCollection = database.OpenCollection(database.Client, "stock_movement")
+
+func GetIngredients(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	result, err := ingredientCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{"name", 1}}))
+	if err != nil {
+		msg := "error occurred while listing ingredients"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	allIngredients := []models.Ingredient{}
+	if err = result.All(ctx, &allIngredients); err != nil {
+		log.Fatal(err)
+	}
+
+	allIngredientsJSON, err := json.Marshal(allIngredients)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(allIngredientsJSON)
+}
+
+func GetIngredient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	ingredientId := vars["ingredient_id"]
+
+	var ingredient models.Ingredient
+	if err := ingredientCollection.FindOne(ctx, bson.M{"ingredient_id": ingredientId}).Decode(&ingredient); err != nil {
+		http.Error(w, "ingredient was not found", http.StatusNotFound)
+		return
+	}
+
+	ingredientJSON, err := json.Marshal(ingredient)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(ingredientJSON)
+}
+
+func CreateIngredient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var ingredient models.Ingredient
+
+	if err := json.NewDecoder(r.Body).Decode(&ingredient); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	validationErr := validate.Struct(ingredient)
+	if validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ingredient.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	ingredient.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	ingredient.ID = primitive.NewObjectID()
+	ingredient.IngredientId = ingredient.ID.Hex()
+
+	// the opening stock is recorded like a delivery so that the movements add up to the stock
+	openingStock := ingredient.Stock
+	ingredient.Stock = 0
+
+	result, insertErr := ingredientCollection.InsertOne(ctx, ingredient)
+	if insertErr != nil {
+		msg := fmt.Sprintf("Ingredient was not created")
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if openingStock != 0 {
+		comment := "opening stock"
+		if _, err := changeStock(ctx, ingredient.IngredientId, openingStock, models.StockMovementDelivery, &comment, nil, r.Header.Get("uid")); err != nil {
+			log.Printf("opening stock of ingredient %s was not recorded: %s", ingredient.IngredientId, err)
+		}
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+func UpdateIngredient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var ingredient struct {
+		Name              *string  `json:"name" validate:"omitempty,min=2,max=100"`
+		Unit              *string  `json:"unit" validate:"omitempty,eq=g|eq=kg|eq=ml|eq=l|eq=pcs"`
+		LowStockThreshold *float64 `json:"low_stock_threshold" validate:"omitempty,gte=0"`
+		CostPerUnit       *float64 `json:"cost_per_unit" validate:"omitempty,gte=0"`
+	}
+
+	vars := mux.Vars(r)
+	ingredientId := vars["ingredient_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&ingredient); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if validationErr := validate.Struct(ingredient); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var updateObj primitive.D
+
+	if ingredient.Name != nil {
+		updateObj = append(updateObj, bson.E{"name", ingredient.Name})
+	}
+
+	if ingredient.Unit != nil {
+		updateObj = append(updateObj, bson.E{"unit", ingredient.Unit})
+	}
+
+	if ingredient.LowStockThreshold != nil {
+		updateObj = append(updateObj, bson.E{"low_stock_threshold", ingredient.LowStockThreshold})
+	}
+
+	if ingredient.CostPerUnit != nil {
+		updateObj = append(updateObj, bson.E{"cost_per_unit", ingredient.CostPerUnit})
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+	result, err := ingredientCollection.UpdateOne(
+		ctx,
+		bson.M{"ingredient_id": ingredientId},
+		bson.D{
+			{"$set", updateObj},
+		},
+	)
+	if err != nil {
+		msg := "Ingredient update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "ingredient was not found", http.StatusNotFound)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+func CreateStockAdjustment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var adjustment StockAdjustment
+	var ingredient models.Ingredient
+
+	vars := mux.Vars(r)
+	ingredientId := vars["ingredient_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&adjustment); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	adjustment.Reason = strings.ToUpper(adjustment.Reason)
+	if validationErr := validate.Struct(adjustment); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := ingredientCollection.FindOne(ctx, bson.M{"ingredient_id": ingredientId}).Decode(&ingredient); err != nil {
+		http.Error(w, "ingredient was not found", http.StatusNotFound)
+		return
+	}
+
+	quantity := adjustment.Quantity
+	switch adjustment.Reason {
+	case models.StockMovementWaste:
+		quantity = -adjustment.Quantity
+	case models.StockMovementCount:
+		quantity = adjustment.Quantity - ingredient.Stock
+	}
+
+	if quantity == 0 {
+		http.Error(w, "adjustment does not change the stock", http.StatusBadRequest)
+		return
+	}
+
+	movement, err := changeStock(ctx, ingredientId, quantity, adjustment.Reason, adjustment.Comment, nil, r.Header.Get("uid"))
+	if err != nil {
+		msg := "stock adjustment failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	refreshFoodStock(ctx, []string{ingredientId})
+
+	movementJSON, err := json.Marshal(movement)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(movementJSON)
+}
+
+func GetStockMovements(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	ingredientId := vars["ingredient_id"]
+
+	result, err := stockMovementCollection.Find(
+		ctx,
+		bson.M{"ingredient_id": ingredientId},
+		options.Find().SetSort(bson.D{{"created_at", -1}}),
+	)
+	if err != nil {
+		msg := "error occurred while listing stock movements"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	allMovements := []models.StockMovement{}
+	if err = result.All(ctx, &allMovements); err != nil {
+		log.Fatal(err)
+	}
+
+	allMovementsJSON, err := json.Marshal(allMovements)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(allMovementsJSON)
+}
+
+func GetRecipe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	foodId := vars["food_id"]
+
+	var recipe models.Recipe
+	if err := recipeCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&recipe); err != nil {
+		http.Error(w, "recipe was not found", http.StatusNotFound)
+		return
+	}
+
+	recipeJSON, err := json.Marshal(recipe)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(recipeJSON)
+}
+
+// UpdateRecipe replaces the recipe of a food.
+func UpdateRecipe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var recipe models.Recipe
+
+	vars := mux.Vars(r)
+	foodId := vars["food_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&recipe); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if validationErr := validate.Struct(recipe); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if count, err := foodCollection.CountDocuments(ctx, bson.M{"food_id": foodId}); err != nil || count == 0 {
+		http.Error(w, "food was not found", http.StatusNotFound)
+		return
+	}
+
+	ingredientIds := make([]string, 0, len(recipe.Ingredients))
+	for _, ingredient := range recipe.Ingredients {
+		ingredientIds = append(ingredientIds, ingredient.IngredientId)
+	}
+	count, err := ingredientCollection.CountDocuments(ctx, bson.M{"ingredient_id": bson.M{"$in": ingredientIds}})
+	if err != nil {
+		msg := "error occurred while checking the recipe ingredients"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if int(count) != len(ingredientIds) {
+		http.Error(w, "recipe uses an ingredient that does not exist or lists one twice", http.StatusBadRequest)
+		return
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	upsert := true
+	opt := options.UpdateOptions{
+		Upsert: &upsert,
+	}
+
+	result, err := recipeCollection.UpdateOne(
+		ctx,
+		bson.M{"food_id": foodId},
+		bson.D{
+			{"$set", bson.D{
+				{"ingredients", recipe.Ingredients},
+				{"updated_at", updatedAt},
+			}},
+			{"$setOnInsert", bson.D{{"_id", primitive.NewObjectID()}}},
+		},
+		&opt,
+	)
+	if err != nil {
+		msg := "Recipe update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	refreshFoodStock(ctx, ingredientIds)
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+// GetInventoryAlerts lists the ingredients at or below their low stock threshold and the foods that cannot be
+// made because an ingredient ran out.
+func GetInventoryAlerts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	lowStock := []models.Ingredient{}
+	result, err := ingredientCollection.Find(
+		ctx,
+		bson.M{"$expr": bson.M{"$lte": bson.A{"$stock", "$low_stock_threshold"}}},
+		options.Find().SetSort(bson.D{{"stock", 1}}),
+	)
+	if err == nil {
+		err = result.All(ctx, &lowStock)
+	}
+	if err != nil {
+		msg := "error occurred while listing low stock ingredients"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	outOfStock := []models.Food{}
+	result, err = foodCollection.Find(ctx, bson.M{"out_of_stock": true})
+	if err == nil {
+		err = result.All(ctx, &outOfStock)
+	}
+	if err != nil {
+		msg := "error occurred while listing out of stock food items"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	alertsJSON, err := json.Marshal(bson.M{"low_stock": lowStock, "out_of_stock_foods": outOfStock})
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(alertsJSON)
+}
+
+// changeStock moves the stock of an ingredient by quantity and records the movement.
+func changeStock(ctx context.Context, ingredientId string, quantity float64, reason string, comment *string, orderItemId *string, uid string) (models.StockMovement, error) {
+	var movement models.StockMovement
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	_, err := ingredientCollection.UpdateOne(
+		ctx,
+		bson.M{"ingredient_id": ingredientId},
+		bson.D{
+			{"$inc", bson.D{{"stock", quantity}}},
+			{"$set", bson.D{{"updated_at", updatedAt}}},
+		},
+	)
+	if err != nil {
+		return movement, err
+	}
+
+	movement.ID = primitive.NewObjectID()
+	movement.StockMovementId = movement.ID.Hex()
+	movement.IngredientId = ingredientId
+	movement.Quantity = quantity
+	movement.Reason = reason
+	movement.Comment = comment
+	movement.OrderItemId = orderItemId
+	movement.CreatedBy = uid
+	movement.CreatedAt = updatedAt
+
+	_, err = stockMovementCollection.InsertOne(ctx, movement)
+	return movement, err
+}
+
+// deductStock takes the recipe ingredients of the order items out of stock. Foods without a recipe are
+// not tracked. Failures are logged, the kitchen already has the order.
+func deductStock(ctx context.Context, orderItems []models.OrderItem, uid string) {
+	var touched []string
+
+	for _, orderItem := range orderItems {
+		var recipe models.Recipe
+
+		if orderItem.FoodId == nil {
+			continue
+		}
+		if err := recipeCollection.FindOne(ctx, bson.M{"food_id": orderItem.FoodId}).Decode(&recipe); err != nil {
+			if err != mongo.ErrNoDocuments {
+				log.Printf("recipe of food %s was not loaded: %s", *orderItem.FoodId, err)
+			}
+			continue
+		}
+
+		count := orderItem.Count
+		if count == 0 {
+			count = 1
+		}
+		orderItemId := orderItem.OrderItemId
+
+		for _, ingredient := range recipe.Ingredients {
+			if _, err := changeStock(ctx, ingredient.IngredientId, -ingredient.Quantity*float64(count), models.StockMovementSale, nil, &orderItemId, uid); err != nil {
+				log.Printf("stock of ingredient %s was not deducted for order item %s: %s", ingredient.IngredientId, orderItemId, err)
+				continue
+			}
+			touched = append(touched, ingredient.IngredientId)
+		}
+	}
+
+	if len(touched) > 0 {
+		refreshFoodStock(ctx, touched)
+	}
+}
+
+// restoreStock gives back what is still taken out of stock for an order item, when it is voided or changed.
+// Sales that were already given back are netted out, so it can run more than once for the same item.
+func restoreStock(ctx context.Context, orderItemId string, uid string) error {
+	var movements []models.StockMovement
+
+	result, err := stockMovementCollection.Find(ctx, bson.M{
+		"order_item_id": orderItemId,
+		"reason":        bson.M{"$in": bson.A{models.StockMovementSale, models.StockMovementVoid}},
+	})
+	if err != nil {
+		return err
+	}
+	if err = result.All(ctx, &movements); err != nil {
+		return err
+	}
+
+	var ingredientIds []string
+	taken := map[string]float64{}
+	for _, movement := range movements {
+		if _, ok := taken[movement.IngredientId]; !ok {
+			ingredientIds = append(ingredientIds, movement.IngredientId)
+		}
+		taken[movement.IngredientId] += movement.Quantity
+	}
+
+	var touched []string
+	for _, ingredientId := range ingredientIds {
+		// sums of fractional quantities are not exact, what is left of a reversed sale is rounding
+		if math.Abs(taken[ingredientId]) < 1e-9 {
+			continue
+		}
+		if _, err := changeStock(ctx, ingredientId, -taken[ingredientId], models.StockMovementVoid, nil, &orderItemId, uid); err != nil {
+			return err
+		}
+		touched = append(touched, ingredientId)
+	}
+
+	if len(touched) > 0 {
+		refreshFoodStock(ctx, touched)
+	}
+	return nil
+}
+
+// refreshFoodStock sets out_of_stock on the foods whose recipes use one of the ingredients, a food is out of
+// stock as long as any of its ingredients is.
+func refreshFoodStock(ctx context.Context, ingredientIds []string) {
+	var recipes []models.Recipe
+
+	result, err := recipeCollection.Find(ctx, bson.M{"ingredients.ingredient_id": bson.M{"$in": ingredientIds}})
+	if err == nil {
+		err = result.All(ctx, &recipes)
+	}
+	if err != nil {
+		log.Printf("recipes of ingredients %v were not loaded: %s", ingredientIds, err)
+		return
+	}
+
+	for _, recipe := range recipes {
+		recipeIngredientIds := make([]string, 0, len(recipe.Ingredients))
+		for _, ingredient := range recipe.Ingredients {
+			recipeIngredientIds = append(recipeIngredientIds, ingredient.IngredientId)
+		}
+
+		empty, err := ingredientCollection.CountDocuments(ctx, bson.M{
+			"ingredient_id": bson.M{"$in": recipeIngredientIds},
+			"stock":         bson.M{"$lte": 0},
+		})
+		if err != nil {
+			log.Printf("stock of food %s was not checked: %s", recipe.FoodId, err)
+			continue
+		}
+
+		result, err := foodCollection.UpdateOne(
+			ctx,
+			bson.M{"food_id": recipe.FoodId},
+			bson.D{{"$set", bson.D{{"out_of_stock", empty > 0}}}},
+		)
+		if err != nil {
+			log.Printf("stock of food %s was not updated: %s", recipe.FoodId, err)
+			continue
+		}
+		if result.ModifiedCount > 0 {
+			publishFoodAvailability(ctx, recipe.FoodId)
+		}
+	}
+}
@@ -6,17 +6,23 @@ import (
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
 	"github.com/menyasosali/restaurant-manage-backend-go/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"math"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 )
 
 type InvoiceViewFormat struct {
 	InvoiceId      string
+	InvoiceNumber  string
 	PaymentMethod  string
 	OrderId        string
 	PaymentStatus  *string
@@ -24,6 +30,9 @@ type InvoiceViewFormat struct {
 	TableNumber    interface{}
 	PaymentDueDate time.Time
 	OrderDetails   interface{}
+	Subtotal       float64
+	Discounts      []helpers.AppliedDiscount
+	DiscountTotal  float64
 }
 
 var invoiceCollection = database.OpenCollection(database.Client, "invoice")
@@ -32,10 +41,16 @@ func GetInvoices(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	result, err := invoiceCollection.Find(context.TODO(), bson.M{})
+	filter := bson.M{}
+	if number := r.URL.Query().Get("number"); number != "" {
+		filter["invoice_number"] = bson.M{"$regex": "^" + regexp.QuoteMeta(strings.ToUpper(number))}
+	}
+
+	result, err := invoiceCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{"invoice_number", 1}}))
 	if err != nil {
 		msg := "error occurred while listing invoice items"
 		http.Error(w, msg, http.StatusInternalServerError)
+		return
 	}
 
 	var allInvoices []bson.M
@@ -68,6 +83,19 @@ func GetInvoice(w http.ResponseWriter, r *http.Request) {
 	var invoiceView InvoiceViewFormat
 
 	allOrderItems, err := ItemsByOrder(invoice.OrderId)
+	if err != nil || len(allOrderItems) == 0 {
+		msg := "error occurred while listing the invoice order items"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	pricing, err := invoicePricing(ctx, invoice)
+	if err != nil {
+		msg := "error occurred while pricing the invoice order"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
 	invoiceView.OrderId = invoice.OrderId
 	invoiceView.PaymentDueDate = invoice.PaymentDueDate
 
@@ -77,18 +105,142 @@ func GetInvoice(w http.ResponseWriter, r *http.Request) {
 	}
 
 	invoiceView.InvoiceId = invoice.InvoiceId
+	invoiceView.InvoiceNumber = invoice.InvoiceNumber
 	invoiceView.PaymentStatus = *&invoice.PaymentStatus
-	invoiceView.PaymentDue = allOrderItems[0]["payment_due"]
+	invoiceView.Subtotal = pricing.Subtotal
+	invoiceView.Discounts = pricing.Discounts
+	invoiceView.DiscountTotal = pricing.DiscountTotal
+	invoiceView.PaymentDue = pricing.Total
 	invoiceView.TableNumber = allOrderItems[0]["table_number"]
 	invoiceView.OrderDetails = allOrderItems[0]["order_items"]
 
-	invoiceViewJSON, err := json.Marshal(invoiceView)
+	format := receiptFormat(r)
+	if format == "json" {
+		invoiceViewJSON, err := json.Marshal(invoiceView)
+		if err != nil {
+			log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(invoiceViewJSON)
+		return
+	}
+
+	receipt, err := invoiceReceipt(ctx, invoice, pricing)
 	if err != nil {
-		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+		msg := "error occurred while preparing the receipt"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if invoiceView.TableNumber != nil {
+		receipt.TableNumber = fmt.Sprint(invoiceView.TableNumber)
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(invoiceViewJSON)
+	switch format {
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", receipt.InvoiceNumber+".pdf"))
+		w.WriteHeader(http.StatusOK)
+		w.Write(helpers.RenderReceiptPDF(receipt))
+	case "html":
+		receiptHTML, err := helpers.RenderReceiptHTML(receipt)
+		if err != nil {
+			msg := "error occurred while rendering the receipt"
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(receiptHTML)
+	default:
+		width := 48
+		if r.URL.Query().Get("width") == "40" {
+			width = 40
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(helpers.RenderReceiptText(receipt, width))
+	}
+}
+
+// receiptFormat picks the invoice representation from ?format= or, without it, from the Accept header.
+func receiptFormat(r *http.Request) string {
+	switch format := strings.ToLower(r.URL.Query().Get("format")); format {
+	case "pdf", "html", "text", "json":
+		return format
+	case "txt":
+		return "text"
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "application/pdf"):
+		return "pdf"
+	case strings.Contains(accept, "text/html"):
+		return "html"
+	case strings.Contains(accept, "text/plain"):
+		return "text"
+	}
+	return "json"
+}
+
+// invoicePricing is the pricing an invoice is shown with. An open invoice follows its order, once it is paid or
+// void it keeps the totals and discounts it was billed with, only the lines are read from the order. Invoices
+// billed before the discounts were kept have no snapshot and are still priced from the order.
+func invoicePricing(ctx context.Context, invoice models.Invoice) (helpers.OrderPricing, error) {
+	pricing, err := priceOrder(ctx, invoice.OrderId)
+	if err != nil {
+		return pricing, err
+	}
+	if invoice.PaymentStatus == nil || *invoice.PaymentStatus == models.PaymentStatusPending || invoice.Discounts == nil {
+		return pricing, nil
+	}
+
+	pricing.Subtotal = invoice.Subtotal
+	pricing.Discounts = invoice.Discounts
+	pricing.DiscountTotal = invoice.DiscountTotal
+	pricing.TaxRate = invoice.TaxRate
+	pricing.Tax = invoice.Tax
+	pricing.Total = invoice.Total
+	return pricing, nil
+}
+
+func invoiceReceipt(ctx context.Context, invoice models.Invoice, pricing helpers.OrderPricing) (helpers.Receipt, error) {
+	var payments []models.Payment
+
+	receipt := helpers.NewReceipt()
+	receipt.InvoiceNumber = invoice.InvoiceNumber
+	receipt.IssuedAt = invoice.CreatedAt
+	receipt.Lines = pricing.Lines
+	receipt.Discounts = pricing.Discounts
+	receipt.Subtotal = pricing.Subtotal
+	receipt.DiscountTotal = pricing.DiscountTotal
+	receipt.TaxRate = pricing.TaxRate
+	receipt.Tax = pricing.Tax
+	receipt.Total = pricing.Total
+	if invoice.PaymentStatus != nil {
+		receipt.Status = *invoice.PaymentStatus
+	}
+
+	cursor, err := paymentCollection.Find(ctx, bson.M{"invoice_id": invoice.InvoiceId}, options.Find().SetSort(bson.D{{"created_at", 1}}))
+	if err != nil {
+		return receipt, err
+	}
+	if err = cursor.All(ctx, &payments); err != nil {
+		return receipt, err
+	}
+
+	for _, payment := range payments {
+		receipt.Payments = append(receipt.Payments, helpers.ReceiptPayment{Method: *payment.PaymentMethod, Amount: *payment.Amount})
+		if payment.Tip > 0 {
+			receipt.Payments = append(receipt.Payments, helpers.ReceiptPayment{Method: "TIP " + *payment.PaymentMethod, Amount: payment.Tip})
+		}
+		if payment.RefundedAmount > 0 {
+			receipt.Payments = append(receipt.Payments, helpers.ReceiptPayment{Method: "REFUND " + *payment.PaymentMethod, Amount: -payment.RefundedAmount})
+		}
+	}
+
+	return receipt, nil
 }
 
 func CreateInvoice(w http.ResponseWriter, r *http.Request) {
@@ -110,16 +262,29 @@ func CreateInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status := "PENDING"
-	if invoice.PaymentStatus == nil {
-		invoice.PaymentStatus = &status
-	}
+	status := models.PaymentStatusPending
+	invoice.PaymentStatus = &status
 
 	invoice.PaymentDueDate, _ = time.Parse(time.RFC822, time.Now().AddDate(0, 0, 1).Format(time.RFC822))
 	invoice.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
 	invoice.UpdatedAT, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
 	invoice.ID = primitive.NewObjectID()
-	invoice.OrderId = invoice.ID.Hex()
+	invoice.InvoiceId = invoice.ID.Hex()
+	invoice.AmountPaid = 0
+	invoice.AmountRefunded = 0
+
+	pricing, err := priceOrder(ctx, invoice.OrderId)
+	if err != nil {
+		msg := "error occurred while pricing the invoice order"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	invoice.Subtotal = pricing.Subtotal
+	invoice.Discounts = pricing.Discounts
+	invoice.DiscountTotal = pricing.DiscountTotal
+	invoice.TaxRate = pricing.TaxRate
+	invoice.Tax = pricing.Tax
+	invoice.Total = pricing.Total
 
 	validateErr := validate.Struct(invoice)
 	if validateErr != nil {
@@ -127,13 +292,33 @@ func CreateInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, insertErr := invoiceCollection.InsertOne(ctx, invoice)
+	session, err := database.Client.StartSession()
+	if err != nil {
+		msg := "error occurred while starting the invoice transaction"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	defer session.EndSession(ctx)
+
+	// the number is allocated in the same transaction as the insert, so a failed insert gives the number
+	// back and concurrent invoices are serialized on the counter document
+	result, insertErr := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		invoiceNumber, err := helpers.NextInvoiceNumber(sessCtx, invoice.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		invoice.InvoiceNumber = invoiceNumber
+
+		return invoiceCollection.InsertOne(sessCtx, invoice)
+	})
 	if insertErr != nil {
 		msg := fmt.Sprintf("Invoice item was not created")
 		http.Error(w, msg, http.StatusInternalServerError)
 		return
 	}
 
+	recordInvoiceEvent(ctx, invoice.InvoiceId, models.InvoiceEventCreated, nil, nil, r.Header.Get("uid"))
+
 	resultJson, err := json.Marshal(result)
 	if err != nil {
 		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
@@ -148,6 +333,7 @@ func UpdateInvoice(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	var invoice models.Invoice
+	var foundInvoice models.Invoice
 
 	vars := mux.Vars(r)
 	invoiceId := vars["invoice_id"]
@@ -157,39 +343,87 @@ func UpdateInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&foundInvoice); err != nil {
+		http.Error(w, "invoice was not found", http.StatusNotFound)
+		return
+	}
+
+	if *foundInvoice.PaymentStatus != models.PaymentStatusPending && *foundInvoice.PaymentStatus != models.PaymentStatusPaid {
+		msg := fmt.Sprintf("invoice is %s and can no longer be updated", *foundInvoice.PaymentStatus)
+		http.Error(w, msg, http.StatusConflict)
+		return
+	}
+
+	if invoice.PaymentStatus != nil && *invoice.PaymentStatus != *foundInvoice.PaymentStatus {
+		if *invoice.PaymentStatus != models.PaymentStatusPaid {
+			msg := "paid invoices can only be reversed with a refund or a void"
+			http.Error(w, msg, http.StatusConflict)
+			return
+		}
+
+		// marking the invoice as paid records a payment for whatever is still due
+		method := foundInvoice.PaymentMethod
+		if invoice.PaymentMethod != nil {
+			method = invoice.PaymentMethod
+		}
+		if method == nil || *method == "" {
+			http.Error(w, "payment_method is required to mark the invoice as paid", http.StatusBadRequest)
+			return
+		}
+		if validationErr := validate.Var(*method, "eq=CARD|eq=CASH"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pricing, err := priceOrder(ctx, foundInvoice.OrderId)
+		if err != nil {
+			msg := "error occurred while pricing the invoice order"
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+
+		payment, err := recordPayment(ctx, foundInvoice, *method, math.Max(pricing.Total-foundInvoice.AmountPaid, 0), 0, r.Header.Get("uid"))
+		if err == errPaymentExceedsTotal {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			msg := "Payment was not recorded"
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+
+		paymentJSON, err := json.Marshal(payment)
+		if err != nil {
+			log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(paymentJSON)
+		return
+	}
+
 	var updateObj primitive.D
 
 	if invoice.PaymentMethod != nil {
+		if validationErr := validate.Var(*invoice.PaymentMethod, "eq=CARD|eq=CASH|eq="); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
 		updateObj = append(updateObj, bson.E{"payment_method", invoice.PaymentMethod})
 	}
 
-	if invoice.PaymentStatus != nil {
-		updateObj = append(updateObj, bson.E{"payment_status", invoice.PaymentStatus})
-	}
-
 	invoice.UpdatedAT, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
-	updateObj = append(updateObj, bson.E{"update_at", invoice.UpdatedAT})
+	updateObj = append(updateObj, bson.E{"updated_at", invoice.UpdatedAT})
 
 	filter := bson.M{"invoice_id": invoiceId}
 
-	upsert := true
-
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
-	}
-
-	status := "PENDING"
-	if invoice.PaymentStatus == nil {
-		invoice.PaymentStatus = &status
-	}
-
 	result, err := invoiceCollection.UpdateOne(
 		ctx,
 		filter,
 		bson.D{
 			{"$set", updateObj},
 		},
-		&opt,
 	)
 
 	if err != nil {
@@ -198,6 +432,8 @@ func UpdateInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordInvoiceEvent(ctx, invoiceId, models.InvoiceEventUpdated, nil, nil, r.Header.Get("uid"))
+
 	resultJson, err := json.Marshal(result)
 	if err != nil {
 		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
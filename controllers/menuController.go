@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
 	"github.com/menyasosali/restaurant-manage-backend-go/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
 	"net/http"
@@ -25,11 +27,16 @@ func GetMenus(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "error occurred while listing the menu item", http.StatusBadRequest)
 	}
 
-	var allMenus []bson.M
+	allMenus := []models.Menu{}
 	if err = result.All(ctx, &allMenus); err != nil {
 		log.Fatal(err)
 	}
 
+	locales := helpers.RequestedLocales(r)
+	for i := range allMenus {
+		helpers.LocalizeMenu(&allMenus[i], locales)
+	}
+
 	allMenusJSON, err := json.Marshal(allMenus)
 	if err != nil {
 		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
@@ -39,10 +46,19 @@ func GetMenus(w http.ResponseWriter, r *http.Request) {
 	w.Write(allMenusJSON)
 }
 
+// GetMenu returns the menu with its foods nested in their categories, both in display order. Foods without a
+// category are listed in foods, the combos of the menu in combos.
 func GetMenu(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
+	type menuTree struct {
+		models.Menu
+		Categories []helpers.CategoryNode `json:"categories"`
+		Foods      []models.Food          `json:"foods"`
+		Combos     []models.Combo         `json:"combos"`
+	}
+
 	vars := mux.Vars(r)
 	menuId := vars["menu_id"]
 
@@ -51,9 +67,46 @@ func GetMenu(w http.ResponseWriter, r *http.Request) {
 	err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu)
 	if err != nil {
 		http.Error(w, "occurred while fetching the menu", http.StatusInternalServerError)
+		return
 	}
 
-	menuJSON, err := json.Marshal(menu)
+	var foods []models.Food
+	var categories []models.Category
+	combos := []models.Combo{}
+
+	result, err := foodCollection.Find(ctx, bson.M{"menu_id": menuId})
+	if err == nil {
+		err = result.All(ctx, &foods)
+	}
+	if err == nil {
+		result, err = categoryCollection.Find(ctx, bson.M{})
+	}
+	if err == nil {
+		err = result.All(ctx, &categories)
+	}
+	if err == nil {
+		result, err = comboCollection.Find(ctx, bson.M{"menu_id": menuId}, options.Find().SetSort(bson.D{{"display_order", 1}, {"name", 1}}))
+	}
+	if err == nil {
+		err = result.All(ctx, &combos)
+	}
+	if err != nil {
+		http.Error(w, "error occurred while listing the menu food items", http.StatusInternalServerError)
+		return
+	}
+
+	locales := helpers.RequestedLocales(r)
+	locale := helpers.LocalizeMenu(&menu, locales)
+	w.Header().Set("Content-Language", locale)
+
+	tree := menuTree{Menu: menu, Combos: combos}
+	tree.Categories, tree.Foods = helpers.CategoryTree(categories, foods)
+	helpers.LocalizeCategoryTree(tree.Categories, locales)
+	for i := range tree.Foods {
+		helpers.LocalizeFood(&tree.Foods[i], locales)
+	}
+
+	menuJSON, err := json.Marshal(tree)
 	if err != nil {
 		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
 	}
@@ -78,6 +131,17 @@ func CreateMenu(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := helpers.ValidateDayparts(menu.Dayparts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if menu.StartDate != nil && menu.EndDate != nil && !menu.EndDate.After(*menu.StartDate) {
+		msg := "end_date must be after start_date"
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
 	menu.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
 	menu.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
 	menu.ID = primitive.NewObjectID()
@@ -101,6 +165,7 @@ func CreateMenu(w http.ResponseWriter, r *http.Request) {
 
 func UpdateMenu(w http.ResponseWriter, r *http.Request) {
 	var menu models.Menu
+	var current models.Menu
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
@@ -113,59 +178,192 @@ func UpdateMenu(w http.ResponseWriter, r *http.Request) {
 	menuId := vars["menu_id"]
 	filter := bson.M{"menu_id": menuId}
 
+	if err := menuCollection.FindOne(ctx, filter).Decode(&current); err != nil {
+		http.Error(w, "menu was not found", http.StatusNotFound)
+		return
+	}
+
 	var updateObj primitive.D
 
-	if menu.StartDate != nil && menu.EndDate != nil {
-		if !inTimeSpan(*menu.StartDate, *menu.EndDate, time.Now()) {
-			msg := "kindly retype the time"
-			http.Error(w, msg, http.StatusInternalServerError)
-			return
-		}
+	// a date that is not sent keeps its current value for the range check
+	startDate, endDate := current.StartDate, current.EndDate
 
+	if menu.StartDate != nil {
+		startDate = menu.StartDate
 		updateObj = append(updateObj, bson.E{"start_date", menu.StartDate})
+	}
+
+	if menu.EndDate != nil {
+		endDate = menu.EndDate
 		updateObj = append(updateObj, bson.E{"end_date", menu.EndDate})
+	}
+
+	if startDate != nil && endDate != nil && !endDate.After(*startDate) {
+		msg := "end_date must be after start_date"
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if menu.Name != "" {
+		updateObj = append(updateObj, bson.E{"name", menu.Name})
+	}
+
+	if menu.Category != "" {
+		updateObj = append(updateObj, bson.E{"category", menu.Category})
+	}
 
-		if menu.Name != "" {
-			updateObj = append(updateObj, bson.E{"name", menu.Name})
+	if menu.Translations != nil {
+		if validationErr := validate.StructPartial(menu, "Translations"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
 		}
+		updateObj = append(updateObj, bson.E{"translations", menu.Translations})
+	}
 
-		if menu.Category != "" {
-			updateObj = append(updateObj, bson.E{"category", menu.Category})
+	if menu.Dayparts != nil {
+		if validationErr := validate.StructPartial(menu, "Dayparts"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
 		}
+		if err := helpers.ValidateDayparts(menu.Dayparts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"dayparts", menu.Dayparts})
+	}
 
-		menu.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
-		updateObj = append(updateObj, bson.E{"update_at", menu.UpdatedAt})
+	if menu.Timezone != nil {
+		if validationErr := validate.StructPartial(menu, "Timezone"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"timezone", menu.Timezone})
+	}
 
-		upsert := true
+	menu.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	updateObj = append(updateObj, bson.E{"updated_at", menu.UpdatedAt})
 
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
-		}
+	result, err := menuCollection.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", updateObj},
+		},
+	)
+
+	if err != nil {
+		msg := "Menu update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resultJson, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJson)
+}
+
+// GetActiveMenus returns the menus served at ?at= (RFC 3339, now when empty) with the foods that can be
+// ordered from them at that moment.
+func GetActiveMenus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
 
-		result, err := menuCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
-		)
+	type activeMenu struct {
+		models.Menu
+		Daypart string        `json:"daypart"`
+		Foods   []models.Food `json:"foods"`
+	}
 
+	at := time.Now()
+	if value := r.FormValue("at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
 		if err != nil {
-			msg := "Menu update failed"
-			http.Error(w, msg, http.StatusInternalServerError)
+			http.Error(w, "at must be an RFC 3339 time, e.g. 2026-03-01T09:30:00+01:00", http.StatusBadRequest)
+			return
+		}
+		at = parsed
+	}
+
+	var menus []models.Menu
+	result, err := menuCollection.Find(ctx, bson.M{})
+	if err == nil {
+		err = result.All(ctx, &menus)
+	}
+	if err != nil {
+		http.Error(w, "error occurred while listing the menus", http.StatusInternalServerError)
+		return
+	}
+
+	locales := helpers.RequestedLocales(r)
+	activeMenus := []activeMenu{}
+	for _, menu := range menus {
+		active, daypart := helpers.MenuActive(menu, at)
+		if !active {
+			continue
 		}
 
-		resultJson, err := json.Marshal(result)
+		var foods []models.Food
+		result, err := foodCollection.Find(ctx, bson.M{"menu_id": menu.MenuId}, options.Find().SetSort(bson.D{{"name", 1}}))
+		if err == nil {
+			err = result.All(ctx, &foods)
+		}
 		if err != nil {
-			log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+			http.Error(w, "error occurred while listing the menu food items", http.StatusInternalServerError)
+			return
+		}
+
+		orderable := []models.Food{}
+		for _, food := range foods {
+			if helpers.FoodOrderable(food, 1) == nil {
+				helpers.LocalizeFood(&food, locales)
+				orderable = append(orderable, food)
+			}
 		}
+		helpers.LocalizeMenu(&menu, locales)
+
+		activeMenus = append(activeMenus, activeMenu{Menu: menu, Daypart: daypart, Foods: orderable})
+	}
 
-		w.WriteHeader(http.StatusOK)
-		w.Write(resultJson)
+	activeMenusJSON, err := json.Marshal(bson.M{"at": at, "menus": activeMenus})
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
 	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(activeMenusJSON)
+}
+
+// menuNotServedError tells that the menu of a food is not served, other errors of menuServed are failed lookups.
+type menuNotServedError struct {
+	food string
+	menu string
 }
 
-func inTimeSpan(start, end, check time.Time) bool {
-	return start.After(check) && end.After(start)
+func (e menuNotServedError) Error() string {
+	return fmt.Sprintf("%s is not served at this time, %s is not active", e.food, e.menu)
+}
+
+// menuServed checks that the menu of the food is served at the moment and returns the menu. Foods whose
+// menu does not exist are not restricted.
+func menuServed(ctx context.Context, food models.Food, at time.Time) (models.Menu, error) {
+	var menu models.Menu
+
+	if food.MenuId == nil {
+		return menu, nil
+	}
+	err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.MenuId}).Decode(&menu)
+	if err == mongo.ErrNoDocuments {
+		return menu, nil
+	}
+	if err != nil {
+		return menu, err
+	}
+	if active, _ := helpers.MenuActive(menu, at); !active {
+		return menu, menuNotServedError{food: *food.Name, menu: menu.Name}
+	}
+	return menu, nil
 }
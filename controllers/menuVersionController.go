@@ -0,0 +1,620 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+type PublishRequest struct {
+	// PublishAt schedules the publication, the version is published right away when it is empty or past.
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+type RollbackRequest struct {
+	MenuVersionId string `json:"menu_version_id" validate:"required"`
+}
+
+// MenuVersionPreview is a version with the differences to the live menu.
+type MenuVersionPreview struct {
+	models.MenuVersion
+	AddedFoods   []string `json:"added_foods"`
+	RemovedFoods []string `json:"removed_foods"`
+	ChangedFoods []string `json:"changed_foods"`
+}
+
+var menuVersionCollection = database.OpenCollection(database.Client, "menu_version")
+
+var errMenuVersionChanged = errors.New("menu version was changed by someone else")
+
+// CreateMenuVersion starts a draft from the live menu.
+func CreateMenuVersion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var menu models.Menu
+
+	vars := mux.Vars(r)
+	menuId := vars["menu_id"]
+
+	if err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu); err != nil {
+		http.Error(w, "menu was not found", http.StatusNotFound)
+		return
+	}
+
+	liveFoods, err := menuFoods(ctx, menuId)
+	if err != nil {
+		msg := "error occurred while listing the menu food items"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	version := models.MenuVersion{
+		Name:     menu.Name,
+		Category: menu.Category,
+		Foods:    liveFoods,
+		BasedOn:  menu.PublishedVersionId,
+	}
+
+	result, err := insertMenuVersion(ctx, menuId, &version, r.Header.Get("uid"))
+	if err != nil {
+		msg := fmt.Sprintf("Menu version was not created")
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+func GetMenuVersions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	menuId := vars["menu_id"]
+
+	result, err := menuVersionCollection.Find(
+		ctx,
+		bson.M{"menu_id": menuId},
+		options.Find().SetSort(bson.D{{"version", -1}}),
+	)
+	if err != nil {
+		msg := "error occurred while listing the menu versions"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	allVersions := []models.MenuVersion{}
+	if err = result.All(ctx, &allVersions); err != nil {
+		log.Fatal(err)
+	}
+
+	allVersionsJSON, err := json.Marshal(allVersions)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(allVersionsJSON)
+}
+
+// GetMenuVersion previews a version against the live menu.
+func GetMenuVersion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var version models.MenuVersion
+
+	vars := mux.Vars(r)
+	menuId := vars["menu_id"]
+	versionId := vars["menu_version_id"]
+
+	if err := menuVersionCollection.FindOne(ctx, bson.M{"menu_id": menuId, "menu_version_id": versionId}).Decode(&version); err != nil {
+		http.Error(w, "menu version was not found", http.StatusNotFound)
+		return
+	}
+
+	liveFoods, err := menuFoods(ctx, menuId)
+	if err != nil {
+		msg := "error occurred while listing the menu food items"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	preview := MenuVersionPreview{MenuVersion: version, AddedFoods: []string{}, RemovedFoods: []string{}, ChangedFoods: []string{}}
+	live := map[string]models.MenuVersionFood{}
+	for _, food := range liveFoods {
+		live[food.FoodId] = food
+	}
+	for _, food := range version.Foods {
+		current, ok := live[food.FoodId]
+		switch {
+		case !ok:
+			preview.AddedFoods = append(preview.AddedFoods, food.FoodId)
+		case current.Name != food.Name || current.Price != food.Price || helpers.VariantsChanged(current.Variants, food.Variants),
+			food.CategoryId != nil && (current.CategoryId == nil || *current.CategoryId != *food.CategoryId),
+			food.CategoryId != nil && current.DisplayOrder != food.DisplayOrder:
+			preview.ChangedFoods = append(preview.ChangedFoods, food.FoodId)
+		}
+		delete(live, food.FoodId)
+	}
+	for foodId := range live {
+		preview.RemovedFoods = append(preview.RemovedFoods, foodId)
+	}
+
+	previewJSON, err := json.Marshal(preview)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(previewJSON)
+}
+
+// UpdateMenuVersion stages changes on a draft. Foods replace the whole food list of the version.
+func UpdateMenuVersion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var version models.MenuVersion
+
+	vars := mux.Vars(r)
+	menuId := vars["menu_id"]
+	versionId := vars["menu_version_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&version); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var updateObj primitive.D
+
+	if version.Name != "" {
+		updateObj = append(updateObj, bson.E{"name", version.Name})
+	}
+
+	if version.Category != "" {
+		updateObj = append(updateObj, bson.E{"category", version.Category})
+	}
+
+	if version.Foods != nil {
+		if validationErr := validate.StructPartial(version, "Foods"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, food := range version.Foods {
+			if err := helpers.ValidateVariants(food.Variants); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := checkVersionFoods(ctx, version.Foods); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"foods", version.Foods})
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+	result, err := menuVersionCollection.UpdateOne(
+		ctx,
+		bson.M{"menu_id": menuId, "menu_version_id": versionId, "status": models.MenuVersionDraft},
+		bson.D{
+			{"$set", updateObj},
+		},
+	)
+	if err != nil {
+		msg := "Menu version update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "menu version was not found or is no longer a draft", http.StatusConflict)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+// PublishMenuVersion publishes a draft now or schedules it for PublishAt.
+func PublishMenuVersion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var request PublishRequest
+	var version models.MenuVersion
+
+	vars := mux.Vars(r)
+	menuId := vars["menu_id"]
+	versionId := vars["menu_version_id"]
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := menuVersionCollection.FindOne(ctx, bson.M{"menu_id": menuId, "menu_version_id": versionId}).Decode(&version); err != nil {
+		http.Error(w, "menu version was not found", http.StatusNotFound)
+		return
+	}
+
+	if version.Status != models.MenuVersionDraft && version.Status != models.MenuVersionScheduled {
+		http.Error(w, "only drafts and scheduled versions can be published", http.StatusConflict)
+		return
+	}
+
+	uid := r.Header.Get("uid")
+
+	if request.PublishAt != nil && request.PublishAt.After(time.Now()) {
+		updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+		result, err := menuVersionCollection.UpdateOne(
+			ctx,
+			bson.M{"menu_version_id": versionId, "status": version.Status},
+			bson.D{
+				{"$set", bson.D{
+					{"status", models.MenuVersionScheduled},
+					{"publish_at", request.PublishAt},
+					{"published_by", uid},
+					{"updated_at", updatedAt},
+				}},
+			},
+		)
+		if err != nil {
+			msg := "Menu version scheduling failed"
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, errMenuVersionChanged.Error(), http.StatusConflict)
+			return
+		}
+	} else if err := publishMenuVersion(ctx, version, version.Status, uid); err != nil {
+		status := http.StatusInternalServerError
+		if err == errMenuVersionChanged {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := menuVersionCollection.FindOne(ctx, bson.M{"menu_version_id": versionId}).Decode(&version); err != nil {
+		http.Error(w, "menu version was not found", http.StatusNotFound)
+		return
+	}
+
+	versionJSON, err := json.Marshal(version)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(versionJSON)
+}
+
+// RollbackMenu publishes a copy of an earlier version, so the history keeps moving forward.
+func RollbackMenu(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var request RollbackRequest
+	var previous models.MenuVersion
+
+	vars := mux.Vars(r)
+	menuId := vars["menu_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := menuVersionCollection.FindOne(ctx, bson.M{"menu_id": menuId, "menu_version_id": request.MenuVersionId}).Decode(&previous); err != nil {
+		http.Error(w, "menu version was not found", http.StatusNotFound)
+		return
+	}
+
+	if previous.PublishedAt == nil {
+		http.Error(w, "only versions that were published can be rolled back to", http.StatusConflict)
+		return
+	}
+
+	// foods deleted since then cannot come back
+	if err := checkVersionFoods(ctx, previous.Foods); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	uid := r.Header.Get("uid")
+	version := models.MenuVersion{
+		Name:     previous.Name,
+		Category: previous.Category,
+		Foods:    previous.Foods,
+		BasedOn:  &previous.MenuVersionId,
+	}
+
+	if _, err := insertMenuVersion(ctx, menuId, &version, uid); err != nil {
+		msg := "Menu version was not created"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if err := publishMenuVersion(ctx, version, models.MenuVersionDraft, uid); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := menuVersionCollection.FindOne(ctx, bson.M{"menu_version_id": version.MenuVersionId}).Decode(&version); err != nil {
+		http.Error(w, "menu version was not found", http.StatusNotFound)
+		return
+	}
+
+	versionJSON, err := json.Marshal(version)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(versionJSON)
+}
+
+// RunMenuPublisher publishes scheduled menu versions and food prices when they are due, checking every
+// interval. Several instances can run it, the status filters let only one of them publish.
+func RunMenuPublisher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		PublishDue(ctx)
+		cancel()
+	}
+}
+
+// PublishDue publishes the menu versions and applies the food prices that are due now, failures are logged.
+func PublishDue(ctx context.Context) {
+	publishDueMenuVersions(ctx)
+	applyDueFoodPrices(ctx)
+}
+
+func publishDueMenuVersions(ctx context.Context) {
+
+	var due []models.MenuVersion
+
+	result, err := menuVersionCollection.Find(
+		ctx,
+		bson.M{"status": models.MenuVersionScheduled, "publish_at": bson.M{"$lte": time.Now()}},
+		options.Find().SetSort(bson.D{{"publish_at", 1}}),
+	)
+	if err == nil {
+		err = result.All(ctx, &due)
+	}
+	if err != nil {
+		log.Printf("scheduled menu versions were not loaded: %s", err)
+		return
+	}
+
+	for _, version := range due {
+		publishedBy := ""
+		if version.PublishedBy != nil {
+			publishedBy = *version.PublishedBy
+		}
+		if err := publishMenuVersion(ctx, version, models.MenuVersionScheduled, publishedBy); err != nil && err != errMenuVersionChanged {
+			log.Printf("menu version %s was not published: %s", version.MenuVersionId, err)
+		}
+	}
+}
+
+// publishMenuVersion makes the version live: the menu and its foods get the names, prices and membership
+// of the version in one transaction, the version published before it is archived.
+func publishMenuVersion(ctx context.Context, version models.MenuVersion, fromStatus string, uid string) error {
+	session, err := database.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		publishedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+		result, err := menuVersionCollection.UpdateOne(
+			sessCtx,
+			bson.M{"menu_version_id": version.MenuVersionId, "status": fromStatus},
+			bson.D{
+				{"$set", bson.D{
+					{"status", models.MenuVersionPublished},
+					{"published_at", publishedAt},
+					{"published_by", uid},
+					{"updated_at", publishedAt},
+				}},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if result.ModifiedCount == 0 {
+			return nil, errMenuVersionChanged
+		}
+
+		if _, err := menuVersionCollection.UpdateMany(
+			sessCtx,
+			bson.M{
+				"menu_id":         version.MenuId,
+				"status":          models.MenuVersionPublished,
+				"menu_version_id": bson.M{"$ne": version.MenuVersionId},
+			},
+			bson.D{{"$set", bson.D{{"status", models.MenuVersionArchived}}}},
+		); err != nil {
+			return nil, err
+		}
+
+		if _, err := menuCollection.UpdateOne(
+			sessCtx,
+			bson.M{"menu_id": version.MenuId},
+			bson.D{
+				{"$set", bson.D{
+					{"name", version.Name},
+					{"category", version.Category},
+					{"published_version_id", version.MenuVersionId},
+					{"updated_at", publishedAt},
+				}},
+			},
+		); err != nil {
+			return nil, err
+		}
+
+		foodIds := make([]string, 0, len(version.Foods))
+		for _, food := range version.Foods {
+			var before models.Food
+
+			foodIds = append(foodIds, food.FoodId)
+			updateObj := bson.D{
+				{"name", food.Name},
+				{"price", food.Price},
+				{"variants", food.Variants},
+				{"menu_id", version.MenuId},
+				{"updated_at", publishedAt},
+			}
+			if food.CategoryId != nil {
+				updateObj = append(updateObj, bson.E{"category_id", food.CategoryId}, bson.E{"display_order", food.DisplayOrder})
+			}
+			if err := foodCollection.FindOneAndUpdate(
+				sessCtx,
+				bson.M{"food_id": food.FoodId},
+				bson.D{{"$set", updateObj}},
+			).Decode(&before); err != nil {
+				return nil, err
+			}
+
+			if before.Price == nil || *before.Price != food.Price || helpers.VariantsChanged(before.Variants, food.Variants) {
+				price := food.Price
+				if err := recordFoodPrice(sessCtx, &models.FoodPrice{
+					FoodId:    food.FoodId,
+					Price:     &price,
+					Variants:  food.Variants,
+					Source:    models.FoodPriceSourceMenuVersion,
+					Reason:    &version.MenuVersionId,
+					ChangedBy: uid,
+				}); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// foods taken off the menu stay in the database for the order history
+		return foodCollection.UpdateMany(
+			sessCtx,
+			bson.M{"menu_id": version.MenuId, "food_id": bson.M{"$nin": foodIds}},
+			bson.D{{"$set", bson.D{{"menu_id", nil}, {"updated_at", publishedAt}}}},
+		)
+	})
+	return err
+}
+
+func insertMenuVersion(ctx context.Context, menuId string, version *models.MenuVersion, uid string) (*mongo.InsertOneResult, error) {
+	number, err := helpers.NextSequence(ctx, "menu_version:"+menuId)
+	if err != nil {
+		return nil, err
+	}
+
+	version.MenuId = menuId
+	version.Version = number
+	version.Status = models.MenuVersionDraft
+	version.CreatedBy = uid
+	version.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	version.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	version.ID = primitive.NewObjectID()
+	version.MenuVersionId = version.ID.Hex()
+
+	return menuVersionCollection.InsertOne(ctx, version)
+}
+
+// menuFoods snapshots the live foods of a menu.
+func menuFoods(ctx context.Context, menuId string) ([]models.MenuVersionFood, error) {
+	var foods []models.Food
+
+	result, err := foodCollection.Find(ctx, bson.M{"menu_id": menuId}, options.Find().SetSort(bson.D{{"name", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err = result.All(ctx, &foods); err != nil {
+		return nil, err
+	}
+
+	snapshot := make([]models.MenuVersionFood, 0, len(foods))
+	for _, food := range foods {
+		versionFood := models.MenuVersionFood{
+			FoodId:       food.FoodId,
+			Variants:     food.Variants,
+			CategoryId:   food.CategoryId,
+			DisplayOrder: food.DisplayOrder,
+		}
+		if food.Name != nil {
+			versionFood.Name = *food.Name
+		}
+		if food.Price != nil {
+			versionFood.Price = *food.Price
+		}
+		snapshot = append(snapshot, versionFood)
+	}
+	return snapshot, nil
+}
+
+// checkVersionFoods makes sure every food of a version exists and is listed once.
+func checkVersionFoods(ctx context.Context, foods []models.MenuVersionFood) error {
+	foodIds := make([]string, 0, len(foods))
+	seen := map[string]bool{}
+	for _, food := range foods {
+		if seen[food.FoodId] {
+			return fmt.Errorf("food %s is listed more than once", food.FoodId)
+		}
+		seen[food.FoodId] = true
+		foodIds = append(foodIds, food.FoodId)
+	}
+
+	count, err := foodCollection.CountDocuments(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return err
+	}
+	if int(count) != len(foodIds) {
+		return fmt.Errorf("menu version lists foods that do not exist")
+	}
+
+	for _, food := range foods {
+		if err := checkCategory(ctx, food.CategoryId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var noteCollection = database.OpenCollection(database.Client, "note")
+
+// GetTargetNotes lists the notes of the entity identified by the idVar route variable, pinned notes first.
+func GetTargetNotes(targetType string, idVar string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		vars := mux.Vars(r)
+		targetId := vars[idVar]
+
+		notes, err := findNotes(ctx, targetType, []string{targetId})
+		if err != nil {
+			msg := "error occurred while listing notes"
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+
+		notesJSON, err := json.Marshal(notes)
+		if err != nil {
+			log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(notesJSON)
+	}
+}
+
+// CreateTargetNote attaches a note to the entity identified by the idVar route variable.
+func CreateTargetNote(targetType string, idVar string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note models.Note
+
+		vars := mux.Vars(r)
+
+		if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		note.TargetType = targetType
+		note.TargetId = vars[idVar]
+
+		if status, err := createNote(ctx, r, &note); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		noteJSON, err := json.Marshal(note)
+		if err != nil {
+			log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(noteJSON)
+	}
+}
+
+func UpdateNote(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var note struct {
+		Text      *string  `json:"text"`
+		Title     *string  `json:"title"`
+		Kind      *string  `json:"kind"`
+		Allergens []string `json:"allergens"`
+		Pinned    *bool    `json:"pinned"`
+	}
+
+	vars := mux.Vars(r)
+	noteId := vars["note_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var updateObj primitive.D
+
+	if note.Text != nil {
+		if validationErr := validate.Var(*note.Text, "required,max=1000"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"text", note.Text})
+	}
+
+	if note.Title != nil {
+		updateObj = append(updateObj, bson.E{"title", note.Title})
+	}
+
+	if note.Kind != nil {
+		if validationErr := validate.Var(*note.Kind, "eq=GENERAL|eq=ALLERGY|eq=REQUEST"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"kind", note.Kind})
+	}
+
+	if note.Allergens != nil {
+		if validationErr := validate.StructPartial(models.Note{Allergens: note.Allergens}, "Allergens"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"allergens", note.Allergens})
+	}
+
+	if note.Pinned != nil {
+		updateObj = append(updateObj, bson.E{"pinned", note.Pinned})
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+	result, err := noteCollection.UpdateOne(
+		ctx,
+		bson.M{"note_id": noteId},
+		bson.D{
+			{"$set", updateObj},
+		},
+	)
+	if err != nil {
+		msg := "Note update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		http.Error(w, "note was not found", http.StatusNotFound)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+func DeleteNote(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	noteId := vars["note_id"]
+
+	result, err := noteCollection.DeleteOne(ctx, bson.M{"note_id": noteId})
+	if err != nil {
+		msg := "Note was not deleted"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if result.DeletedCount == 0 {
+		http.Error(w, "note was not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createNote validates the note, checks that its target exists and stores it with the request user as author.
+// The returned status is meant for the response when the note is rejected.
+func createNote(ctx context.Context, r *http.Request, note *models.Note) (int, error) {
+	if note.Kind == "" {
+		note.Kind = models.NoteKindGeneral
+	}
+	note.Text = strings.TrimSpace(note.Text)
+
+	if validationErr := validate.Struct(note); validationErr != nil {
+		return http.StatusBadRequest, validationErr
+	}
+
+	var targetCollection *mongo.Collection
+	var targetField string
+
+	switch note.TargetType {
+	case models.NoteTargetOrder:
+		targetCollection, targetField = orderCollection, "order_id"
+	case models.NoteTargetOrderItem:
+		targetCollection, targetField = orderItemCollection, "order_item_id"
+	case models.NoteTargetTable:
+		targetCollection, targetField = tableCollection, "table_id"
+	}
+
+	if targetCollection != nil {
+		count, err := targetCollection.CountDocuments(ctx, bson.M{targetField: note.TargetId})
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("error occurred while checking the note target")
+		}
+		if count == 0 {
+			return http.StatusNotFound, fmt.Errorf("%s %s was not found", strings.ToLower(note.TargetType), note.TargetId)
+		}
+	}
+
+	note.AuthorId = r.Header.Get("uid")
+	note.AuthorName = strings.TrimSpace(r.Header.Get("first_name") + " " + r.Header.Get("second_name"))
+	note.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	note.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	note.ID = primitive.NewObjectID()
+	note.NoteId = note.ID.Hex()
+
+	if _, err := noteCollection.InsertOne(ctx, note); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Note was not created")
+	}
+
+	return http.StatusOK, nil
+}
+
+func findNotes(ctx context.Context, targetType string, targetIds []string) ([]models.Note, error) {
+	notes := []models.Note{}
+
+	cursor, err := noteCollection.Find(
+		ctx,
+		bson.M{"target_type": targetType, "target_id": bson.M{"$in": targetIds}},
+		options.Find().SetSort(bson.D{{"pinned", -1}, {"created_at", 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &notes)
+	return notes, err
+}
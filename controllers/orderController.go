@@ -15,7 +15,6 @@ import (
 	"time"
 )
 
-var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 var orderCollection = database.OpenCollection(database.Client, "order")
 
 func GetOrders(w http.ResponseWriter, r *http.Request) {
@@ -130,12 +129,12 @@ func UpdateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if order.TableId != nil {
-		if err := orderCollection.FindOne(ctx, bson.M{"table_id": order.TableId}).Decode(&table); err != nil {
-			msg := fmt.Sprintf("message: Order was not found")
+		if err := tableCollection.FindOne(ctx, bson.M{"table_id": order.TableId}).Decode(&table); err != nil {
+			msg := fmt.Sprintf("message: Table was not found")
 			http.Error(w, msg, http.StatusInternalServerError)
 			return
 		}
-		updateObj = append(updateObj, bson.E{"menu", order.TableId})
+		updateObj = append(updateObj, bson.E{"table_id", order.TableId})
 	}
 
 	order.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
@@ -172,7 +171,9 @@ func UpdateOrder(w http.ResponseWriter, r *http.Request) {
 }
 
 func OrderItemOrderCreator(order models.Order) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
+
 	order.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
 	order.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
 	order.ID = primitive.NewObjectID()
@@ -3,22 +3,35 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
 	"github.com/menyasosali/restaurant-manage-backend-go/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// OrderItemPack is a round of items sent to the kitchen. Without OrderId a new order is opened for the table.
 type OrderItemPack struct {
 	TableId    *string
-	OrderItems []models.OrderItem
+	OrderId    *string
+	Notes      []models.Note
+	OrderItems []OrderItemRequest
+}
+
+// OrderItemRequest is an order item as submitted by the waiter, optionally with notes for the kitchen.
+// Combos are ordered with their combo_id and a selection for every choice of their slots.
+type OrderItemRequest struct {
+	models.OrderItem
+	Notes      []models.Note    `json:"notes"`
+	Selections []ComboSelection `json:"selections"`
 }
 
 var orderItemCollection = database.OpenCollection(database.Client, "orderItem")
@@ -94,19 +107,26 @@ func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	matchStage := bson.D{{"$match", bson.D{{"order_id", id}}}}
-	lookupStage := bson.D{{"lookup", bson.D{
+	matchStage := bson.D{{"$match", bson.D{{"order_id", id}, {"status", bson.D{{"$ne", models.OrderItemStatusVoid}}}}}}
+	lookupStage := bson.D{{"$lookup", bson.D{
 		{"from", "food"},
 		{"localField", "food_id"},
 		{"foreignField", "food_id"},
 		{"as", "food"}}}}
 	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
 
+	lookupComboStage := bson.D{{"$lookup", bson.D{
+		{"from", "combo"},
+		{"localField", "combo_id"},
+		{"foreignField", "combo_id"},
+		{"as", "combo"}}}}
+	unwindComboStage := bson.D{{"$unwind", bson.D{{"path", "$combo"}, {"preserveNullAndEmptyArrays", true}}}}
+
 	lookupOrderStage := bson.D{{"$lookup", bson.D{
 		{"from", "order"},
 		{"localField", "order_id"},
 		{"foreignField", "order_id"},
-		{"as", "food"}}}}
+		{"as", "order"}}}}
 	unwindOrderStage := bson.D{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}}
 
 	lookupTableStage := bson.D{{"$lookup", bson.D{
@@ -116,34 +136,45 @@ func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
 		{"as", "table"}}}}
 	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
 
+	// documents written before counts existed are a single item
+	countExpr := bson.D{{"$ifNull", bson.A{"$count", 1}}}
+	// the foods of a combo are listed, but counted and paid with the combo
+	isComboFood := bson.D{{"$gt", bson.A{"$parent_item_id", nil}}}
+
 	projectStage := bson.D{
 		{"$project", bson.D{
-			{"id", 0},
-			{"amount", "$food.price"},
-			{"total_count", 1},
-			{"food_name", "$food.name"},
+			{"_id", 0},
+			{"amount", bson.D{{"$multiply", bson.A{"$unit_price", countExpr}}}},
+			{"food_name", bson.D{{"$ifNull", bson.A{"$food.name", "$combo.name"}}}},
 			{"food_image", "$food.food_image"},
 			{"table_number", "$table.table_number"},
 			{"table_id", "$table.table_id"},
-			{"order_id", "$order.order_id"},
-			{"price", "$food.price"},
-			{"quantity", 1},
+			{"order_id", "$order_id"},
+			{"order_item_id", "$order_item_id"},
+			{"price", "$unit_price"},
+			{"count", countExpr},
+			{"portion", 1},
+			{"modifiers", 1},
+			{"combo_id", 1},
+			{"parent_item_id", 1},
+			{"allocated_price", 1},
+			{"is_combo_food", isComboFood},
 		}},
 	}
 
 	groupStage := bson.D{
-		{"$group", bson.D{{"_id", bson.D{
-			{"order_id", "$order_id"},
-			{"table_id", "$table_id"},
-			{"table_number", "$table_number"}}},
-
-			{"payment_due", bson.D{{"$sum", "$amount"},
-				{"total_count", bson.D{{"$sum", 1},
-					{"order_items", bson.D{{"", ""}}}}}}}}}}
+		{"$group", bson.D{
+			{"_id", bson.D{
+				{"order_id", "$order_id"},
+				{"table_id", "$table_id"},
+				{"table_number", "$table_number"}}},
+			{"payment_due", bson.D{{"$sum", "$amount"}}},
+			{"total_count", bson.D{{"$sum", bson.D{{"$cond", bson.A{"$is_combo_food", 0, "$count"}}}}}},
+			{"order_items", bson.D{{"$push", "$$ROOT"}}}}}}
 
 	projectStage2 := bson.D{
 		{"$project", bson.D{
-			{"id", 0},
+			{"_id", 0},
 			{"payment_due", 1},
 			{"total_count", 1},
 			{"table_number", "$_id.table_number"},
@@ -155,6 +186,8 @@ func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
 		matchStage,
 		lookupStage,
 		unwindStage,
+		lookupComboStage,
+		unwindComboStage,
 		lookupOrderStage,
 		unwindOrderStage,
 		lookupTableStage,
@@ -165,11 +198,11 @@ func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	if err := result.All(ctx, &OrderItems); err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	return OrderItems, err
@@ -187,36 +220,215 @@ func CreateOrderItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order.OrderDate, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	for _, note := range orderItemPack.Notes {
+		if validationErr := validate.StructExcept(note, "TargetType", "TargetId"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// the links between combos and their foods, the ids, prices and menu versions are set by the server only
+	for i := range orderItemPack.OrderItems {
+		clearServerFields(&orderItemPack.OrderItems[i].OrderItem)
+	}
+
+	var comboPrices map[string]float64
+	var status int
+	var err error
+	orderItemPack.OrderItems, comboPrices, status, err = expandCombos(ctx, orderItemPack.OrderItems, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	foods := make([]models.Food, len(orderItemPack.OrderItems))
+	basePrices := make([]float64, len(orderItemPack.OrderItems))
+	modifierDeltas := make([]float64, len(orderItemPack.OrderItems))
 
-	orderItemsToBeInserted := []interface{}{}
-	order.TableId = orderItemPack.TableId
-	orderId := OrderItemOrderCreator(order)
+	for i, orderItem := range orderItemPack.OrderItems {
+		var food models.Food
 
-	for _, orderItem := range orderItemPack.OrderItems {
-		orderItem.OrderId = orderId
+		if orderItem.Count == 0 {
+			orderItem.Count = 1
+			orderItemPack.OrderItems[i].Count = 1
+		}
 
-		validationErr := validate.Struct(orderItem)
+		validationErr := validate.StructExcept(orderItem.OrderItem, "OrderId")
 		if validationErr != nil {
 			http.Error(w, validationErr.Error(), http.StatusBadRequest)
 			return
 		}
 
-		orderItem.ID = primitive.NewObjectID()
+		for _, note := range orderItem.Notes {
+			if validationErr := validate.StructExcept(note, "TargetType", "TargetId"); validationErr != nil {
+				http.Error(w, validationErr.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// a combo has no food of its own, the foods chosen for it follow as its children
+		if orderItem.ComboId != nil && orderItem.ParentItemId == nil {
+			basePrices[i] = comboPrices[orderItem.OrderItemId]
+			if orderItem.UnitPrice != nil && helpers.HasRole(r, models.RoleManager, models.RoleAdmin) {
+				uid := r.Header.Get("uid")
+				orderItemPack.OrderItems[i].PriceOverriddenBy = &uid
+				basePrices[i] = *orderItem.UnitPrice
+			}
+			continue
+		}
+
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": orderItem.FoodId}).Decode(&food); err != nil {
+			msg := fmt.Sprintf("food of order item %d was not found", i+1)
+			http.Error(w, msg, http.StatusNotFound)
+			return
+		}
+
+		// the price comes from the price history, whatever the client sent
+		if err := currentFoodPrice(ctx, &food, time.Now()); err != nil {
+			msg := fmt.Sprintf("price of %s was not found", *food.Name)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+
+		if err := helpers.FoodOrderable(food, orderItem.Count); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		menu, err := menuServed(ctx, food, time.Now())
+		if err != nil {
+			menuNotServed(w, err)
+			return
+		}
+		// the item remembers the menu version its price comes from
+		orderItemPack.OrderItems[i].MenuVersionId = menu.PublishedVersionId
+
+		foods[i] = food
+
+		variant, err := helpers.ResolveVariant(food, orderItem.Portion)
+		if err != nil {
+			msg := fmt.Sprintf("%s: %s", *food.Name, err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		modifiers, delta, err := helpers.ResolveModifiers(food.ModifierGroups, orderItem.Modifiers)
+		if err != nil {
+			msg := fmt.Sprintf("%s: %s", *food.Name, err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		orderItemPack.OrderItems[i].Modifiers = modifiers
+
+		if variant != nil {
+			orderItemPack.OrderItems[i].Portion = &variant.Name
+		}
+
+		// a submitted unit price is only taken from managers, as an override of the menu price
+		switch {
+		case orderItem.UnitPrice != nil && helpers.HasRole(r, models.RoleManager, models.RoleAdmin):
+			uid := r.Header.Get("uid")
+			orderItemPack.OrderItems[i].PriceOverriddenBy = &uid
+			basePrices[i] = *orderItem.UnitPrice
+		case variant != nil:
+			basePrices[i] = *variant.Price
+		default:
+			basePrices[i] = *food.Price
+		}
+		modifierDeltas[i] = delta
+	}
+
+	unitPrices := make([]float64, len(orderItemPack.OrderItems))
+	for i := range unitPrices {
+		unitPrices[i] = basePrices[i] + modifierDeltas[i]
+	}
+	allocateComboPrices(orderItemPack.OrderItems, unitPrices, modifierDeltas)
+
+	if err := reservePortions(ctx, orderItemPack.OrderItems, foods); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if orderItemPack.OrderId != nil {
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderItemPack.OrderId}).Decode(&order); err != nil {
+			releasePortions(ctx, orderItemPack.OrderItems, foods)
+			msg := fmt.Sprintf("message: Order was not found")
+			http.Error(w, msg, http.StatusNotFound)
+			return
+		}
+	} else {
+		order.OrderDate, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+		order.TableId = orderItemPack.TableId
+		order.OrderId = OrderItemOrderCreator(order)
+	}
+
+	warnings := allergyWarnings(ctx, order, orderItemPack, foods)
+
+	orderItemsToBeInserted := []interface{}{}
+	var orderItems []models.OrderItem
+
+	for i := range orderItemPack.OrderItems {
+		orderItem := &orderItemPack.OrderItems[i].OrderItem
+		orderItem.OrderId = order.OrderId
+
+		// combos already have their ids, their children point to them
+		if orderItem.ID.IsZero() {
+			orderItem.ID = primitive.NewObjectID()
+			orderItem.OrderItemId = orderItem.ID.Hex()
+		}
 		orderItem.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
 		orderItem.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
-		orderItem.OrderItemId = orderItem.ID.Hex()
-		var num = toFixed(*orderItem.UnitPrice, 2)
+		orderItem.Status = models.OrderItemStatusOrdered
+		// the stored unit price includes the price of the selected modifiers
+		var num = toFixed(unitPrices[i], 2)
 		orderItem.UnitPrice = &num
-		orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+		orderItemsToBeInserted = append(orderItemsToBeInserted, *orderItem)
+		orderItems = append(orderItems, *orderItem)
 	}
 
 	insertedOrderItems, err := orderItemCollection.InsertMany(ctx, orderItemsToBeInserted)
 	if err != nil {
-		log.Fatal(err)
+		// the items inserted before the failure are taken out again, so all the portions can be given back
+		ids := make([]string, len(orderItems))
+		for i, orderItem := range orderItems {
+			ids[i] = orderItem.OrderItemId
+		}
+		if _, deleteErr := orderItemCollection.DeleteMany(ctx, bson.M{"order_item_id": bson.M{"$in": ids}}); deleteErr != nil {
+			log.Printf("order items of order %s were partly created: %s", order.OrderId, deleteErr)
+		}
+		releasePortions(ctx, orderItemPack.OrderItems, foods)
+		msg := "Order items were not created"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
 	}
 
-	insertedOrderItemsJSON, err := json.Marshal(insertedOrderItems)
+	for _, note := range orderItemPack.Notes {
+		note.TargetType = models.NoteTargetOrder
+		note.TargetId = order.OrderId
+		if _, err := createNote(ctx, r, &note); err != nil {
+			log.Printf("note for order %s was not created: %s", order.OrderId, err)
+		}
+	}
+
+	for _, orderItem := range orderItemPack.OrderItems {
+		for _, note := range orderItem.Notes {
+			note.TargetType = models.NoteTargetOrderItem
+			note.TargetId = orderItem.OrderItemId
+			if _, err := createNote(ctx, r, &note); err != nil {
+				log.Printf("note for order item %s was not created: %s", orderItem.OrderItemId, err)
+			}
+		}
+	}
+
+	sendToKitchen(ctx, order, orderItems)
+	deductStock(ctx, orderItems, r.Header.Get("uid"))
+
+	response := struct {
+		*mongo.InsertManyResult
+		Warnings []string `json:"warnings,omitempty"`
+	}{insertedOrderItems, warnings}
+
+	insertedOrderItemsJSON, err := json.Marshal(response)
 	if err != nil {
 		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
 	}
@@ -225,6 +437,58 @@ func CreateOrderItem(w http.ResponseWriter, r *http.Request) {
 	w.Write(insertedOrderItemsJSON)
 }
 
+func menuNotServed(w http.ResponseWriter, err error) {
+	var notServed menuNotServedError
+	if errors.As(err, &notServed) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	msg := "error occurred while checking the menu of the food"
+	http.Error(w, msg, http.StatusInternalServerError)
+}
+
+// clearServerFields drops what a client must not set on a new order item. A plain item sent with a parent
+// would otherwise be billed as the food of a combo.
+func clearServerFields(orderItem *models.OrderItem) {
+	orderItem.ID = primitive.NilObjectID
+	orderItem.OrderItemId = ""
+	orderItem.ParentItemId = nil
+	orderItem.SlotId = nil
+	orderItem.AllocatedPrice = nil
+	orderItem.MenuVersionId = nil
+	orderItem.PriceOverriddenBy = nil
+	orderItem.VoidReason = nil
+	orderItem.VoidedBy = nil
+	orderItem.VoidedAt = nil
+}
+
+// allergyWarnings compares the allergens of the ordered foods with the allergy notes of the order, its table
+// and the item itself. Conflicts do not stop the order, the waiter is warned to check with the guest.
+func allergyWarnings(ctx context.Context, order models.Order, orderItemPack OrderItemPack, foods []models.Food) []string {
+	var warnings []string
+
+	orderNotes := append([]models.Note{}, orderItemPack.Notes...)
+	if stored, err := findNotes(ctx, models.NoteTargetOrder, []string{order.OrderId}); err == nil {
+		orderNotes = append(orderNotes, stored...)
+	}
+	if order.TableId != nil {
+		if stored, err := findNotes(ctx, models.NoteTargetTable, []string{*order.TableId}); err == nil {
+			orderNotes = append(orderNotes, stored...)
+		}
+	}
+	orderAllergens := helpers.NotedAllergens(orderNotes)
+
+	for i, orderItem := range orderItemPack.OrderItems {
+		noted := append(helpers.NotedAllergens(orderItem.Notes), orderAllergens...)
+		conflicts := helpers.AllergenConflicts(foods[i], noted)
+		if len(conflicts) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s contains %s, which conflicts with a noted allergy", *foods[i].Name, strings.Join(conflicts, ", ")))
+		}
+	}
+
+	return warnings
+}
+
 func UpdateOrderItem(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
@@ -241,42 +505,290 @@ func UpdateOrderItem(w http.ResponseWriter, r *http.Request) {
 
 	filter := bson.M{"order_item_id": orderItemID}
 
+	var current models.OrderItem
+	if err := orderItemCollection.FindOne(ctx, filter).Decode(&current); err != nil {
+		http.Error(w, "order item was not found", http.StatusNotFound)
+		return
+	}
+	if current.Status == models.OrderItemStatusVoid {
+		http.Error(w, "void order items cannot be changed", http.StatusConflict)
+		return
+	}
+	if current.ComboId != nil {
+		if orderItem.FoodId != nil || orderItem.Portion != nil || orderItem.Count != 0 || current.ParentItemId != nil {
+			http.Error(w, "combos cannot be changed, void the combo and order it again", http.StatusConflict)
+			return
+		}
+	}
+
 	var updateObj primitive.D
 
-	if orderItem.UnitPrice != nil {
-		updateObj = append(updateObj, bson.E{"unit_price", *&orderItem.UnitPrice})
+	if orderItem.UnitPrice != nil && !helpers.HasRole(r, models.RoleManager, models.RoleAdmin) {
+		http.Error(w, "only managers can override the unit price", http.StatusForbidden)
+		return
 	}
 
-	if orderItem.Quantity != nil {
-		updateObj = append(updateObj, bson.E{"quantity", *orderItem.Quantity})
+	if orderItem.Count != 0 {
+		if validationErr := validate.Var(orderItem.Count, "min=1,max=100"); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		updateObj = append(updateObj, bson.E{"count", orderItem.Count})
 	}
 
 	if orderItem.FoodId != nil {
 		updateObj = append(updateObj, bson.E{"food_id", *orderItem.FoodId})
 	}
 
-	orderItem.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
-	updateObj = append(updateObj, bson.E{"update_at", orderItem.UpdatedAt})
+	oldCount := current.Count
+	if oldCount == 0 {
+		oldCount = 1
+	}
+	newCount := oldCount
+	if orderItem.Count != 0 {
+		newCount = orderItem.Count
+	}
+	foodChanged := orderItem.FoodId != nil && (current.FoodId == nil || *orderItem.FoodId != *current.FoodId)
+
+	// the portions the item needs on top of what it already holds, all of them for another food
+	var food models.Food
+	reserved := newCount - oldCount
+	if foodChanged {
+		reserved = newCount
+	}
+
+	// a changed food, portion or count is priced again like a new item, the client price is ignored
+	if orderItem.FoodId != nil || orderItem.Portion != nil || orderItem.Count != 0 {
+		foodId := current.FoodId
+		if orderItem.FoodId != nil {
+			foodId = orderItem.FoodId
+		}
+		portion := current.Portion
+		if orderItem.Portion != nil {
+			portion = orderItem.Portion
+		}
+
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+			msg := fmt.Sprintf("food %s was not found", *foodId)
+			http.Error(w, msg, http.StatusNotFound)
+			return
+		}
+
+		if err := currentFoodPrice(ctx, &food, time.Now()); err != nil {
+			msg := fmt.Sprintf("price of %s was not found", *food.Name)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+
+		if foodChanged || reserved > 0 {
+			if err := helpers.FoodOrderable(food, reserved); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+		}
+
+		if foodChanged {
+			menu, err := menuServed(ctx, food, time.Now())
+			if err != nil {
+				menuNotServed(w, err)
+				return
+			}
+			// the item remembers the menu version its price comes from
+			updateObj = append(updateObj, bson.E{"menu_version_id", menu.PublishedVersionId})
+		}
+
+		variant, err := helpers.ResolveVariant(food, portion)
+		if err != nil {
+			msg := fmt.Sprintf("%s: %s", *food.Name, err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		modifiers, delta, err := helpers.ResolveModifiers(food.ModifierGroups, current.Modifiers)
+		if err != nil {
+			msg := fmt.Sprintf("%s: %s", *food.Name, err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		if variant != nil {
+			portion = &variant.Name
+		}
 
-	upsert := true
+		price := *food.Price
+		if variant != nil {
+			price = *variant.Price
+		}
+		unitPrice := toFixed(price+delta, 2)
+
+		updateObj = append(updateObj, bson.E{"portion", portion})
+		updateObj = append(updateObj, bson.E{"modifiers", modifiers})
+		if orderItem.UnitPrice == nil {
+			updateObj = append(updateObj, bson.E{"unit_price", unitPrice})
+			updateObj = append(updateObj, bson.E{"price_overridden_by", nil})
+		}
+	}
+
+	// a submitted unit price is only taken from managers, as an override of the menu price
+	if orderItem.UnitPrice != nil {
+		updateObj = append(updateObj, bson.E{"unit_price", *orderItem.UnitPrice})
+		updateObj = append(updateObj, bson.E{"price_overridden_by", r.Header.Get("uid")})
+	}
+
+	orderItem.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	updateObj = append(updateObj, bson.E{"updated_at", orderItem.UpdatedAt})
 
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+	reservation := []OrderItemRequest{{OrderItem: models.OrderItem{Count: reserved}}}
+	if reserved > 0 {
+		if err := reservePortions(ctx, reservation, []models.Food{food}); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 	}
 
+	// the status filter keeps a concurrently voided item from being changed
 	result, err := orderItemCollection.UpdateOne(
 		ctx,
-		filter,
+		bson.M{"order_item_id": orderItemID, "status": bson.M{"$ne": models.OrderItemStatusVoid}},
 		bson.D{
 			{"$set", updateObj},
 		},
-		&opt)
+	)
+	if err != nil || result.MatchedCount == 0 {
+		if reserved > 0 {
+			releasePortions(ctx, reservation, []models.Food{food})
+		}
+		if err == nil {
+			http.Error(w, "void order items cannot be changed", http.StatusConflict)
+			return
+		}
+		msg := "Order items update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	// the portions the item no longer holds are given back
+	switch {
+	case foodChanged && current.FoodId != nil:
+		releaseFoodPortions(ctx, *current.FoodId, oldCount)
+	case !foodChanged && reserved < 0 && current.FoodId != nil:
+		releaseFoodPortions(ctx, *current.FoodId, -reserved)
+	}
 
+	// the ingredients of the old item are given back and those of the changed item taken instead
+	if orderItem.FoodId != nil || orderItem.Count != 0 {
+		updated := current
+		if orderItem.FoodId != nil {
+			updated.FoodId = orderItem.FoodId
+		}
+		if orderItem.Count != 0 {
+			updated.Count = orderItem.Count
+		}
+
+		uid := r.Header.Get("uid")
+		if err := restoreStock(ctx, current.OrderItemId, uid); err != nil {
+			log.Printf("stock of order item %s was not restored: %s", current.OrderItemId, err)
+		}
+		deductStock(ctx, []models.OrderItem{updated}, uid)
+	}
+
+	resultJSON, err := json.Marshal(result)
 	if err != nil {
-		msg := "Order items update failed"
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+// VoidOrderItem takes an item off the order, e.g. when it was entered by mistake or sent back. The item is
+// kept for the audit trail, its ingredients and counted portions are given back. Voiding a combo voids its foods.
+func VoidOrderItem(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var request VoidRequest
+
+	vars := mux.Vars(r)
+	orderItemId := vars["order_item_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request.Reason = strings.TrimSpace(request.Reason)
+	if validationErr := validate.Struct(request); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var orderItem models.OrderItem
+	if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem); err != nil {
+		http.Error(w, "order item was not found", http.StatusNotFound)
+		return
+	}
+	if orderItem.ParentItemId != nil {
+		http.Error(w, "the foods of a combo are voided with the combo", http.StatusConflict)
+		return
+	}
+
+	uid := r.Header.Get("uid")
+	voidedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	update := bson.D{
+		{"$set", bson.D{
+			{"status", models.OrderItemStatusVoid},
+			{"void_reason", request.Reason},
+			{"voided_by", uid},
+			{"voided_at", voidedAt},
+			{"updated_at", voidedAt},
+		}},
+	}
+
+	// the status filter makes sure the stock is only given back once
+	result, err := orderItemCollection.UpdateOne(
+		ctx,
+		bson.M{"order_item_id": orderItemId, "status": bson.M{"$ne": models.OrderItemStatusVoid}},
+		update,
+	)
+	if err != nil {
+		msg := "order item void failed"
 		http.Error(w, msg, http.StatusInternalServerError)
 		return
 	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "order item is already void", http.StatusConflict)
+		return
+	}
+
+	voided := []models.OrderItem{orderItem}
+	if orderItem.ComboId != nil {
+		var children []models.OrderItem
+		cursor, err := orderItemCollection.Find(ctx, bson.M{"parent_item_id": orderItemId, "status": bson.M{"$ne": models.OrderItemStatusVoid}})
+		if err == nil {
+			err = cursor.All(ctx, &children)
+		}
+		if err == nil {
+			_, err = orderItemCollection.UpdateMany(ctx, bson.M{"parent_item_id": orderItemId, "status": bson.M{"$ne": models.OrderItemStatusVoid}}, update)
+		}
+		if err != nil {
+			log.Printf("foods of combo item %s were not voided: %s", orderItemId, err)
+		}
+		voided = append(voided, children...)
+	}
+
+	for _, item := range voided {
+		if err := restoreStock(ctx, item.OrderItemId, uid); err != nil {
+			log.Printf("stock of order item %s was not restored: %s", item.OrderItemId, err)
+		}
+		if item.FoodId != nil {
+			count := item.Count
+			if count == 0 {
+				count = 1
+			}
+			releaseFoodPortions(ctx, *item.FoodId, count)
+		}
+	}
 
 	resultJSON, err := json.Marshal(result)
 	if err != nil {
@@ -0,0 +1,536 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type VoidRequest struct {
+	Reason string `json:"reason" validate:"required,min=3"`
+}
+
+var errPaymentExceedsTotal = errors.New("payment exceeds the amount due on the invoice")
+var errRefundExceedsPayment = errors.New("refund exceeds the refundable amount of the payment")
+var errInvoiceVoid = errors.New("invoice is already void")
+
+var paymentCollection = database.OpenCollection(database.Client, "payment")
+var refundCollection = database.OpenCollection(database.Client, "refund")
+var creditNoteCollection = database.OpenCollection(database.Client, "creditNote")
+var invoiceEventCollection = database.OpenCollection(database.Client, "invoiceEvent")
+
+func GetInvoicePayments(w http.ResponseWriter, r *http.Request) {
+	listByInvoice(w, r, paymentCollection, "error occurred while listing payments")
+}
+
+func GetInvoiceCreditNotes(w http.ResponseWriter, r *http.Request) {
+	listByInvoice(w, r, creditNoteCollection, "error occurred while listing credit notes")
+}
+
+func GetInvoiceHistory(w http.ResponseWriter, r *http.Request) {
+	listByInvoice(w, r, invoiceEventCollection, "error occurred while listing the invoice history")
+}
+
+func CreatePayment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var payment models.Payment
+	var invoice models.Invoice
+
+	vars := mux.Vars(r)
+	invoiceId := vars["invoice_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	validationErr := validate.Struct(payment)
+	if validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		http.Error(w, "invoice was not found", http.StatusNotFound)
+		return
+	}
+
+	if *invoice.PaymentStatus != models.PaymentStatusPending {
+		msg := fmt.Sprintf("cannot take a payment for an invoice with status %s", *invoice.PaymentStatus)
+		http.Error(w, msg, http.StatusConflict)
+		return
+	}
+
+	payment, err := recordPayment(ctx, invoice, *payment.PaymentMethod, *payment.Amount, payment.Tip, r.Header.Get("uid"))
+	if err == errPaymentExceedsTotal {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		msg := "Payment was not recorded"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	paymentJSON, err := json.Marshal(payment)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(paymentJSON)
+}
+
+func CreateRefund(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var refund models.Refund
+	var invoice models.Invoice
+	var payment models.Payment
+
+	vars := mux.Vars(r)
+	invoiceId := vars["invoice_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&refund); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	validationErr := validate.Struct(refund)
+	if validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		http.Error(w, "invoice was not found", http.StatusNotFound)
+		return
+	}
+
+	if *invoice.PaymentStatus == models.PaymentStatusVoid {
+		http.Error(w, "cannot refund a void invoice", http.StatusConflict)
+		return
+	}
+
+	if err := paymentCollection.FindOne(ctx, bson.M{"payment_id": refund.PaymentId, "invoice_id": invoiceId}).Decode(&payment); err != nil {
+		http.Error(w, "payment was not found for this invoice", http.StatusNotFound)
+		return
+	}
+
+	// without an amount the rest of the payment is refunded
+	amount := helpers.RoundMoney(*payment.Amount - payment.RefundedAmount)
+	if refund.Amount != nil {
+		amount = helpers.RoundMoney(*refund.Amount)
+	}
+	if amount <= 0 {
+		http.Error(w, "payment has already been fully refunded", http.StatusConflict)
+		return
+	}
+
+	uid := r.Header.Get("uid")
+	refund.ID = primitive.NewObjectID()
+	refund.RefundId = refund.ID.Hex()
+	refund.Amount = &amount
+	refund.AuthorizedBy = uid
+	refund.InvoiceId = invoiceId
+	refund.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	session, err := database.Client.StartSession()
+	if err != nil {
+		msg := "error occurred while starting the refund transaction"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	defer session.EndSession(ctx)
+
+	// the payment, credit note, refund and invoice are written together, a failure leaves none of them changed
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		// the refunded amount is only increased while it stays within the payment, so concurrent refunds
+		// cannot give back more than was paid
+		err := paymentCollection.FindOneAndUpdate(
+			sessCtx,
+			bson.M{
+				"payment_id": payment.PaymentId,
+				"$expr":      bson.M{"$lte": bson.A{bson.M{"$add": bson.A{"$refunded_amount", amount}}, "$amount"}},
+			},
+			bson.D{{"$inc", bson.D{{"refunded_amount", amount}}}},
+		).Err()
+		if err == mongo.ErrNoDocuments {
+			return nil, errRefundExceedsPayment
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		creditNote, err := issueCreditNote(sessCtx, invoiceId, &refund.RefundId, amount, *refund.Reason, uid)
+		if err != nil {
+			return nil, err
+		}
+		refund.CreditNoteId = creditNote.CreditNoteId
+
+		if _, err := refundCollection.InsertOne(sessCtx, refund); err != nil {
+			return nil, err
+		}
+
+		// a refund on an invoice that is still being paid lowers what was paid, so the rest of the bill can
+		// be taken as usual; only paid invoices become refunded
+		pending := bson.D{{"$eq", bson.A{"$payment_status", models.PaymentStatusPending}}}
+		return invoiceCollection.UpdateOne(
+			sessCtx,
+			bson.M{"invoice_id": invoiceId},
+			mongo.Pipeline{
+				{{"$set", bson.D{
+					{"amount_paid", bson.D{{"$cond", bson.A{
+						pending,
+						bson.D{{"$round", bson.A{bson.D{{"$subtract", bson.A{"$amount_paid", amount}}}, 2}}},
+						"$amount_paid",
+					}}}},
+					{"amount_refunded", bson.D{{"$cond", bson.A{
+						pending,
+						"$amount_refunded",
+						bson.D{{"$round", bson.A{bson.D{{"$add", bson.A{"$amount_refunded", amount}}}, 2}}},
+					}}}},
+					{"updated_at", refund.CreatedAt},
+				}}},
+				{{"$set", bson.D{
+					{"payment_status", bson.D{{"$switch", bson.D{
+						{"branches", bson.A{
+							bson.D{{"case", pending}, {"then", models.PaymentStatusPending}},
+							bson.D{{"case", bson.D{{"$gte", bson.A{"$amount_refunded", "$amount_paid"}}}}, {"then", models.PaymentStatusRefunded}},
+						}},
+						{"default", models.PaymentStatusPartiallyRefunded},
+					}}}},
+				}}},
+			},
+		)
+	})
+	if err == errRefundExceedsPayment {
+		msg := fmt.Sprintf("refund of %.2f exceeds the refundable amount of the payment", amount)
+		http.Error(w, msg, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		msg := "Refund was not recorded"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	recordInvoiceEvent(ctx, invoiceId, models.InvoiceEventRefund, &amount, refund.Reason, uid)
+
+	refundJSON, err := json.Marshal(refund)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(refundJSON)
+}
+
+func VoidInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var request VoidRequest
+	var invoice models.Invoice
+
+	vars := mux.Vars(r)
+	invoiceId := vars["invoice_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request.Reason = strings.TrimSpace(request.Reason)
+	if validationErr := validate.Struct(request); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		http.Error(w, "invoice was not found", http.StatusNotFound)
+		return
+	}
+
+	if *invoice.PaymentStatus == models.PaymentStatusVoid {
+		http.Error(w, "invoice is already void", http.StatusConflict)
+		return
+	}
+
+	if helpers.RoundMoney(invoice.AmountPaid-invoice.AmountRefunded) > 0 {
+		http.Error(w, "invoice has payments that were not refunded, refund them before voiding", http.StatusConflict)
+		return
+	}
+
+	pricing, err := invoicePricing(ctx, invoice)
+	if err != nil {
+		msg := "error occurred while pricing the invoice order"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	uid := r.Header.Get("uid")
+	voidedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	session, err := database.Client.StartSession()
+	if err != nil {
+		msg := "error occurred while starting the void transaction"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	defer session.EndSession(ctx)
+
+	var amount float64
+	var creditNote *models.CreditNote
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		// the status filter makes sure only one of two concurrent voids succeeds
+		result, err := invoiceCollection.UpdateOne(
+			sessCtx,
+			bson.M{"invoice_id": invoiceId, "payment_status": bson.M{"$ne": models.PaymentStatusVoid}},
+			bson.D{
+				{"$set", append(invoiceTotals(pricing),
+					bson.E{"payment_status", models.PaymentStatusVoid},
+					bson.E{"void_reason", request.Reason},
+					bson.E{"voided_by", uid},
+					bson.E{"voided_at", voidedAt},
+					bson.E{"updated_at", voidedAt},
+				)},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if result.ModifiedCount == 0 {
+			return nil, errInvoiceVoid
+		}
+
+		// the refunds already issued a credit note for their part of the invoice, only the rest is credited
+		credited, err := creditedAmount(sessCtx, invoiceId)
+		if err != nil {
+			return nil, err
+		}
+		amount = helpers.RoundMoney(math.Max(pricing.Total-credited, 0))
+
+		// an invoice that was credited in full by its refunds is voided without another credit note
+		creditNote = nil
+		if amount > 0 {
+			issued, err := issueCreditNote(sessCtx, invoiceId, nil, amount, request.Reason, uid)
+			if err != nil {
+				return nil, err
+			}
+			creditNote = &issued
+		}
+		return nil, nil
+	})
+	if err == errInvoiceVoid {
+		http.Error(w, "invoice is already void", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		msg := "invoice void failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	recordInvoiceEvent(ctx, invoiceId, models.InvoiceEventVoid, &amount, &request.Reason, uid)
+
+	if creditNote == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	creditNoteJSON, err := json.Marshal(creditNote)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(creditNoteJSON)
+}
+
+// recordPayment stores a payment and marks the invoice PAID once the payments cover the order total. A payment
+// that would take the paid amount past the total is rejected with errPaymentExceedsTotal.
+func recordPayment(ctx context.Context, invoice models.Invoice, method string, amount float64, tip float64, uid string) (models.Payment, error) {
+	amount = helpers.RoundMoney(amount)
+	payment := models.Payment{
+		PaymentMethod: &method,
+		Amount:        &amount,
+		Tip:           helpers.RoundMoney(tip),
+		ReceivedBy:    uid,
+		InvoiceId:     invoice.InvoiceId,
+	}
+	payment.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	payment.ID = primitive.NewObjectID()
+	payment.PaymentId = payment.ID.Hex()
+
+	pricing, err := priceOrder(ctx, invoice.OrderId)
+	if err != nil {
+		return payment, err
+	}
+
+	session, err := database.Client.StartSession()
+	if err != nil {
+		return payment, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := paymentCollection.InsertOne(sessCtx, payment); err != nil {
+			return nil, err
+		}
+
+		// the paid amount is only increased while it stays within the total, so concurrent payments
+		// cannot pay more than is due
+		var updated models.Invoice
+		err := invoiceCollection.FindOneAndUpdate(
+			sessCtx,
+			bson.M{
+				"invoice_id": invoice.InvoiceId,
+				"$expr":      bson.M{"$lte": bson.A{bson.M{"$round": bson.A{bson.M{"$add": bson.A{"$amount_paid", amount}}, 2}}, pricing.Total}},
+			},
+			bson.D{
+				{"$inc", bson.D{{"amount_paid", amount}}},
+				{"$set", append(invoiceTotals(pricing),
+					bson.E{"payment_method", method},
+					bson.E{"updated_at", payment.CreatedAt},
+				)},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err == mongo.ErrNoDocuments {
+			return nil, errPaymentExceedsTotal
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if helpers.RoundMoney(updated.AmountPaid) >= pricing.Total {
+			return invoiceCollection.UpdateOne(
+				sessCtx,
+				bson.M{"invoice_id": invoice.InvoiceId, "payment_status": models.PaymentStatusPending},
+				bson.D{{"$set", bson.D{{"payment_status", models.PaymentStatusPaid}}}},
+			)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return payment, err
+	}
+
+	recordInvoiceEvent(ctx, invoice.InvoiceId, models.InvoiceEventPayment, &amount, nil, uid)
+
+	return payment, nil
+}
+
+// invoiceTotals sets the pricing snapshot of an invoice.
+func invoiceTotals(pricing helpers.OrderPricing) bson.D {
+	return bson.D{
+		{"subtotal", pricing.Subtotal},
+		{"discounts", pricing.Discounts},
+		{"discount_total", pricing.DiscountTotal},
+		{"tax_rate", pricing.TaxRate},
+		{"tax", pricing.Tax},
+		{"total", pricing.Total},
+	}
+}
+
+// creditedAmount is the sum of the credit notes issued against an invoice.
+func creditedAmount(ctx context.Context, invoiceId string) (float64, error) {
+	var totals []struct {
+		Amount float64 `bson:"amount"`
+	}
+
+	cursor, err := creditNoteCollection.Aggregate(ctx, mongo.Pipeline{
+		{{"$match", bson.D{{"invoice_id", invoiceId}}}},
+		{{"$group", bson.D{{"_id", nil}, {"amount", bson.D{{"$sum", "$amount"}}}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err = cursor.All(ctx, &totals); err != nil {
+		return 0, err
+	}
+	if len(totals) == 0 {
+		return 0, nil
+	}
+	return helpers.RoundMoney(totals[0].Amount), nil
+}
+
+func issueCreditNote(ctx context.Context, invoiceId string, refundId *string, amount float64, reason string, uid string) (models.CreditNote, error) {
+	creditNote := models.CreditNote{
+		Amount:    helpers.RoundMoney(amount),
+		Reason:    reason,
+		IssuedBy:  uid,
+		InvoiceId: invoiceId,
+		RefundId:  refundId,
+	}
+	creditNote.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	creditNote.ID = primitive.NewObjectID()
+	creditNote.CreditNoteId = creditNote.ID.Hex()
+
+	_, err := creditNoteCollection.InsertOne(ctx, creditNote)
+	return creditNote, err
+}
+
+// recordInvoiceEvent appends to the audit trail of an invoice. A failure is only logged so that it never
+// undoes the operation being audited.
+func recordInvoiceEvent(ctx context.Context, invoiceId string, action string, amount *float64, reason *string, uid string) {
+	event := models.InvoiceEvent{
+		Action:    action,
+		Amount:    amount,
+		Reason:    reason,
+		UserId:    uid,
+		InvoiceId: invoiceId,
+	}
+	event.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	event.ID = primitive.NewObjectID()
+	event.EventId = event.ID.Hex()
+
+	if _, err := invoiceEventCollection.InsertOne(ctx, event); err != nil {
+		log.Printf("failed to record %s event for invoice %s: %s", action, invoiceId, err)
+	}
+}
+
+func listByInvoice(w http.ResponseWriter, r *http.Request, collection *mongo.Collection, errMsg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	invoiceId := vars["invoice_id"]
+
+	result, err := collection.Find(ctx, bson.M{"invoice_id": invoiceId}, options.Find().SetSort(bson.D{{"created_at", 1}}))
+	if err != nil {
+		http.Error(w, errMsg, http.StatusInternalServerError)
+		return
+	}
+
+	var allDocuments []bson.M
+	if err = result.All(ctx, &allDocuments); err != nil {
+		log.Fatal(err)
+	}
+
+	allDocumentsJSON, err := json.Marshal(allDocuments)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(allDocumentsJSON)
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"github.com/menyasosali/restaurant-manage-backend-go/printing"
+	"go.mongodb.org/mongo-driver/bson"
+	"log"
+	"net/http"
+	"time"
+)
+
+var printRouter = printing.NewRouterFromEnv()
+
+// sendToKitchen prints a ticket for every station that has to prepare some of the order items.
+// Printing happens in the background, failures are logged and never fail the order.
+func sendToKitchen(ctx context.Context, order models.Order, orderItems []models.OrderItem) {
+	if !printRouter.Enabled() || len(orderItems) == 0 {
+		return
+	}
+
+	var table models.Table
+	tableNumber := ""
+	if order.TableId != nil {
+		if err := tableCollection.FindOne(ctx, bson.M{"table_id": order.TableId}).Decode(&table); err == nil && table.TableNumber != nil {
+			tableNumber = fmt.Sprint(*table.TableNumber)
+		}
+	}
+
+	var orderNotes []string
+	notes, err := findNotes(ctx, models.NoteTargetOrder, []string{order.OrderId})
+	if err != nil {
+		log.Printf("kitchen ticket: notes of order %s were not loaded: %s", order.OrderId, err)
+	}
+	for _, note := range notes {
+		if note.Kind != models.NoteKindGeneral || note.Pinned {
+			orderNotes = append(orderNotes, ticketNote(note))
+		}
+	}
+
+	orderItemIds := make([]string, 0, len(orderItems))
+	for _, orderItem := range orderItems {
+		orderItemIds = append(orderItemIds, orderItem.OrderItemId)
+	}
+	itemNotes := map[string][]string{}
+	notes, err = findNotes(ctx, models.NoteTargetOrderItem, orderItemIds)
+	if err != nil {
+		log.Printf("kitchen ticket: notes of order %s items were not loaded: %s", order.OrderId, err)
+	}
+	for _, note := range notes {
+		itemNotes[note.TargetId] = append(itemNotes[note.TargetId], ticketNote(note))
+	}
+
+	categories, err := loadCategories(ctx)
+	if err != nil {
+		log.Printf("kitchen ticket: categories were not loaded: %s", err)
+	}
+
+	tickets := map[string]*printing.KitchenTicket{}
+	var stations []string
+
+	for _, orderItem := range orderItems {
+		var food models.Food
+		var menu models.Menu
+
+		if orderItem.FoodId == nil {
+			continue
+		}
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": orderItem.FoodId}).Decode(&food); err != nil {
+			log.Printf("kitchen ticket: food %s was not found: %s", *orderItem.FoodId, err)
+			continue
+		}
+		if food.MenuId != nil {
+			menuCollection.FindOne(ctx, bson.M{"menu_id": food.MenuId}).Decode(&menu)
+		}
+
+		station := kitchenStation(categories, food, menu)
+		ticket, ok := tickets[station]
+		if !ok {
+			ticket = &printing.KitchenTicket{
+				Station:     station,
+				OrderId:     order.OrderId,
+				TableNumber: tableNumber,
+				CreatedAt:   time.Now(),
+				Notes:       orderNotes,
+			}
+			tickets[station] = ticket
+			stations = append(stations, station)
+		}
+
+		item := printing.TicketItem{Count: orderItem.Count, Notes: itemNotes[orderItem.OrderItemId]}
+		if food.Name != nil {
+			item.Name = *food.Name
+		}
+		if orderItem.Portion != nil {
+			item.Portion = *orderItem.Portion
+		}
+		for _, modifier := range orderItem.Modifiers {
+			item.Modifiers = append(item.Modifiers, helpers.ModifierLabel(modifier))
+		}
+		ticket.Items = append(ticket.Items, item)
+	}
+
+	for _, station := range stations {
+		if err := printRouter.Print(station, tickets[station].ESCPOS()); err != nil {
+			log.Printf("kitchen ticket for order %s not printed on %s: %s", order.OrderId, station, err)
+		}
+	}
+}
+
+// kitchenStation picks the station of the nearest category of the food that names one. Otherwise the
+// category names, from the food category up, and then the menu category are looked up in PRINTER_CATEGORIES.
+func kitchenStation(categories map[string]models.Category, food models.Food, menu models.Menu) string {
+	var path []models.Category
+	if food.CategoryId != nil {
+		path = helpers.CategoryPath(categories, *food.CategoryId)
+	}
+	if station := helpers.CategoryStation(path); station != "" {
+		return station
+	}
+	for _, category := range path {
+		if category.Name == nil {
+			continue
+		}
+		if station, ok := printRouter.CategoryStation(*category.Name); ok {
+			return station
+		}
+	}
+	return printRouter.StationFor(menu.Category)
+}
+
+func ticketNote(note models.Note) string {
+	text := note.Text
+	if note.Title != "" {
+		text = note.Title + ": " + text
+	}
+	if note.Kind == models.NoteKindAllergy {
+		text = "ALLERGY " + text
+	}
+	return text
+}
+
+func PrintInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	invoiceId := vars["invoice_id"]
+
+	station := r.URL.Query().Get("station")
+	if station == "" {
+		station = "receipt"
+	}
+
+	var invoice models.Invoice
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		http.Error(w, "invoice was not found", http.StatusNotFound)
+		return
+	}
+
+	pricing, err := invoicePricing(ctx, invoice)
+	if err != nil {
+		msg := "error occurred while pricing the invoice order"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	receipt, err := invoiceReceipt(ctx, invoice, pricing)
+	if err != nil {
+		msg := "error occurred while preparing the receipt"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if err := printRouter.Print(station, printing.Receipt(helpers.RenderReceiptText(receipt, printing.TicketWidth))); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	resultJSON, err := json.Marshal(map[string]string{"status": "queued", "station": station})
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(resultJSON)
+}
@@ -0,0 +1,699 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type PromoCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+var promotionCollection = database.OpenCollection(database.Client, "promotion")
+var discountCollection = database.OpenCollection(database.Client, "discount")
+
+func GetPromotions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	result, err := promotionCollection.Find(ctx, bson.M{})
+	if err != nil {
+		msg := "error occurred while listing promotions"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	var allPromotions []bson.M
+	if err = result.All(ctx, &allPromotions); err != nil {
+		log.Fatal(err)
+	}
+
+	allPromotionsJSON, err := json.Marshal(allPromotions)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(allPromotionsJSON)
+}
+
+func GetPromotion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	promotionId := vars["promotion_id"]
+
+	var promotion models.Promotion
+	if err := promotionCollection.FindOne(ctx, bson.M{"promotion_id": promotionId}).Decode(&promotion); err != nil {
+		msg := "error occurred while fetching the promotion"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	promotionJSON, err := json.Marshal(promotion)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(promotionJSON)
+}
+
+func CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var promotion models.Promotion
+
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validatePromotion(promotion); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if promotion.Code != nil {
+		code := strings.ToUpper(strings.TrimSpace(*promotion.Code))
+		promotion.Code = &code
+
+		count, err := promotionCollection.CountDocuments(ctx, bson.M{"code": code})
+		if err != nil {
+			msg := "error occurred while checking for the promo code"
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		if count > 0 {
+			msg := fmt.Sprintf("promo code %s already exists", code)
+			http.Error(w, msg, http.StatusConflict)
+			return
+		}
+	}
+
+	active := true
+	if promotion.Active == nil {
+		promotion.Active = &active
+	}
+
+	promotion.UsageCount = 0
+	promotion.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	promotion.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	promotion.ID = primitive.NewObjectID()
+	promotion.PromotionId = promotion.ID.Hex()
+
+	result, insertErr := promotionCollection.InsertOne(ctx, promotion)
+	if insertErr != nil {
+		msg := fmt.Sprintf("Promotion was not created")
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+func UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var promotion models.Promotion
+
+	vars := mux.Vars(r)
+	promotionId := vars["promotion_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var found models.Promotion
+	if err := promotionCollection.FindOne(ctx, bson.M{"promotion_id": promotionId}).Decode(&found); err != nil {
+		http.Error(w, "promotion was not found", http.StatusNotFound)
+		return
+	}
+
+	// the changes are checked on the updated promotion, the same way a new one is
+	var updateObj primitive.D
+
+	if promotion.Name != nil {
+		found.Name = promotion.Name
+		updateObj = append(updateObj, bson.E{"name", promotion.Name})
+	}
+
+	if promotion.Value != nil {
+		found.Value = promotion.Value
+		updateObj = append(updateObj, bson.E{"value", promotion.Value})
+	}
+
+	if promotion.Active != nil {
+		found.Active = promotion.Active
+		updateObj = append(updateObj, bson.E{"active", promotion.Active})
+	}
+
+	if promotion.UsageLimit != nil {
+		found.UsageLimit = promotion.UsageLimit
+		updateObj = append(updateObj, bson.E{"usage_limit", promotion.UsageLimit})
+	}
+
+	if promotion.ValidFrom != nil {
+		found.ValidFrom = promotion.ValidFrom
+		updateObj = append(updateObj, bson.E{"valid_from", promotion.ValidFrom})
+	}
+
+	if promotion.ValidUntil != nil {
+		found.ValidUntil = promotion.ValidUntil
+		updateObj = append(updateObj, bson.E{"valid_until", promotion.ValidUntil})
+	}
+
+	if promotion.FoodIds != nil {
+		found.FoodIds = promotion.FoodIds
+		updateObj = append(updateObj, bson.E{"food_ids", promotion.FoodIds})
+	}
+
+	if promotion.Categories != nil {
+		found.Categories = promotion.Categories
+		updateObj = append(updateObj, bson.E{"categories", promotion.Categories})
+	}
+
+	if promotion.HappyHours != nil {
+		found.HappyHours = promotion.HappyHours
+		updateObj = append(updateObj, bson.E{"happy_hours", promotion.HappyHours})
+	}
+
+	if err := validatePromotion(found); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	promotion.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	updateObj = append(updateObj, bson.E{"updated_at", promotion.UpdatedAt})
+
+	result, err := promotionCollection.UpdateOne(
+		ctx,
+		bson.M{"promotion_id": promotionId},
+		bson.D{
+			{"$set", updateObj},
+		},
+	)
+	if err != nil {
+		msg := "Promotion update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		http.Error(w, "promotion was not found", http.StatusNotFound)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+// validatePromotion checks a new promotion, or a stored one with the changes of an update applied.
+func validatePromotion(promotion models.Promotion) error {
+	if err := validate.Struct(promotion); err != nil {
+		return err
+	}
+	if *promotion.DiscountType == "PERCENTAGE" && *promotion.Value > 100 {
+		return fmt.Errorf("percentage discount cannot be greater than 100")
+	}
+	return helpers.ValidateHappyHours(promotion.HappyHours)
+}
+
+func ApplyPromoCode(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var request PromoCodeRequest
+	var order models.Order
+	var promotion models.Promotion
+
+	vars := mux.Vars(r)
+	orderId := vars["order_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if validationErr := validate.Struct(request); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		msg := fmt.Sprintf("message: Order was not found")
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+
+	if settled, err := invoiceSettled(ctx, orderId); err != nil || settled {
+		discountsLocked(w, err)
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(request.Code))
+	if err := promotionCollection.FindOne(ctx, bson.M{"code": code}).Decode(&promotion); err != nil {
+		msg := fmt.Sprintf("promo code %s is not valid", code)
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+
+	if !helpers.PromotionActive(promotion, time.Now()) {
+		msg := fmt.Sprintf("promo code %s is not valid at this time", code)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	count, err := discountCollection.CountDocuments(ctx, bson.M{"order_id": orderId, "promotion_id": promotion.PromotionId})
+	if err != nil {
+		msg := "error occurred while checking the order discounts"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if count > 0 {
+		msg := fmt.Sprintf("promo code %s is already applied to this order", code)
+		http.Error(w, msg, http.StatusConflict)
+		return
+	}
+
+	// the usage counter is only incremented while it is below the limit, so concurrent redemptions
+	// cannot exceed it
+	err = promotionCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"promotion_id": promotion.PromotionId,
+			"$or": bson.A{
+				bson.M{"usage_limit": nil},
+				bson.M{"$expr": bson.M{"$lt": bson.A{"$usage_count", "$usage_limit"}}},
+			},
+		},
+		bson.D{{"$inc", bson.D{{"usage_count", 1}}}},
+	).Err()
+	if err == mongo.ErrNoDocuments {
+		msg := fmt.Sprintf("promo code %s has reached its usage limit", code)
+		http.Error(w, msg, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		msg := "error occurred while redeeming the promo code"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	discount := models.Discount{
+		Source:       models.DiscountSourcePromoCode,
+		DiscountType: promotion.DiscountType,
+		Value:        promotion.Value,
+		PromotionId:  &promotion.PromotionId,
+		Code:         &code,
+		OrderId:      orderId,
+	}
+	discount.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	discount.ID = primitive.NewObjectID()
+	discount.DiscountId = discount.ID.Hex()
+
+	result, insertErr := discountCollection.InsertOne(ctx, discount)
+	if insertErr != nil {
+		// the redemption is given back, the unique index on the order and promotion catches concurrent applies
+		_, err := promotionCollection.UpdateOne(
+			ctx,
+			bson.M{"promotion_id": promotion.PromotionId, "usage_count": bson.M{"$gt": 0}},
+			bson.D{{"$inc", bson.D{{"usage_count", -1}}}},
+		)
+		if err != nil {
+			log.Printf("failed to release promo code usage for promotion %s: %s", promotion.PromotionId, err)
+		}
+
+		if mongo.IsDuplicateKeyError(insertErr) {
+			msg := fmt.Sprintf("promo code %s is already applied to this order", code)
+			http.Error(w, msg, http.StatusConflict)
+			return
+		}
+		msg := fmt.Sprintf("Discount was not created")
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+func CreateManualDiscount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var discount models.Discount
+	var order models.Order
+
+	vars := mux.Vars(r)
+	orderId := vars["order_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&discount); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	discount.OrderId = orderId
+	discount.Source = models.DiscountSourceManual
+	discount.PromotionId = nil
+	discount.Code = nil
+
+	validationErr := validate.Struct(discount)
+	if validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if discount.Reason == nil || strings.TrimSpace(*discount.Reason) == "" {
+		http.Error(w, "a reason is required for manual discounts", http.StatusBadRequest)
+		return
+	}
+
+	if *discount.DiscountType == "PERCENTAGE" && *discount.Value > 100 {
+		http.Error(w, "percentage discount cannot be greater than 100", http.StatusBadRequest)
+		return
+	}
+
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		msg := fmt.Sprintf("message: Order was not found")
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+
+	if settled, err := invoiceSettled(ctx, orderId); err != nil || settled {
+		discountsLocked(w, err)
+		return
+	}
+
+	if discount.OrderItemId != nil {
+		count, err := orderItemCollection.CountDocuments(ctx, bson.M{"order_item_id": discount.OrderItemId, "order_id": orderId})
+		if err != nil || count == 0 {
+			msg := "order item was not found in this order"
+			http.Error(w, msg, http.StatusNotFound)
+			return
+		}
+	}
+
+	approvedBy := r.Header.Get("uid")
+	discount.ApprovedBy = &approvedBy
+	discount.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	discount.ID = primitive.NewObjectID()
+	discount.DiscountId = discount.ID.Hex()
+
+	result, insertErr := discountCollection.InsertOne(ctx, discount)
+	if insertErr != nil {
+		msg := fmt.Sprintf("Discount was not created")
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+func GetOrderDiscounts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	orderId := vars["order_id"]
+
+	pricing, err := priceOrder(ctx, orderId)
+	if err != nil {
+		msg := "error occurred while pricing the order"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	pricingJSON, err := json.Marshal(pricing)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(pricingJSON)
+}
+
+func DeleteDiscount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	discountId := vars["discount_id"]
+
+	var discount models.Discount
+	if err := discountCollection.FindOne(ctx, bson.M{"discount_id": discountId}).Decode(&discount); err != nil {
+		http.Error(w, "discount was not found", http.StatusNotFound)
+		return
+	}
+
+	if settled, err := invoiceSettled(ctx, discount.OrderId); err != nil || settled {
+		discountsLocked(w, err)
+		return
+	}
+
+	if err := discountCollection.FindOneAndDelete(ctx, bson.M{"discount_id": discountId}).Decode(&discount); err != nil {
+		http.Error(w, "discount was not found", http.StatusNotFound)
+		return
+	}
+
+	// give the redemption back so the code can be used again
+	if discount.PromotionId != nil {
+		_, err := promotionCollection.UpdateOne(
+			ctx,
+			bson.M{"promotion_id": discount.PromotionId, "usage_count": bson.M{"$gt": 0}},
+			bson.D{{"$inc", bson.D{{"usage_count", -1}}}},
+		)
+		if err != nil {
+			log.Printf("failed to release promo code usage for promotion %s: %s", *discount.PromotionId, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// invoiceSettled tells whether the order was billed on an invoice that is no longer pending, its discounts
+// are part of the invoice then and cannot change.
+func invoiceSettled(ctx context.Context, orderId string) (bool, error) {
+	count, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": orderId, "payment_status": bson.M{"$ne": models.PaymentStatusPending}})
+	return count > 0, err
+}
+
+func discountsLocked(w http.ResponseWriter, err error) {
+	if err != nil {
+		msg := "error occurred while checking the order invoice"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, "the discounts of an order cannot change once its invoice is paid or void", http.StatusConflict)
+}
+
+// orderLines loads the billable lines of an order together with the food name and categories the
+// promotion rules match against. The foods of a combo are components of the combo line.
+func orderLines(ctx context.Context, orderId string) ([]helpers.PricedLine, error) {
+	var orderItems []models.OrderItem
+
+	cursor, err := orderItemCollection.Find(ctx, bson.M{"order_id": orderId, "status": bson.M{"$ne": models.OrderItemStatusVoid}})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &orderItems); err != nil {
+		return nil, err
+	}
+
+	foodCategories, err := loadCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	foods := map[string]models.Food{}
+	categories := map[string]string{}
+	combos := map[string]models.Combo{}
+	components := map[string][]helpers.PricedComponent{}
+	lines := make([]helpers.PricedLine, 0, len(orderItems))
+
+	for _, orderItem := range orderItems {
+		line := helpers.PricedLine{
+			OrderItemId: orderItem.OrderItemId,
+			Count:       orderItem.Count,
+			OrderedAt:   orderItem.CreatedAt,
+		}
+		if line.Count == 0 {
+			line.Count = 1
+		}
+		if orderItem.Portion != nil {
+			line.Portion = *orderItem.Portion
+		}
+		if orderItem.UnitPrice != nil {
+			line.UnitPrice = *orderItem.UnitPrice
+		}
+		for _, modifier := range orderItem.Modifiers {
+			line.Modifiers = append(line.Modifiers, helpers.ModifierLabel(modifier))
+		}
+
+		if orderItem.FoodId != nil {
+			line.FoodId = *orderItem.FoodId
+
+			food, ok := foods[line.FoodId]
+			if !ok {
+				if err := foodCollection.FindOne(ctx, bson.M{"food_id": line.FoodId}).Decode(&food); err != nil && err != mongo.ErrNoDocuments {
+					return nil, err
+				}
+				foods[line.FoodId] = food
+			}
+
+			if food.Name != nil {
+				line.FoodName = *food.Name
+			}
+
+			if food.MenuId != nil {
+				category, ok := categories[*food.MenuId]
+				if !ok {
+					var menu models.Menu
+					if err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.MenuId}).Decode(&menu); err != nil && err != mongo.ErrNoDocuments {
+						return nil, err
+					}
+					category = menu.Category
+					categories[*food.MenuId] = category
+				}
+				line.Category = category
+			}
+
+			if food.CategoryId != nil {
+				if line.Category != "" {
+					line.Categories = append(line.Categories, line.Category)
+				}
+				for i, category := range helpers.CategoryPath(foodCategories, *food.CategoryId) {
+					line.Categories = append(line.Categories, category.CategoryId)
+					if category.Name != nil {
+						line.Categories = append(line.Categories, *category.Name)
+						if i == 0 {
+							line.Category = *category.Name
+						}
+					}
+				}
+			}
+		}
+
+		if orderItem.ParentItemId != nil {
+			component := helpers.PricedComponent{
+				OrderItemId: line.OrderItemId,
+				FoodId:      line.FoodId,
+				FoodName:    line.FoodName,
+				Portion:     line.Portion,
+				Modifiers:   line.Modifiers,
+			}
+			if orderItem.AllocatedPrice != nil {
+				component.Allocated = *orderItem.AllocatedPrice
+			}
+			components[*orderItem.ParentItemId] = append(components[*orderItem.ParentItemId], component)
+			continue
+		}
+
+		if orderItem.ComboId != nil {
+			line.ComboId = *orderItem.ComboId
+
+			combo, ok := combos[line.ComboId]
+			if !ok {
+				if err := comboCollection.FindOne(ctx, bson.M{"combo_id": line.ComboId}).Decode(&combo); err != nil && err != mongo.ErrNoDocuments {
+					return nil, err
+				}
+				combos[line.ComboId] = combo
+			}
+
+			if combo.Name != nil {
+				line.FoodName = *combo.Name
+			}
+			if combo.CategoryId != nil {
+				for _, category := range helpers.CategoryPath(foodCategories, *combo.CategoryId) {
+					line.Categories = append(line.Categories, category.CategoryId)
+					if category.Name != nil {
+						line.Categories = append(line.Categories, *category.Name)
+					}
+				}
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	for i := range lines {
+		lines[i].Components = components[lines[i].OrderItemId]
+	}
+
+	return lines, nil
+}
+
+// priceOrder computes the totals, itemized discounts and tax of an order.
+func priceOrder(ctx context.Context, orderId string) (helpers.OrderPricing, error) {
+	var order models.Order
+	var promotions []models.Promotion
+	var discounts []models.Discount
+
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return helpers.OrderPricing{}, err
+	}
+
+	lines, err := orderLines(ctx, orderId)
+	if err != nil {
+		return helpers.OrderPricing{}, err
+	}
+
+	cursor, err := promotionCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return helpers.OrderPricing{}, err
+	}
+	if err = cursor.All(ctx, &promotions); err != nil {
+		return helpers.OrderPricing{}, err
+	}
+
+	cursor, err = discountCollection.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return helpers.OrderPricing{}, err
+	}
+	if err = cursor.All(ctx, &discounts); err != nil {
+		return helpers.OrderPricing{}, err
+	}
+
+	pricing := helpers.PriceOrder(lines, promotions, discounts, order.OrderDate)
+	helpers.ApplyTax(&pricing, helpers.TAX_RATE)
+
+	return pricing, nil
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"github.com/menyasosali/restaurant-manage-backend-go/reporting"
+	"go.mongodb.org/mongo-driver/bson"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetXReport shows the sales of the running period since the last Z report without closing it.
+func GetXReport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	location, err := helpers.RestaurantLocation()
+	if err != nil {
+		http.Error(w, "RESTAURANT_TIMEZONE is not a valid timezone", http.StatusInternalServerError)
+		return
+	}
+
+	at, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	start, err := reporting.ShiftStart(ctx, at, location)
+	if err == nil {
+		err = refreshInvoiceTotals(ctx, start, at)
+	}
+	var report models.SalesReport
+	if err == nil {
+		report, err = reporting.Sales(ctx, models.ReportTypeX, start, at)
+	}
+	if err != nil {
+		msg := "error occurred while computing the X report"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	report.GeneratedBy = r.Header.Get("uid")
+	report.GeneratedAt = at
+
+	writeReport(w, report)
+}
+
+// CloseZReport closes the day: the sales since the last Z report are stored as the next Z report.
+func CloseZReport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	location, err := helpers.RestaurantLocation()
+	if err != nil {
+		http.Error(w, "RESTAURANT_TIMEZONE is not a valid timezone", http.StatusInternalServerError)
+		return
+	}
+
+	at, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	start, err := reporting.ShiftStart(ctx, at, location)
+	if err == nil {
+		err = refreshInvoiceTotals(ctx, start, at)
+	}
+	var report models.SalesReport
+	if err == nil {
+		report, err = reporting.CloseDay(ctx, at, location, r.Header.Get("uid"))
+	}
+	if err != nil {
+		msg := "Z report was not created"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	writeReport(w, report)
+}
+
+func GetZReports(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	reports, err := reporting.ZReports(ctx)
+	if err != nil {
+		msg := "error occurred while listing the Z reports"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	reportsJSON, err := json.Marshal(reports)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(reportsJSON)
+}
+
+func GetZReport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	vars := mux.Vars(r)
+	reportId := vars["report_id"]
+
+	report, err := reporting.ZReport(ctx, reportId)
+	if err != nil {
+		http.Error(w, "Z report was not found", http.StatusNotFound)
+		return
+	}
+
+	writeReport(w, report)
+}
+
+// GetSalesSummary reports the days from ?from= to ?to=, both YYYY-MM-DD and included, with the sales of every
+// day. Both default to today.
+func GetSalesSummary(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	location, err := helpers.RestaurantLocation()
+	if err != nil {
+		http.Error(w, "RESTAURANT_TIMEZONE is not a valid timezone", http.StatusInternalServerError)
+		return
+	}
+
+	first, last, err := reportDays(r, location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = refreshInvoiceTotals(ctx, first, last.AddDate(0, 0, 1))
+	var report models.SalesReport
+	if err == nil {
+		report, err = reporting.Summary(ctx, first, last, location)
+	}
+	if err != nil {
+		msg := "error occurred while computing the sales summary"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	report.GeneratedBy = r.Header.Get("uid")
+	report.GeneratedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	writeReport(w, report)
+}
+
+// reportDays reads the ?from= and ?to= days of a report in the restaurant timezone, at most a year apart.
+func reportDays(r *http.Request, location *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	first, last := today, today
+
+	if value := r.FormValue("from"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, location)
+		if err != nil {
+			return first, last, fmt.Errorf("from must be a YYYY-MM-DD date")
+		}
+		first = day
+	}
+	if value := r.FormValue("to"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, location)
+		if err != nil {
+			return first, last, fmt.Errorf("to must be a YYYY-MM-DD date")
+		}
+		last = day
+	}
+
+	if last.Before(first) {
+		return first, last, fmt.Errorf("to must not be before from")
+	}
+	if last.After(first.AddDate(1, 0, 0)) {
+		return first, last, fmt.Errorf("a summary covers at most a year")
+	}
+	return first, last, nil
+}
+
+// refreshInvoiceTotals reprices the invoices of the period that are still open or were created before invoices
+// kept their totals, so the reports add up current figures.
+func refreshInvoiceTotals(ctx context.Context, start time.Time, end time.Time) error {
+	_, err := RecomputeInvoiceTotals(ctx, start, end, false)
+	return err
+}
+
+// RecomputeInvoiceTotals reprices the open invoices of the period and those without totals, with all it
+// reprices every invoice that was not voided. It returns the number of invoices repriced.
+func RecomputeInvoiceTotals(ctx context.Context, start time.Time, end time.Time, all bool) (int, error) {
+	var invoices []models.Invoice
+
+	filter := bson.M{"created_at": bson.M{"$gte": start, "$lt": end}}
+	if all {
+		filter["voided_at"] = nil
+	} else {
+		filter["$or"] = bson.A{
+			bson.M{"payment_status": models.PaymentStatusPending},
+			bson.M{"total": bson.M{"$exists": false}},
+		}
+	}
+
+	cursor, err := invoiceCollection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return 0, err
+	}
+
+	for i, invoice := range invoices {
+		pricing, err := priceOrder(ctx, invoice.OrderId)
+		if err != nil {
+			return i, err
+		}
+		_, err = invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.InvoiceId}, bson.D{{"$set", invoiceTotals(pricing)}})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(invoices), nil
+}
+
+func writeReport(w http.ResponseWriter, report models.SalesReport) {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(reportJSON)
+}
@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchHit is a food, category or menu found by a search, only the field of its type is set.
+type SearchHit struct {
+	Type     string           `json:"type"`
+	Id       string           `json:"id"`
+	Name     string           `json:"name"`
+	Score    float64          `json:"score"`
+	Food     *models.Food     `json:"food,omitempty"`
+	Category *models.Category `json:"category,omitempty"`
+	Menu     *models.Menu     `json:"menu,omitempty"`
+}
+
+type SearchPage struct {
+	Query      string      `json:"query"`
+	TotalCount int         `json:"total_count"`
+	Results    []SearchHit `json:"results"`
+}
+
+// Search finds foods by name, description, category and menu, and the categories and menus themselves.
+// The text indexes rank whole words in their stemmed forms, the foods, categories and menus are few enough
+// to also be ranked in memory for prefixes, typos and translations. Foods are filtered like GET /foods,
+// ?available=true only keeps foods that can be ordered right now and ?menu_id= the foods of one menu.
+// Categories and menus are only found when they hold a food that passes the filters.
+func Search(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	query := strings.TrimSpace(r.FormValue("q"))
+	terms := helpers.SearchTerms(query)
+	if len(terms) == 0 || len(query) > 100 {
+		http.Error(w, "q must be a text of up to 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := foodFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if menuId := r.FormValue("menu_id"); menuId != "" {
+		filter["menu_id"] = menuId
+	}
+
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+	availableOnly := r.FormValue("available") == "true"
+
+	var foods []models.Food
+	var menus []models.Menu
+
+	result, err := foodCollection.Find(ctx, filter)
+	if err == nil {
+		err = result.All(ctx, &foods)
+	}
+	if err == nil {
+		result, err = menuCollection.Find(ctx, bson.M{})
+	}
+	if err == nil {
+		err = result.All(ctx, &menus)
+	}
+	var categories map[string]models.Category
+	if err == nil {
+		categories, err = loadCategories(ctx)
+	}
+	var textScores map[string]float64
+	if err == nil {
+		textScores, err = searchText(ctx, query)
+	}
+	if err != nil {
+		msg := "error occurred while searching"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	menusById := make(map[string]models.Menu, len(menus))
+	for _, menu := range menus {
+		menusById[menu.MenuId] = menu
+	}
+
+	now := time.Now()
+	hits := []SearchHit{}
+	foundCategories := map[string]bool{}
+	foundMenus := map[string]bool{}
+
+	for i := range foods {
+		food := foods[i]
+		var menu models.Menu
+		if food.MenuId != nil {
+			menu = menusById[*food.MenuId]
+		}
+		if availableOnly {
+			if active, _ := helpers.MenuActive(menu, now); !active || helpers.FoodOrderable(food, 1) != nil {
+				continue
+			}
+		}
+
+		var path []models.Category
+		if food.CategoryId != nil {
+			path = helpers.CategoryPath(categories, *food.CategoryId)
+		}
+		for _, category := range path {
+			foundCategories[category.CategoryId] = true
+		}
+		foundMenus[menu.MenuId] = true
+
+		fields := foodSearchFields(food)
+		for _, category := range path {
+			for _, field := range categorySearchFields(category) {
+				fields = append(fields, helpers.SearchField{Text: field.Text, Weight: 2})
+			}
+		}
+		fields = append(fields, helpers.SearchField{Text: menu.Name, Weight: 1})
+
+		score := helpers.SearchScore(terms, fields) + textScores["food:"+food.FoodId]
+		if score > 0 {
+			hits = append(hits, SearchHit{Type: "FOOD", Id: food.FoodId, Score: score, Food: &foods[i]})
+		}
+	}
+
+	for categoryId := range foundCategories {
+		category := categories[categoryId]
+		score := helpers.SearchScore(terms, categorySearchFields(category)) + textScores["category:"+categoryId]
+		if score > 0 {
+			hits = append(hits, SearchHit{Type: "CATEGORY", Id: categoryId, Score: score, Category: &category})
+		}
+	}
+
+	for i := range menus {
+		if !foundMenus[menus[i].MenuId] {
+			continue
+		}
+		fields := []helpers.SearchField{{Text: menus[i].Name, Weight: 3}, {Text: menus[i].Category, Weight: 2}}
+		for _, translation := range menus[i].Translations {
+			fields = append(fields, helpers.SearchField{Text: translation.Name, Weight: 3}, helpers.SearchField{Text: translation.Category, Weight: 2})
+		}
+		score := helpers.SearchScore(terms, fields) + textScores["menu:"+menus[i].MenuId]
+		if score > 0 {
+			hits = append(hits, SearchHit{Type: "MENU", Id: menus[i].MenuId, Score: score, Menu: &menus[i]})
+		}
+	}
+
+	locales := helpers.RequestedLocales(r)
+	for i := range hits {
+		switch {
+		case hits[i].Food != nil:
+			helpers.LocalizeFood(hits[i].Food, locales)
+			hits[i].Name = *hits[i].Food.Name
+		case hits[i].Category != nil:
+			helpers.LocalizeCategory(hits[i].Category, locales)
+			hits[i].Name = *hits[i].Category.Name
+		case hits[i].Menu != nil:
+			helpers.LocalizeMenu(hits[i].Menu, locales)
+			hits[i].Name = hits[i].Menu.Name
+		}
+		hits[i].Score = math.Round(hits[i].Score*100) / 100
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return strings.ToLower(hits[i].Name) < strings.ToLower(hits[j].Name)
+	})
+
+	page := SearchPage{Query: query, TotalCount: len(hits), Results: hits}
+	if len(hits) > limit {
+		page.Results = hits[:limit]
+	}
+
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(pageJSON)
+}
+
+func foodSearchFields(food models.Food) []helpers.SearchField {
+	var fields []helpers.SearchField
+	if food.Name != nil {
+		fields = append(fields, helpers.SearchField{Text: *food.Name, Weight: 3})
+	}
+	if food.Description != nil {
+		fields = append(fields, helpers.SearchField{Text: *food.Description, Weight: 1})
+	}
+	for _, translation := range food.Translations {
+		fields = append(fields, helpers.SearchField{Text: translation.Name, Weight: 3}, helpers.SearchField{Text: translation.Description, Weight: 1})
+	}
+	return fields
+}
+
+func categorySearchFields(category models.Category) []helpers.SearchField {
+	var fields []helpers.SearchField
+	if category.Name != nil {
+		fields = append(fields, helpers.SearchField{Text: *category.Name, Weight: 3})
+	}
+	for _, translation := range category.Translations {
+		fields = append(fields, helpers.SearchField{Text: translation.Name, Weight: 3})
+	}
+	return fields
+}
+
+// searchText runs the query against the text indexes of foods, categories and menus and returns the scores
+// by "food:", "category:" and "menu:" followed by the id.
+func searchText(ctx context.Context, query string) (map[string]float64, error) {
+	var foods []struct {
+		models.Food `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+	var categories []struct {
+		models.Category `bson:",inline"`
+		Score           float64 `bson:"score"`
+	}
+	var menus []struct {
+		models.Menu `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+
+	err := findText(ctx, foodCollection, query, &foods)
+	if err == nil {
+		err = findText(ctx, categoryCollection, query, &categories)
+	}
+	if err == nil {
+		err = findText(ctx, menuCollection, query, &menus)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scores := map[string]float64{}
+	for _, food := range foods {
+		scores["food:"+food.FoodId] = food.Score
+	}
+	for _, category := range categories {
+		scores["category:"+category.CategoryId] = category.Score
+	}
+	for _, menu := range menus {
+		scores["menu:"+menu.MenuId] = menu.Score
+	}
+	return scores, nil
+}
+
+func findText(ctx context.Context, collection *mongo.Collection, query string, results interface{}) error {
+	opts := options.Find().SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}})
+
+	cursor, err := collection.Find(ctx, bson.M{"$text": bson.M{"$search": query}}, opts)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TranslationRow is one localized text of a food, menu or category, the unit of translation import and export.
+// Source is the untranslated text, it is exported for the translators and ignored on import.
+type TranslationRow struct {
+	EntityType string `json:"entity_type" validate:"required,eq=FOOD|eq=MENU|eq=CATEGORY"`
+	EntityId   string `json:"entity_id" validate:"required"`
+	Locale     string `json:"locale" validate:"required,bcp47_language_tag"`
+	Field      string `json:"field" validate:"required,eq=name|eq=description|eq=category"`
+	Source     string `json:"source"`
+	Value      string `json:"value" validate:"max=1000"`
+}
+
+type TranslationRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+const (
+	TranslationEntityFood     = "FOOD"
+	TranslationEntityMenu     = "MENU"
+	TranslationEntityCategory = "CATEGORY"
+)
+
+var translationColumns = []string{"entity_type", "entity_id", "locale", "field", "source", "value"}
+
+var translationFields = map[string][]string{
+	TranslationEntityFood:     {"name", "description"},
+	TranslationEntityMenu:     {"name", "category"},
+	TranslationEntityCategory: {"name"},
+}
+
+// ExportTranslations lists the translations of foods, menus and categories as JSON, or as CSV with ?format=csv.
+// With ?locale= every translatable text is listed for that locale, with an empty value when it is missing,
+// so the file can be handed to a translator.
+func ExportTranslations(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	locale := r.FormValue("locale")
+	if locale != "" {
+		if validationErr := validate.Var(locale, "bcp47_language_tag"); validationErr != nil {
+			http.Error(w, "locale must be a language tag like de or de-AT", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rows, err := translationRows(ctx, locale)
+	if err != nil {
+		msg := "error occurred while listing the translations"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if r.FormValue("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="translations.csv"`)
+		w.WriteHeader(http.StatusOK)
+
+		writer := csv.NewWriter(w)
+		writer.Write(translationColumns)
+		for _, row := range rows {
+			writer.Write([]string{row.EntityType, row.EntityId, row.Locale, row.Field, row.Source, row.Value})
+		}
+		writer.Flush()
+		return
+	}
+
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rowsJSON)
+}
+
+// ImportTranslations sets the translations of a JSON array or a CSV file (Content-Type text/csv) of
+// translation rows. Nothing is written unless every row is valid, ?dry_run=true only validates.
+// An empty value removes the translation.
+func ImportTranslations(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var rows []TranslationRow
+	var err error
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		rows, err = readTranslationCSV(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&rows)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rowErrors, err := checkTranslationRows(ctx, rows)
+	if err != nil {
+		msg := "error occurred while checking the translations"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if len(rowErrors) > 0 {
+		rowErrorsJSON, err := json.Marshal(bson.M{"errors": rowErrors})
+		if err != nil {
+			log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(rowErrorsJSON)
+		return
+	}
+
+	dryRun := r.FormValue("dry_run") == "true"
+	if !dryRun {
+		for i, row := range rows {
+			key := fmt.Sprintf("translations.%s.%s", row.Locale, row.Field)
+			update := bson.D{{"$set", bson.D{{key, row.Value}}}}
+			if row.Value == "" {
+				update = bson.D{{"$unset", bson.D{{key, ""}}}}
+			}
+
+			collection, key := translationCollection(row.EntityType)
+			filter := bson.M{key: row.EntityId}
+			// documents stored with a null translations field cannot take nested fields
+			_, err := collection.UpdateOne(ctx, bson.M{"$and": bson.A{filter, bson.M{"translations": nil}}}, bson.D{{"$set", bson.D{{"translations", bson.M{}}}}})
+			if err == nil {
+				_, err = collection.UpdateOne(ctx, filter, update)
+			}
+			if err != nil {
+				msg := fmt.Sprintf("translation import failed at row %d", i+1)
+				http.Error(w, msg, http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	resultJSON, err := json.Marshal(bson.M{"rows": len(rows), "dry_run": dryRun})
+	if err != nil {
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
+}
+
+func readTranslationCSV(body io.Reader) ([]TranslationRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("translation file has no header row")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"entity_type", "entity_id", "locale", "field", "value"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("translation file has no %s column", name)
+		}
+	}
+
+	cell := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []TranslationRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, TranslationRow{
+			EntityType: strings.ToUpper(cell(record, "entity_type")),
+			EntityId:   cell(record, "entity_id"),
+			Locale:     cell(record, "locale"),
+			Field:      strings.ToLower(cell(record, "field")),
+			Value:      cell(record, "value"),
+		})
+	}
+}
+
+// checkTranslationRows validates every row and checks that the foods, menus and categories exist. Rows are numbered
+// from 1 like the data rows of a CSV file.
+func checkTranslationRows(ctx context.Context, rows []TranslationRow) ([]TranslationRowError, error) {
+	rowErrors := []TranslationRowError{}
+	ids := map[string]map[string]bool{TranslationEntityFood: {}, TranslationEntityMenu: {}, TranslationEntityCategory: {}}
+
+	for i, row := range rows {
+		if validationErr := validate.Struct(row); validationErr != nil {
+			rowErrors = append(rowErrors, TranslationRowError{Row: i + 1, Error: validationErr.Error()})
+			continue
+		}
+		if !containsString(translationFields[row.EntityType], row.Field) {
+			msg := fmt.Sprintf("%s has no translatable field %s", strings.ToLower(row.EntityType), row.Field)
+			rowErrors = append(rowErrors, TranslationRowError{Row: i + 1, Error: msg})
+			continue
+		}
+		ids[row.EntityType][row.EntityId] = true
+	}
+
+	existing := map[string]map[string]bool{TranslationEntityFood: {}, TranslationEntityMenu: {}, TranslationEntityCategory: {}}
+	for entityType, entityIds := range ids {
+		if len(entityIds) == 0 {
+			continue
+		}
+		list := make([]string, 0, len(entityIds))
+		for id := range entityIds {
+			list = append(list, id)
+		}
+
+		collection, key := translationCollection(entityType)
+		found, err := collection.Distinct(ctx, key, bson.M{key: bson.M{"$in": list}})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range found {
+			if id, ok := id.(string); ok {
+				existing[entityType][id] = true
+			}
+		}
+	}
+
+	for i, row := range rows {
+		if ids[row.EntityType] != nil && ids[row.EntityType][row.EntityId] && !existing[row.EntityType][row.EntityId] {
+			msg := fmt.Sprintf("%s %s was not found", strings.ToLower(row.EntityType), row.EntityId)
+			rowErrors = append(rowErrors, TranslationRowError{Row: i + 1, Error: msg})
+		}
+	}
+
+	sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+	return rowErrors, nil
+}
+
+// translationRows lists the stored translations, or every translatable text in the given locale.
+func translationRows(ctx context.Context, locale string) ([]TranslationRow, error) {
+	var foods []models.Food
+	var menus []models.Menu
+	var categories []models.Category
+
+	result, err := foodCollection.Find(ctx, bson.M{})
+	if err == nil {
+		err = result.All(ctx, &foods)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = menuCollection.Find(ctx, bson.M{})
+	if err == nil {
+		err = result.All(ctx, &menus)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = categoryCollection.Find(ctx, bson.M{})
+	if err == nil {
+		err = result.All(ctx, &categories)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []TranslationRow{}
+	add := func(entityType string, entityId string, translations map[string]map[string]string, sources map[string]string) {
+		locales := []string{locale}
+		if locale == "" {
+			locales = locales[:0]
+			for translated := range translations {
+				locales = append(locales, translated)
+			}
+			sort.Strings(locales)
+		}
+		for _, rowLocale := range locales {
+			for _, field := range translationFields[entityType] {
+				value := translations[rowLocale][field]
+				if value == "" && locale == "" {
+					continue
+				}
+				rows = append(rows, TranslationRow{
+					EntityType: entityType,
+					EntityId:   entityId,
+					Locale:     rowLocale,
+					Field:      field,
+					Source:     sources[field],
+					Value:      value,
+				})
+			}
+		}
+	}
+
+	for _, food := range foods {
+		translations := map[string]map[string]string{}
+		for translated, translation := range food.Translations {
+			translations[translated] = map[string]string{"name": translation.Name, "description": translation.Description}
+		}
+		sources := map[string]string{}
+		if food.Name != nil {
+			sources["name"] = *food.Name
+		}
+		if food.Description != nil {
+			sources["description"] = *food.Description
+		}
+		add(TranslationEntityFood, food.FoodId, translations, sources)
+	}
+
+	for _, menu := range menus {
+		translations := map[string]map[string]string{}
+		for translated, translation := range menu.Translations {
+			translations[translated] = map[string]string{"name": translation.Name, "category": translation.Category}
+		}
+		add(TranslationEntityMenu, menu.MenuId, translations, map[string]string{"name": menu.Name, "category": menu.Category})
+	}
+
+	for _, category := range categories {
+		translations := map[string]map[string]string{}
+		for translated, translation := range category.Translations {
+			translations[translated] = map[string]string{"name": translation.Name}
+		}
+		sources := map[string]string{}
+		if category.Name != nil {
+			sources["name"] = *category.Name
+		}
+		add(TranslationEntityCategory, category.CategoryId, translations, sources)
+	}
+
+	return rows, nil
+}
+
+// translationCollection returns the collection of an entity type and the key of its ids.
+func translationCollection(entityType string) (*mongo.Collection, string) {
+	switch entityType {
+	case TranslationEntityMenu:
+		return menuCollection, "menu_id"
+	case TranslationEntityCategory:
+		return categoryCollection, "category_id"
+	default:
+		return foodCollection, "food_id"
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/menyasosali/restaurant-manage-backend-go/database"
@@ -11,7 +12,6 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/bcrypt"
 	"log"
 	"net/http"
 	"strconv"
@@ -20,6 +20,9 @@ import (
 
 var userCollection = database.OpenCollection(database.Client, "user")
 
+var ErrUserExists = errors.New("this email or phone number already exists")
+var ErrUserNotFound = errors.New("user was not found")
+
 func GetUsers(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
@@ -113,40 +116,12 @@ func SingUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
-	if err != nil {
-		msg := "error occurred while checking for the email"
-		http.Error(w, msg, http.StatusInternalServerError)
+	resultInsertNumber, err := CreateUser(ctx, user, models.RoleStaff)
+	if errors.Is(err, ErrUserExists) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	password := HashPassword(*user.Password)
-	user.Password = &password
-
-	count, err = userCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
 	if err != nil {
-		msg := "error occurred while checking for the phone number"
-		http.Error(w, msg, http.StatusInternalServerError)
-		return
-	}
-
-	if count > 0 {
-		msg := "this email or phone number already exists"
-		http.Error(w, msg, http.StatusInternalServerError)
-		return
-	}
-
-	user.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
-	user.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
-	user.ID = primitive.NewObjectID()
-	user.UserId = user.ID.Hex()
-
-	token, refreshToken, _ := helpers.GenerateAllTokens(*user.Email, *user.FirstName, *user.SecondName, user.UserId)
-	user.Token = &token
-	user.RefreshToken = &refreshToken
-
-	resultInsertNumber, insertErr := userCollection.InsertOne(ctx, user)
-	if insertErr != nil {
 		msg := fmt.Sprintf("User item was not created")
 		http.Error(w, msg, http.StatusInternalServerError)
 		return
@@ -180,13 +155,18 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	passwordValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
+	passwordValid, msg := helpers.VerifyPassword(*user.Password, *foundUser.Password)
 	if passwordValid != true {
 		http.Error(w, msg, http.StatusInternalServerError)
 		return
 	}
 
-	token, refreshToken, _ := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.FirstName, *foundUser.SecondName, foundUser.UserId)
+	role := models.RoleStaff
+	if foundUser.Role != nil {
+		role = *foundUser.Role
+	}
+
+	token, refreshToken, _ := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.FirstName, *foundUser.SecondName, foundUser.UserId, role)
 
 	helpers.UpdateAllTokens(token, refreshToken, foundUser.UserId)
 
@@ -199,23 +179,113 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	w.Write(foundUserJSON)
 }
 
-func HashPassword(password string) string {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+func UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var user models.User
+
+	vars := mux.Vars(r)
+	userId := vars["user_id"]
+
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if user.Role == nil {
+		http.Error(w, "role is required", http.StatusBadRequest)
+		return
+	}
+
+	if validationErr := validate.Var(*user.Role, "eq=ADMIN|eq=MANAGER|eq=STAFF"); validationErr != nil {
+		http.Error(w, validationErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userId},
+		bson.D{
+			{"$set", bson.D{
+				{"role", user.Role},
+				{"updated_at", user.UpdatedAt},
+			}},
+		},
+	)
+	if err != nil {
+		msg := "user role update failed"
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		http.Error(w, "user was not found", http.StatusNotFound)
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Error happened in JSON marshal. Err: %s", err)
 	}
-	return string(bytes)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultJSON)
 }
 
-func VerifyPassword(userPassword string, providedPassword string) (bool, string) {
+// CreateUser stores a validated user with the role and a hashed password, sign up and restaurantctl share it.
+func CreateUser(ctx context.Context, user models.User, role string) (*mongo.InsertOneResult, error) {
+	count, err := userCollection.CountDocuments(ctx, bson.M{"$or": bson.A{bson.M{"email": user.Email}, bson.M{"phone": user.Phone}}})
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while checking for the email or phone number: %s", err)
+	}
+	if count > 0 {
+		return nil, ErrUserExists
+	}
+
+	password := helpers.HashPassword(*user.Password)
+	user.Password = &password
 
-	err := bcrypt.CompareHashAndPassword([]byte(providedPassword), []byte(userPassword))
-	check := true
-	msg := ""
+	user.CreatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	user.UpdatedAt, _ = time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+	user.ID = primitive.NewObjectID()
+	user.UserId = user.ID.Hex()
+	user.AvatarImage = nil
+	user.Role = &role
 
+	token, refreshToken, _ := helpers.GenerateAllTokens(*user.Email, *user.FirstName, *user.SecondName, user.UserId, *user.Role)
+	user.Token = &token
+	user.RefreshToken = &refreshToken
+
+	return userCollection.InsertOne(ctx, user)
+}
+
+// ResetPassword sets a new password for the user with the email, the tokens issued before stay valid until
+// they expire.
+func ResetPassword(ctx context.Context, email string, password string) error {
+	if err := validate.Var(password, "min=6"); err != nil {
+		return fmt.Errorf("password must have at least 6 characters")
+	}
+
+	updatedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.M{"email": email},
+		bson.D{
+			{"$set", bson.D{
+				{"password", helpers.HashPassword(password)},
+				{"updated_at", updatedAt},
+			}},
+		},
+	)
 	if err != nil {
-		msg = fmt.Sprintf("login or password is incorrect")
-		check = false
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
 	}
-	return check, msg
+	return nil
 }
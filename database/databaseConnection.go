@@ -2,16 +2,20 @@ package database
 
 import (
 	"context"
-	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"os"
 	"time"
 )
 
+// DBInstance connects to MONGODB_URL, a local server when it is not set. The messages go to the log so the
+// output of restaurantctl stays clean.
 func DBInstance() *mongo.Client {
-	MongoDb := "localhost:"
-	fmt.Println(MongoDb)
+	MongoDb := os.Getenv("MONGODB_URL")
+	if MongoDb == "" {
+		MongoDb = "mongodb://localhost:27017"
+	}
 
 	client, err := mongo.NewClient(options.Client().ApplyURI(MongoDb))
 	if err != nil {
@@ -26,14 +30,22 @@ func DBInstance() *mongo.Client {
 		log.Fatalf("failed to connect to database: %s", err)
 	}
 
-	fmt.Println("connected to mongodb")
+	log.Println("connected to mongodb")
 	return client
 }
 
 var Client *mongo.Client = DBInstance()
 
+// DatabaseName is the database of the restaurant, MONGODB_DATABASE or "restaurant".
+func DatabaseName() string {
+	if name := os.Getenv("MONGODB_DATABASE"); name != "" {
+		return name
+	}
+	return "restaurant"
+}
+
 func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	var collection *mongo.Collection = client.Database("restaurant").Collection(collectionName)
+	var collection *mongo.Collection = client.Database(DatabaseName()).Collection(collectionName)
 
 	return collection
 }
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// EnsureIndexes creates the indexes the application relies on. Creating an index that already exists is a no-op.
+func EnsureIndexes(client *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		"invoice": {
+			{
+				Keys:    bson.D{{"invoice_number", 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"invoice_number": bson.M{"$exists": true}}),
+			},
+		},
+		"category": {
+			{
+				Keys:    bson.D{{"category_id", 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{"parent_id", 1}, {"display_order", 1}},
+			},
+			{
+				Keys:    bson.D{{"sku", 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sku": bson.M{"$exists": true}}),
+			},
+			{
+				Keys:    bson.D{{"name", "text"}},
+				Options: options.Index().SetName("category_text").SetWeights(bson.D{{"name", 3}}),
+			},
+		},
+		"food": {
+			{
+				Keys:    bson.D{{"sku", 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sku": bson.M{"$exists": true}}),
+			},
+			{
+				Keys:    bson.D{{"name", "text"}, {"description", "text"}},
+				Options: options.Index().SetName("food_text").SetWeights(bson.D{{"name", 3}, {"description", 1}}),
+			},
+		},
+		"menu": {
+			{
+				Keys:    bson.D{{"sku", 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sku": bson.M{"$exists": true}}),
+			},
+			{
+				Keys:    bson.D{{"name", "text"}, {"category", "text"}},
+				Options: options.Index().SetName("menu_text").SetWeights(bson.D{{"name", 3}, {"category", 2}}),
+			},
+		},
+		"sales_report": {
+			{
+				Keys:    bson.D{{"report_type", 1}, {"number", 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"number": bson.M{"$exists": true}}),
+			},
+		},
+		"discount": {
+			{
+				// a promotion is applied to an order once, manual discounts have no promotion
+				Keys:    bson.D{{"order_id", 1}, {"promotion_id", 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"promotion_id": bson.M{"$type": "string"}}),
+			},
+		},
+		"combo": {
+			{
+				Keys:    bson.D{{"combo_id", 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{"menu_id", 1}, {"display_order", 1}},
+			},
+		},
+	}
+
+	for collectionName, models := range indexes {
+		if _, err := OpenCollection(client, collectionName).Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+type CSVWriter struct {
+	writer *csv.Writer
+	fields []string
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{writer: csv.NewWriter(w)}
+}
+
+func (c *CSVWriter) WriteRow(values []interface{}) error {
+	c.fields = c.fields[:0]
+	for _, value := range values {
+		c.fields = append(c.fields, formatValue(value))
+	}
+	return c.writer.Write(c.fields)
+}
+
+func (c *CSVWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
@@ -0,0 +1,115 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Writer writes a table row by row, so exports never hold more than one row in memory.
+type Writer interface {
+	WriteRow(values []interface{}) error
+	// Close finishes the file, nothing written before is complete without it.
+	Close() error
+}
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// NewWriter opens a writer for the format, sheet names the worksheet of an XLSX file.
+func NewWriter(format string, w io.Writer, sheet string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatXLSX:
+		return NewXLSXWriter(w, sheet)
+	}
+	return nil, fmt.Errorf("format must be one of %s, %s", FormatCSV, FormatXLSX)
+}
+
+// ContentType is the media type of files in the format.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Columns picks the columns of an export from a comma separated list, all of them when the list is empty.
+func Columns(value string, available []string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return available, nil
+	}
+
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+		found := false
+		for _, name := range available {
+			if name == column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s must be one of %s", column, strings.Join(available, ", "))
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// Row picks the values of the columns from a row.
+func Row(row map[string]interface{}, columns []string) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = row[column]
+	}
+	return values
+}
+
+// formatValue writes a value as text, times in RFC 3339 and nil as an empty cell.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil || v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
@@ -1,14 +1,18 @@
 module github.com/menyasosali/restaurant-manage-backend-go
 
-go 1.19
+// 1.22.2 is the minimum of github.com/HugoSmits86/nativewebp, which encodes the WebP image variants
+go 1.22.2
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/go-playground/validator/v10 v10.11.2
 	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/gorilla/handlers v1.5.1
 	github.com/gorilla/mux v1.8.0
 	go.mongodb.org/mongo-driver v1.11.2
 	golang.org/x/crypto v0.5.0
+	golang.org/x/image v0.20.0
+	golang.org/x/text v0.18.0
 )
 
 require (
@@ -24,7 +28,6 @@ require (
 	github.com/xdg-go/scram v1.1.1 // indirect
 	github.com/xdg-go/stringprep v1.0.3 // indirect
 	github.com/youmark/pkcs8 v0.0.0-20181117223130-1be2e3e5546d // indirect
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
+	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.4.0 // indirect
-	golang.org/x/text v0.6.0 // indirect
 )
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"strings"
+	"unicode"
+)
+
+// allergenKeywords are the words that name an allergen in free text allergy notes.
+var allergenKeywords = map[string][]string{
+	"GLUTEN":      {"gluten", "wheat", "barley", "rye", "coeliac", "celiac"},
+	"CRUSTACEANS": {"crustacean", "crustaceans", "shellfish", "shrimp", "shrimps", "prawn", "prawns", "crab", "lobster"},
+	"EGGS":        {"egg", "eggs"},
+	"FISH":        {"fish"},
+	"PEANUTS":     {"peanut", "peanuts"},
+	"SOYBEANS":    {"soy", "soya", "soybean", "soybeans"},
+	"MILK":        {"milk", "dairy", "lactose"},
+	"NUTS":        {"nut", "nuts", "almond", "almonds", "hazelnut", "hazelnuts", "walnut", "walnuts", "cashew", "cashews", "pecan", "pecans", "pistachio", "pistachios"},
+	"CELERY":      {"celery"},
+	"MUSTARD":     {"mustard"},
+	"SESAME":      {"sesame"},
+	"SULPHITES":   {"sulphite", "sulphites", "sulfite", "sulfites"},
+	"LUPIN":       {"lupin", "lupine"},
+	"MOLLUSCS":    {"mollusc", "molluscs", "mollusk", "mollusks", "mussel", "mussels", "oyster", "oysters", "squid"},
+}
+
+// ParseLabels splits a comma separated query value and checks every label against allowed.
+func ParseLabels(value string, allowed []string) ([]string, error) {
+	var labels []string
+	for _, label := range strings.Split(value, ",") {
+		label = strings.ToUpper(strings.TrimSpace(label))
+		if label == "" {
+			continue
+		}
+		if !containsLabel(allowed, label) {
+			return nil, fmt.Errorf("%s must be one of %s", label, strings.Join(allowed, ", "))
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+// NotedAllergens collects the allergens of the ALLERGY notes, from their allergen list and from the text.
+func NotedAllergens(notes []models.Note) []string {
+	var allergens []string
+	for _, note := range notes {
+		if note.Kind != models.NoteKindAllergy {
+			continue
+		}
+		for _, allergen := range note.Allergens {
+			if !containsLabel(allergens, allergen) {
+				allergens = append(allergens, allergen)
+			}
+		}
+		words := strings.FieldsFunc(strings.ToLower(note.Title+" "+note.Text), func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+		for _, allergen := range models.Allergens {
+			if containsLabel(allergens, allergen) {
+				continue
+			}
+			for _, word := range words {
+				if containsLabel(allergenKeywords[allergen], word) {
+					allergens = append(allergens, allergen)
+					break
+				}
+			}
+		}
+	}
+	return allergens
+}
+
+// AllergenConflicts returns the allergens of the food that are in noted.
+func AllergenConflicts(food models.Food, noted []string) []string {
+	var conflicts []string
+	for _, allergen := range food.Allergens {
+		if containsLabel(noted, allergen) {
+			conflicts = append(conflicts, allergen)
+		}
+	}
+	return conflicts
+}
+
+func containsLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"sort"
+	"strings"
+)
+
+// CategoryNode is a category with its foods and subcategories, the shape of the category tree of a menu.
+type CategoryNode struct {
+	models.Category
+	Foods    []models.Food  `json:"foods"`
+	Children []CategoryNode `json:"children"`
+}
+
+// CategoryTree nests the categories under their parents and puts the foods into their categories, both in
+// display order. Branches without foods are left out. Foods without a known category are returned apart.
+func CategoryTree(categories []models.Category, foods []models.Food) ([]CategoryNode, []models.Food) {
+	byId := map[string]models.Category{}
+	children := map[string][]models.Category{}
+	for _, category := range categories {
+		byId[category.CategoryId] = category
+	}
+	for _, category := range categories {
+		parentId := ""
+		if category.ParentId != nil && *category.ParentId != category.CategoryId {
+			if _, ok := byId[*category.ParentId]; ok {
+				parentId = *category.ParentId
+			}
+		}
+		children[parentId] = append(children[parentId], category)
+	}
+
+	foodsOf := map[string][]models.Food{}
+	uncategorized := []models.Food{}
+	for _, food := range foods {
+		if food.CategoryId == nil {
+			uncategorized = append(uncategorized, food)
+			continue
+		}
+		if _, ok := byId[*food.CategoryId]; !ok {
+			uncategorized = append(uncategorized, food)
+			continue
+		}
+		foodsOf[*food.CategoryId] = append(foodsOf[*food.CategoryId], food)
+	}
+	sortFoods(uncategorized)
+
+	// visited guards against parent links that form a cycle
+	visited := map[string]bool{}
+	var build func(parentId string) []CategoryNode
+	build = func(parentId string) []CategoryNode {
+		nodes := []CategoryNode{}
+		level := children[parentId]
+		SortCategories(level)
+		for _, category := range level {
+			if visited[category.CategoryId] {
+				continue
+			}
+			visited[category.CategoryId] = true
+
+			node := CategoryNode{Category: category, Foods: foodsOf[category.CategoryId], Children: build(category.CategoryId)}
+			if node.Foods == nil {
+				node.Foods = []models.Food{}
+			}
+			if len(node.Foods) == 0 && len(node.Children) == 0 {
+				continue
+			}
+			sortFoods(node.Foods)
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+
+	return build(""), uncategorized
+}
+
+// CategoryPath lists a category and its ancestors, the category first. Missing parents and cycles end the path.
+func CategoryPath(categories map[string]models.Category, categoryId string) []models.Category {
+	var path []models.Category
+	seen := map[string]bool{}
+	for categoryId != "" && !seen[categoryId] {
+		category, ok := categories[categoryId]
+		if !ok {
+			break
+		}
+		seen[categoryId] = true
+		path = append(path, category)
+
+		categoryId = ""
+		if category.ParentId != nil {
+			categoryId = *category.ParentId
+		}
+	}
+	return path
+}
+
+// CategoryStation returns the station of the nearest category of the path that has one.
+func CategoryStation(path []models.Category) string {
+	for _, category := range path {
+		if category.Station != "" {
+			return category.Station
+		}
+	}
+	return ""
+}
+
+// SortCategories orders categories by display order, then name.
+func SortCategories(categories []models.Category) {
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].DisplayOrder != categories[j].DisplayOrder {
+			return categories[i].DisplayOrder < categories[j].DisplayOrder
+		}
+		return strings.ToLower(categoryName(categories[i])) < strings.ToLower(categoryName(categories[j]))
+	})
+}
+
+// LocalizeCategory replaces the name of the category with the best translation for the requested locales and
+// returns the locale used.
+func LocalizeCategory(category *models.Category, requested []string) string {
+	available := make([]string, 0, len(category.Translations))
+	for locale := range category.Translations {
+		available = append(available, locale)
+	}
+	sort.Strings(available)
+
+	locale := NegotiateLocale(requested, available)
+	translation, ok := category.Translations[locale]
+	if !ok {
+		return DEFAULT_LOCALE
+	}
+	if translation.Name != "" {
+		category.Name = &translation.Name
+	}
+	return locale
+}
+
+// LocalizeCategoryTree localizes the categories and foods of a tree.
+func LocalizeCategoryTree(nodes []CategoryNode, requested []string) {
+	for i := range nodes {
+		LocalizeCategory(&nodes[i].Category, requested)
+		for j := range nodes[i].Foods {
+			LocalizeFood(&nodes[i].Foods[j], requested)
+		}
+		LocalizeCategoryTree(nodes[i].Children, requested)
+	}
+}
+
+func sortFoods(foods []models.Food) {
+	sort.SliceStable(foods, func(i, j int) bool {
+		if foods[i].DisplayOrder != foods[j].DisplayOrder {
+			return foods[i].DisplayOrder < foods[j].DisplayOrder
+		}
+		return strings.ToLower(foodName(foods[i])) < strings.ToLower(foodName(foods[j]))
+	})
+}
+
+func categoryName(category models.Category) string {
+	if category.Name == nil {
+		return ""
+	}
+	return *category.Name
+}
+
+func foodName(food models.Food) string {
+	if food.Name == nil {
+		return ""
+	}
+	return *food.Name
+}
@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+)
+
+// PrepareComboSlots gives ids to new slots and makes sure every option of a slot can be told apart.
+func PrepareComboSlots(slots []models.ComboSlot) error {
+	for i := range slots {
+		slot := &slots[i]
+		if slot.SlotId == "" {
+			slot.SlotId = primitive.NewObjectID().Hex()
+		}
+		seen := map[string]bool{}
+		for _, option := range slot.Options {
+			key := option.FoodId
+			if option.Portion != nil {
+				key += "/" + *option.Portion
+			}
+			if seen[key] {
+				return fmt.Errorf("slot %s lists the same option more than once", slot.Name)
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
+// FindComboOption looks up the option of a slot for the chosen food. The portion is only needed when the slot
+// offers the food in more than one portion.
+func FindComboOption(combo models.Combo, slotId string, foodId string, portion *string) (*models.ComboSlot, *models.ComboSlotOption, error) {
+	for i := range combo.Slots {
+		slot := &combo.Slots[i]
+		if slot.SlotId != slotId {
+			continue
+		}
+
+		var found *models.ComboSlotOption
+		for j := range slot.Options {
+			option := &slot.Options[j]
+			if option.FoodId != foodId {
+				continue
+			}
+			if portion != nil && (option.Portion == nil || *option.Portion != *portion) {
+				continue
+			}
+			if found != nil {
+				return nil, nil, fmt.Errorf("%s offers this food in several portions, choose one", slot.Name)
+			}
+			found = option
+		}
+		if found == nil {
+			return nil, nil, fmt.Errorf("food %s cannot be chosen for %s", foodId, slot.Name)
+		}
+		return slot, found, nil
+	}
+	return nil, nil, fmt.Errorf("combo slot %s does not exist", slotId)
+}
+
+// CheckComboChoices makes sure every slot got exactly its number of choices, counts are by slot id.
+func CheckComboChoices(combo models.Combo, counts map[string]int) error {
+	for _, slot := range combo.Slots {
+		if counts[slot.SlotId] != slot.Choices {
+			return fmt.Errorf("%s: choose %d for %s", *combo.Name, slot.Choices, slot.Name)
+		}
+	}
+	return nil
+}
+
+// AllocatePrice splits a bundle price over its components in proportion to their weights, usually their
+// prices when sold alone. The shares are rounded to cents and add up to the total exactly, the rounding
+// difference goes to the largest share. Without weights the price is split evenly.
+func AllocatePrice(total float64, weights []float64) []float64 {
+	shares := make([]float64, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+
+	sum := 0.0
+	for _, weight := range weights {
+		sum += math.Max(weight, 0)
+	}
+
+	largest := 0
+	allocated := 0.0
+	for i, weight := range weights {
+		if sum > 0 {
+			shares[i] = RoundMoney(total * math.Max(weight, 0) / sum)
+		} else {
+			shares[i] = RoundMoney(total / float64(len(weights)))
+		}
+		allocated += shares[i]
+		if shares[i] > shares[largest] {
+			largest = i
+		}
+	}
+	shares[largest] = RoundMoney(shares[largest] + total - allocated)
+	return shares
+}
@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var counterCollection = database.OpenCollection(database.Client, "counter")
+
+var INVOICE_PREFIX string = envOrDefault("INVOICE_PREFIX", "INV")
+var RESTAURANT_ID string = envOrDefault("RESTAURANT_ID", "main")
+
+// NextSequence atomically increments and returns the named counter, starting at 1.
+// Called with a session context inside a transaction the increment is rolled back together with the
+// transaction, which is what keeps the sequences gap-free.
+func NextSequence(ctx context.Context, name string) (int64, error) {
+	var counter models.Counter
+
+	err := counterCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": name},
+		bson.D{{"$inc", bson.D{{"seq", 1}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+
+	return counter.Seq, err
+}
+
+// NextInvoiceNumber allocates the next invoice number of the restaurant, e.g. INV-2026-000042.
+// Numbering restarts every year.
+func NextInvoiceNumber(ctx context.Context, at time.Time) (string, error) {
+	year := at.Year()
+
+	seq, err := NextSequence(ctx, fmt.Sprintf("invoice:%s:%d", RESTAURANT_ID, year))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%d-%06d", INVOICE_PREFIX, year, seq), nil
+}
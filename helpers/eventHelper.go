@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"sync"
+	"time"
+)
+
+// FoodAvailabilityEvent is sent to front-of-house devices whenever a food becomes orderable or not.
+type FoodAvailabilityEvent struct {
+	FoodId            string    `json:"food_id"`
+	Name              string    `json:"name"`
+	Available         bool      `json:"available"`
+	OutOfStock        bool      `json:"out_of_stock"`
+	RemainingPortions *int      `json:"remaining_portions"`
+	At                time.Time `json:"at"`
+}
+
+// Broker fans events out to the connected subscribers. It only reaches subscribers of this process.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan interface{}]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[chan interface{}]struct{}{}}
+}
+
+var FoodAvailabilityEvents = NewBroker()
+
+func (b *Broker) Subscribe() chan interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan interface{}, 16)
+	b.subscribers[events] = struct{}{}
+	return events
+}
+
+func (b *Broker) Unsubscribe(events chan interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[events]; ok {
+		delete(b.subscribers, events)
+		close(events)
+	}
+}
+
+// Publish never blocks, a subscriber that does not keep up misses the event and should reload.
+func (b *Broker) Publish(event interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"strings"
+	"time"
+)
+
+// ValidateVariants rejects variants whose names only differ by case, portions are matched case-insensitively.
+func ValidateVariants(variants []models.FoodVariant) error {
+	seen := map[string]bool{}
+	for _, variant := range variants {
+		name := strings.ToUpper(variant.Name)
+		if seen[name] {
+			return fmt.Errorf("variant %s is defined more than once", variant.Name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// VariantsChanged tells whether a variant was added, removed, renamed or repriced, the order does not matter.
+func VariantsChanged(before []models.FoodVariant, after []models.FoodVariant) bool {
+	if len(before) != len(after) {
+		return true
+	}
+
+	prices := map[string]*float64{}
+	for _, variant := range before {
+		prices[variant.Name] = variant.Price
+	}
+	for _, variant := range after {
+		price, ok := prices[variant.Name]
+		if !ok || (price == nil) != (variant.Price == nil) || (price != nil && *price != *variant.Price) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveVariant finds the variant of the food ordered as portion. Foods with variants must be ordered as one
+// of them, for the others the portion is free text and nil is returned.
+func ResolveVariant(food models.Food, portion *string) (*models.FoodVariant, error) {
+	if len(food.Variants) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(food.Variants))
+	for i, variant := range food.Variants {
+		if portion != nil && strings.EqualFold(variant.Name, *portion) {
+			return &food.Variants[i], nil
+		}
+		names = append(names, variant.Name)
+	}
+
+	return nil, fmt.Errorf("portion must be one of %s", strings.Join(names, ", "))
+}
+
+// FoodOrderable checks that count portions of the food can be ordered right now.
+func FoodOrderable(food models.Food, count int) error {
+	switch {
+	case food.Available != nil && !*food.Available:
+		return fmt.Errorf("%s is 86'd", *food.Name)
+	case food.OutOfStock:
+		return fmt.Errorf("%s is out of stock", *food.Name)
+	case food.RemainingPortions != nil && *food.RemainingPortions < count:
+		return fmt.Errorf("only %d portions of %s are left", *food.RemainingPortions, *food.Name)
+	}
+	return nil
+}
+
+// FoodAvailability is the availability event for the current state of the food.
+func FoodAvailability(food models.Food) FoodAvailabilityEvent {
+	return FoodAvailabilityEvent{
+		FoodId:            food.FoodId,
+		Name:              *food.Name,
+		Available:         FoodOrderable(food, 1) == nil,
+		OutOfStock:        food.OutOfStock,
+		RemainingPortions: food.RemainingPortions,
+		At:                time.Now(),
+	}
+}
@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// MAX_IMAGE_BYTES limits the size of uploaded images, 5 MB unless configured.
+var MAX_IMAGE_BYTES int64 = int64(envFloat("MAX_IMAGE_BYTES", 5<<20))
+
+// Renditions are scaled to fit these boxes, smaller images are not enlarged.
+var (
+	THUMBNAIL_SIZE int = int(envFloat("THUMBNAIL_SIZE", 320))
+	WEBP_SIZE      int = int(envFloat("WEBP_SIZE", 1280))
+)
+
+// MAX_IMAGE_PIXELS keeps small files with huge dimensions from being decoded.
+const MAX_IMAGE_PIXELS = 40_000_000
+
+var ImageContentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+var ErrUnsupportedImage = errors.New("image must be a JPEG, PNG, GIF or WebP file")
+
+// ImageRendition is one file to store for an uploaded image.
+type ImageRendition struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// ProcessedImage is a decoded upload with the renditions made from it: the original, a JPEG thumbnail and
+// WebP versions of the image and of the thumbnail.
+type ProcessedImage struct {
+	ContentType   string
+	Width         int
+	Height        int
+	Original      ImageRendition
+	Thumbnail     ImageRendition
+	Webp          ImageRendition
+	ThumbnailWebp ImageRendition
+}
+
+// ProcessImage checks that the data is a supported image, judging by its content rather than the name or
+// declared type, and makes the renditions.
+func ProcessImage(data []byte) (ProcessedImage, error) {
+	var processed ProcessedImage
+
+	if int64(len(data)) > MAX_IMAGE_BYTES {
+		return processed, fmt.Errorf("image must not be larger than %d bytes", MAX_IMAGE_BYTES)
+	}
+
+	contentType := http.DetectContentType(data)
+	if !containsLabel(ImageContentTypes, contentType) {
+		return processed, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return processed, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > MAX_IMAGE_PIXELS {
+		return processed, fmt.Errorf("image must not have more than %d pixels", MAX_IMAGE_PIXELS)
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return processed, ErrUnsupportedImage
+	}
+
+	processed.ContentType = contentType
+	processed.Width = config.Width
+	processed.Height = config.Height
+	processed.Original = ImageRendition{Name: "original" + imageExtension(contentType), ContentType: contentType, Data: data}
+
+	thumbnail := fitImage(source, THUMBNAIL_SIZE)
+
+	// JPEG has no transparency, transparent pixels become white instead of black
+	flattened := image.NewRGBA(thumbnail.Bounds())
+	draw.Draw(flattened, flattened.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flattened, flattened.Bounds(), thumbnail, thumbnail.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flattened, &jpeg.Options{Quality: 80}); err != nil {
+		return processed, err
+	}
+	processed.Thumbnail = ImageRendition{Name: "thumbnail.jpg", ContentType: "image/jpeg", Data: buf.Bytes()}
+
+	if processed.Webp.Data, err = encodeWebp(fitImage(source, WEBP_SIZE)); err != nil {
+		return processed, err
+	}
+	processed.Webp.Name, processed.Webp.ContentType = "image.webp", "image/webp"
+
+	if processed.ThumbnailWebp.Data, err = encodeWebp(thumbnail); err != nil {
+		return processed, err
+	}
+	processed.ThumbnailWebp.Name, processed.ThumbnailWebp.ContentType = "thumbnail.webp", "image/webp"
+
+	return processed, nil
+}
+
+// fitImage scales the image down to fit a size × size box, keeping the aspect ratio.
+func fitImage(source image.Image, size int) image.Image {
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return source
+	}
+
+	if width >= height {
+		height = max(1, height*size/width)
+		width = size
+	} else {
+		width = max(1, width*size/height)
+		height = size
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), source, bounds, draw.Src, nil)
+	return scaled
+}
+
+func encodeWebp(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func imageExtension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ".webp"
+	}
+}
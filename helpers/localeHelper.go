@@ -0,0 +1,143 @@
+package helpers
+
+import (
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DEFAULT_LOCALE is the language of the untranslated names and descriptions.
+var DEFAULT_LOCALE string = envOrDefault("DEFAULT_LOCALE", "en")
+
+// RequestedLocales lists the locales the client asked for, best first. ?lang= takes precedence over the
+// Accept-Language header.
+func RequestedLocales(r *http.Request) []string {
+	if lang := strings.TrimSpace(r.FormValue("lang")); lang != "" {
+		return []string{lang}
+	}
+	return ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// ParseAcceptLanguage reads an Accept-Language header like "de-AT, de;q=0.9, en;q=0.5" into its locales
+// ordered by quality. Locales with q=0 and the wildcard are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		locale  string
+		quality float64
+	}
+
+	var entries []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		locale := strings.TrimSpace(fields[0])
+		if locale == "" || locale == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		entries = append(entries, weighted{locale, quality})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].quality > entries[j].quality })
+
+	locales := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		locales = append(locales, entry.locale)
+	}
+	return locales
+}
+
+// NegotiateLocale picks the available locale that serves the requested ones best. Each requested locale is
+// tried as is, then by its language ("de-AT" finds "de") and then by any region of its language ("de" finds
+// "de-CH"), before the next requested locale is tried. It returns DEFAULT_LOCALE when nothing matches.
+func NegotiateLocale(requested []string, available []string) string {
+	for _, locale := range requested {
+		for _, candidate := range available {
+			if strings.EqualFold(candidate, locale) {
+				return candidate
+			}
+		}
+
+		language := baseLanguage(locale)
+		if strings.EqualFold(language, DEFAULT_LOCALE) {
+			return DEFAULT_LOCALE
+		}
+		for _, candidate := range available {
+			if strings.EqualFold(candidate, language) {
+				return candidate
+			}
+		}
+		for _, candidate := range available {
+			if strings.EqualFold(baseLanguage(candidate), language) {
+				return candidate
+			}
+		}
+	}
+	return DEFAULT_LOCALE
+}
+
+// LocalizeFood replaces the name and description of the food with the best translation for the requested
+// locales and returns the locale used.
+func LocalizeFood(food *models.Food, requested []string) string {
+	available := make([]string, 0, len(food.Translations))
+	for locale := range food.Translations {
+		available = append(available, locale)
+	}
+	sort.Strings(available)
+
+	locale := NegotiateLocale(requested, available)
+	translation, ok := food.Translations[locale]
+	if !ok {
+		return DEFAULT_LOCALE
+	}
+	if translation.Name != "" {
+		food.Name = &translation.Name
+	}
+	if translation.Description != "" {
+		food.Description = &translation.Description
+	}
+	return locale
+}
+
+// LocalizeMenu replaces the name and category of the menu with the best translation for the requested
+// locales and returns the locale used.
+func LocalizeMenu(menu *models.Menu, requested []string) string {
+	available := make([]string, 0, len(menu.Translations))
+	for locale := range menu.Translations {
+		available = append(available, locale)
+	}
+	sort.Strings(available)
+
+	locale := NegotiateLocale(requested, available)
+	translation, ok := menu.Translations[locale]
+	if !ok {
+		return DEFAULT_LOCALE
+	}
+	if translation.Name != "" {
+		menu.Name = translation.Name
+	}
+	if translation.Category != "" {
+		menu.Category = translation.Category
+	}
+	return locale
+}
+
+func baseLanguage(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		return locale[:i]
+	}
+	return locale
+}
@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"time"
+)
+
+var RESTAURANT_TIMEZONE string = envOrDefault("RESTAURANT_TIMEZONE", "")
+
+// MenuLocation is the timezone the dayparts of the menu are evaluated in.
+func MenuLocation(menu models.Menu) (*time.Location, error) {
+	if menu.Timezone != nil && *menu.Timezone != "" {
+		return time.LoadLocation(*menu.Timezone)
+	}
+	return RestaurantLocation()
+}
+
+// RestaurantLocation is the timezone of RESTAURANT_TIMEZONE, the server timezone when it is not set.
+func RestaurantLocation() (*time.Location, error) {
+	if RESTAURANT_TIMEZONE == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(RESTAURANT_TIMEZONE)
+}
+
+// ValidateDayparts checks that the clock times of the dayparts can be read.
+func ValidateDayparts(dayparts []models.Daypart) error {
+	for _, daypart := range dayparts {
+		for _, clock := range []string{daypart.StartTime, daypart.EndTime} {
+			if _, err := time.Parse("15:04", clock); err != nil {
+				return fmt.Errorf("daypart %s: %s is not a HH:MM time", daypart.Name, clock)
+			}
+		}
+		if daypart.StartTime == daypart.EndTime {
+			return fmt.Errorf("daypart %s starts and ends at the same time", daypart.Name)
+		}
+	}
+	return nil
+}
+
+// MenuActive reports whether the menu is served at the given moment and the daypart it is served in, which
+// is empty for menus served all day.
+func MenuActive(menu models.Menu, at time.Time) (bool, string) {
+	if menu.StartDate != nil && at.Before(*menu.StartDate) {
+		return false, ""
+	}
+	if menu.EndDate != nil && !at.Before(*menu.EndDate) {
+		return false, ""
+	}
+	if len(menu.Dayparts) == 0 {
+		return true, ""
+	}
+
+	location, err := MenuLocation(menu)
+	if err != nil {
+		location = time.Local
+	}
+	local := at.In(location)
+
+	for _, daypart := range menu.Dayparts {
+		if inTimeWindow(daypart.Weekdays, daypart.StartTime, daypart.EndTime, local) {
+			return true, daypart.Name
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PrepareModifierGroups gives ids to new groups and options and checks that every group can be satisfied.
+func PrepareModifierGroups(groups []models.ModifierGroup) error {
+	for i := range groups {
+		group := &groups[i]
+		if group.GroupId == "" {
+			group.GroupId = primitive.NewObjectID().Hex()
+		}
+		if group.MaxSelections > len(group.Options) {
+			return fmt.Errorf("modifier group %s allows %d selections but has only %d options", group.Name, group.MaxSelections, len(group.Options))
+		}
+		for j := range group.Options {
+			if group.Options[j].OptionId == "" {
+				group.Options[j].OptionId = primitive.NewObjectID().Hex()
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveModifiers checks the selected options against the modifier groups of a food and fills in their
+// names and price deltas. It returns the resolved selection and the sum of the price deltas.
+func ResolveModifiers(groups []models.ModifierGroup, selected []models.SelectedModifier) ([]models.SelectedModifier, float64, error) {
+	resolved := make([]models.SelectedModifier, 0, len(selected))
+	counts := map[string]int{}
+	seen := map[string]bool{}
+	delta := 0.0
+
+	for _, selection := range selected {
+		group := findModifierGroup(groups, selection.GroupId)
+		if group == nil {
+			return nil, 0, fmt.Errorf("modifier group %s does not exist for this food", selection.GroupId)
+		}
+
+		option := findModifierOption(group.Options, selection.OptionId)
+		if option == nil {
+			return nil, 0, fmt.Errorf("option %s does not exist in modifier group %s", selection.OptionId, group.Name)
+		}
+
+		if seen[option.OptionId] {
+			return nil, 0, fmt.Errorf("option %s of %s is selected more than once", option.Name, group.Name)
+		}
+		seen[option.OptionId] = true
+		counts[group.GroupId]++
+
+		resolved = append(resolved, models.SelectedModifier{
+			GroupId:    group.GroupId,
+			OptionId:   option.OptionId,
+			GroupName:  group.Name,
+			Name:       option.Name,
+			PriceDelta: option.PriceDelta,
+		})
+		delta += option.PriceDelta
+	}
+
+	for _, group := range groups {
+		count := counts[group.GroupId]
+		if count < group.MinSelections {
+			return nil, 0, fmt.Errorf("%s requires at least %d selections", group.Name, group.MinSelections)
+		}
+		if count > group.MaxSelections {
+			return nil, 0, fmt.Errorf("%s allows at most %d selections", group.Name, group.MaxSelections)
+		}
+	}
+
+	return resolved, RoundMoney(delta), nil
+}
+
+// ModifierLabel is how a selected modifier is printed, e.g. "Doneness: rare".
+func ModifierLabel(modifier models.SelectedModifier) string {
+	return fmt.Sprintf("%s: %s", modifier.GroupName, modifier.Name)
+}
+
+func findModifierGroup(groups []models.ModifierGroup, groupId string) *models.ModifierGroup {
+	for i := range groups {
+		if groups[i].GroupId == groupId {
+			return &groups[i]
+		}
+	}
+	return nil
+}
+
+func findModifierOption(options []models.ModifierOption, optionId string) *models.ModifierOption {
+	for i := range options {
+		if options[i].OptionId == optionId {
+			return &options[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package helpers
+
+import (
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"math"
+	"strings"
+	"time"
+)
+
+// PricedLine is a billable order item as seen by PriceOrder.
+type PricedLine struct {
+	OrderItemId string `json:"order_item_id"`
+	FoodId      string `json:"food_id"`
+	FoodName    string `json:"food_name"`
+	Portion     string `json:"portion"`
+	Category    string `json:"category"`
+	// Categories are the ids and names of the food category and its parents, which promotions match too.
+	Categories []string          `json:"categories,omitempty"`
+	ComboId    string            `json:"combo_id,omitempty"`
+	Components []PricedComponent `json:"components,omitempty"`
+	Modifiers  []string          `json:"modifiers"`
+	UnitPrice  float64           `json:"unit_price"`
+	Count      int               `json:"count"`
+	Total      float64           `json:"total"`
+	OrderedAt  time.Time         `json:"ordered_at"`
+}
+
+// PricedComponent is a food of a combo line. Allocated is its share of the line total, which tax is reported
+// by. PriceOrder computes it in proportion to the allocated prices the components are ordered with.
+type PricedComponent struct {
+	OrderItemId string   `json:"order_item_id"`
+	FoodId      string   `json:"food_id"`
+	FoodName    string   `json:"food_name"`
+	Portion     string   `json:"portion"`
+	Modifiers   []string `json:"modifiers"`
+	Allocated   float64  `json:"allocated"`
+}
+
+type AppliedDiscount = models.AppliedDiscount
+
+type OrderPricing struct {
+	Lines         []PricedLine      `json:"lines"`
+	Subtotal      float64           `json:"subtotal"`
+	Discounts     []AppliedDiscount `json:"discounts"`
+	DiscountTotal float64           `json:"discount_total"`
+	TaxRate       float64           `json:"tax_rate"`
+	Tax           float64           `json:"tax"`
+	Total         float64           `json:"total"`
+}
+
+// TAX_RATE is the sales tax in percent added on top of the discounted prices.
+var TAX_RATE float64 = envFloat("TAX_RATE", 0)
+
+// PriceOrder totals the order lines and applies the discounts in a fixed order: the best automatic item
+// promotion of each line, item-level promo codes and manual discounts, and finally the order-level ones on
+// whatever is left. A discount never takes a line or the order below zero. orderedAt is used to evaluate
+// automatic order-level promotions, item-level ones are evaluated at the time each item was ordered.
+func PriceOrder(lines []PricedLine, promotions []models.Promotion, discounts []models.Discount, orderedAt time.Time) OrderPricing {
+	pricing := OrderPricing{Lines: lines, Discounts: []AppliedDiscount{}}
+
+	promotionsById := make(map[string]models.Promotion, len(promotions))
+	for _, promotion := range promotions {
+		promotionsById[promotion.PromotionId] = promotion
+	}
+
+	remaining := make([]float64, len(lines))
+	for i := range lines {
+		lines[i].Total = RoundMoney(lines[i].UnitPrice * float64(lines[i].Count))
+		if len(lines[i].Components) > 0 {
+			weights := make([]float64, len(lines[i].Components))
+			for j, component := range lines[i].Components {
+				weights[j] = component.Allocated
+			}
+			for j, share := range AllocatePrice(lines[i].Total, weights) {
+				lines[i].Components[j].Allocated = share
+			}
+		}
+		remaining[i] = lines[i].Total
+		pricing.Subtotal += lines[i].Total
+	}
+	pricing.Subtotal = RoundMoney(pricing.Subtotal)
+
+	applyToLine := func(i int, discount AppliedDiscount, discountType string, value float64) {
+		amount := discountAmount(discountType, value, remaining[i], lines[i].Count)
+		if amount <= 0 {
+			return
+		}
+		remaining[i] = RoundMoney(remaining[i] - amount)
+		discount.OrderItemId = lines[i].OrderItemId
+		discount.Amount = amount
+		pricing.Discounts = append(pricing.Discounts, discount)
+	}
+
+	for i, line := range lines {
+		var best *models.Promotion
+		bestAmount := 0.0
+		for j, promotion := range promotions {
+			if promotion.Code != nil || *promotion.Scope != "ITEM" {
+				continue
+			}
+			if !PromotionActive(promotion, line.OrderedAt) || !promotionMatches(promotion, line) {
+				continue
+			}
+			if amount := discountAmount(*promotion.DiscountType, *promotion.Value, remaining[i], line.Count); amount > bestAmount {
+				best, bestAmount = &promotions[j], amount
+			}
+		}
+		if best != nil {
+			applyToLine(i, AppliedDiscount{
+				Source:      models.DiscountSourceAutomatic,
+				Description: *best.Name,
+				PromotionId: best.PromotionId,
+			}, *best.DiscountType, *best.Value)
+		}
+	}
+
+	var orderDiscounts []models.Discount
+	for _, discount := range discounts {
+		applied := AppliedDiscount{
+			Source:      discount.Source,
+			Description: discountDescription(discount, promotionsById),
+			DiscountId:  discount.DiscountId,
+		}
+		if discount.Code != nil {
+			applied.Code = *discount.Code
+		}
+
+		switch {
+		case discount.PromotionId != nil:
+			promotion, ok := promotionsById[*discount.PromotionId]
+			if !ok {
+				continue
+			}
+			applied.PromotionId = promotion.PromotionId
+			if *promotion.Scope != "ITEM" {
+				orderDiscounts = append(orderDiscounts, discount)
+				continue
+			}
+			for i, line := range lines {
+				if promotionMatches(promotion, line) {
+					applyToLine(i, applied, *discount.DiscountType, *discount.Value)
+				}
+			}
+		case discount.OrderItemId != nil:
+			for i, line := range lines {
+				if line.OrderItemId == *discount.OrderItemId {
+					applyToLine(i, applied, *discount.DiscountType, *discount.Value)
+				}
+			}
+		default:
+			orderDiscounts = append(orderDiscounts, discount)
+		}
+	}
+
+	base := 0.0
+	for _, amount := range remaining {
+		base += amount
+	}
+	base = RoundMoney(base)
+
+	for _, promotion := range promotions {
+		if promotion.Code != nil || *promotion.Scope != "ORDER" || !PromotionActive(promotion, orderedAt) {
+			continue
+		}
+		amount := discountAmount(*promotion.DiscountType, *promotion.Value, base, 1)
+		if amount <= 0 {
+			continue
+		}
+		base = RoundMoney(base - amount)
+		pricing.Discounts = append(pricing.Discounts, AppliedDiscount{
+			Source:      models.DiscountSourceAutomatic,
+			Description: *promotion.Name,
+			PromotionId: promotion.PromotionId,
+			Amount:      amount,
+		})
+	}
+
+	for _, discount := range orderDiscounts {
+		amount := discountAmount(*discount.DiscountType, *discount.Value, base, 1)
+		if amount <= 0 {
+			continue
+		}
+		base = RoundMoney(base - amount)
+		applied := AppliedDiscount{
+			Source:      discount.Source,
+			Description: discountDescription(discount, promotionsById),
+			DiscountId:  discount.DiscountId,
+			Amount:      amount,
+		}
+		if discount.PromotionId != nil {
+			applied.PromotionId = *discount.PromotionId
+		}
+		if discount.Code != nil {
+			applied.Code = *discount.Code
+		}
+		pricing.Discounts = append(pricing.Discounts, applied)
+	}
+
+	for _, discount := range pricing.Discounts {
+		pricing.DiscountTotal += discount.Amount
+	}
+	pricing.DiscountTotal = RoundMoney(pricing.DiscountTotal)
+	pricing.Total = RoundMoney(pricing.Subtotal - pricing.DiscountTotal)
+
+	return pricing
+}
+
+// ApplyTax adds the tax on the discounted amount to the total of the pricing.
+func ApplyTax(pricing *OrderPricing, taxRate float64) {
+	pricing.TaxRate = taxRate
+	pricing.Tax = RoundMoney((pricing.Subtotal - pricing.DiscountTotal) * taxRate / 100)
+	pricing.Total = RoundMoney(pricing.Subtotal - pricing.DiscountTotal + pricing.Tax)
+}
+
+// ValidateHappyHours checks that the clock times of the happy hours can be read, they are compared as text
+// so they must be HH:MM.
+func ValidateHappyHours(happyHours []models.HappyHour) error {
+	for _, happyHour := range happyHours {
+		for _, clock := range []string{happyHour.StartTime, happyHour.EndTime} {
+			if _, err := time.Parse("15:04", clock); err != nil || len(clock) != 5 {
+				return fmt.Errorf("happy hour %s is not a HH:MM time", clock)
+			}
+		}
+		if happyHour.StartTime == happyHour.EndTime {
+			return fmt.Errorf("happy hour starts and ends at %s", happyHour.StartTime)
+		}
+	}
+	return nil
+}
+
+// PromotionActive reports whether the promotion can be applied at the given moment.
+func PromotionActive(promotion models.Promotion, at time.Time) bool {
+	if promotion.Active != nil && !*promotion.Active {
+		return false
+	}
+	if promotion.ValidFrom != nil && at.Before(*promotion.ValidFrom) {
+		return false
+	}
+	if promotion.ValidUntil != nil && at.After(*promotion.ValidUntil) {
+		return false
+	}
+	if len(promotion.HappyHours) == 0 {
+		return true
+	}
+
+	for _, happyHour := range promotion.HappyHours {
+		if inTimeWindow(happyHour.Weekdays, happyHour.StartTime, happyHour.EndTime, at.Local()) {
+			return true
+		}
+	}
+	return false
+}
+
+// inTimeWindow reports whether at falls between the "15:04" clock times start and end on one of the weekdays.
+func inTimeWindow(weekdays []int, start string, end string, at time.Time) bool {
+	clock := at.Format("15:04")
+
+	if start <= end {
+		return onWeekday(weekdays, at.Weekday()) && clock >= start && clock < end
+	}
+
+	// the window crosses midnight, so the early morning part belongs to the previous day
+	if clock >= start {
+		return onWeekday(weekdays, at.Weekday())
+	}
+	return clock < end && onWeekday(weekdays, at.AddDate(0, 0, -1).Weekday())
+}
+
+func onWeekday(weekdays []int, weekday time.Weekday) bool {
+	if len(weekdays) == 0 {
+		return true
+	}
+	for _, day := range weekdays {
+		if time.Weekday(day) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func promotionMatches(promotion models.Promotion, line PricedLine) bool {
+	if len(promotion.FoodIds) == 0 && len(promotion.Categories) == 0 {
+		return true
+	}
+	for _, foodId := range promotion.FoodIds {
+		if foodId == line.FoodId {
+			return true
+		}
+	}
+	for _, category := range promotion.Categories {
+		if strings.EqualFold(category, line.Category) {
+			return true
+		}
+		for _, lineCategory := range line.Categories {
+			if strings.EqualFold(category, lineCategory) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// discountAmount returns how much a discount takes off amount. Fixed item discounts apply per unit.
+func discountAmount(discountType string, value float64, amount float64, count int) float64 {
+	var discount float64
+	switch discountType {
+	case "PERCENTAGE":
+		discount = amount * value / 100
+	case "FIXED":
+		discount = value * float64(count)
+	}
+	return RoundMoney(math.Min(discount, amount))
+}
+
+func discountDescription(discount models.Discount, promotions map[string]models.Promotion) string {
+	if discount.PromotionId != nil {
+		if promotion, ok := promotions[*discount.PromotionId]; ok {
+			return *promotion.Name
+		}
+	}
+	if discount.Reason != nil {
+		return fmt.Sprintf("Manual discount: %s", *discount.Reason)
+	}
+	return "Manual discount"
+}
+
+func RoundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
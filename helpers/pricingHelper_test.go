@@ -0,0 +1,232 @@
+package helpers
+
+import (
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func promotion(id string, scope string, discountType string, value float64) models.Promotion {
+	return models.Promotion{
+		Name:         stringPtr(id),
+		DiscountType: stringPtr(discountType),
+		Value:        floatPtr(value),
+		Scope:        stringPtr(scope),
+		PromotionId:  id,
+	}
+}
+
+// friday is 2024-01-05 in the local timezone, which PromotionActive compares happy hours in.
+func friday(hour int, minute int) time.Time {
+	return time.Date(2024, time.January, 5, hour, minute, 0, 0, time.Local)
+}
+
+func TestAllocatePrice(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   float64
+		weights []float64
+		want    []float64
+	}{
+		{"no components", 10, nil, []float64{}},
+		{"proportional", 9, []float64{6, 3}, []float64{6, 3}},
+		{"rounding goes to the largest share", 10, []float64{1, 1, 1}, []float64{3.34, 3.33, 3.33}},
+		{"rounding down comes off the largest share", 20, []float64{1, 1, 1}, []float64{6.66, 6.67, 6.67}},
+		{"without weights the price is split evenly", 10, []float64{0, 0}, []float64{5, 5}},
+		{"negative weights count as zero", 10, []float64{-1, 1}, []float64{0, 10}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := AllocatePrice(test.total, test.weights)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("AllocatePrice(%v, %v) = %v, want %v", test.total, test.weights, got, test.want)
+			}
+
+			sum := 0.0
+			for _, share := range got {
+				sum += share
+			}
+			if len(got) > 0 && RoundMoney(sum) != test.total {
+				t.Fatalf("shares %v add up to %v, want %v", got, RoundMoney(sum), test.total)
+			}
+		})
+	}
+}
+
+func TestInTimeWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		weekdays []int
+		start    string
+		end      string
+		at       time.Time
+		want     bool
+	}{
+		{"inside", nil, "15:00", "18:00", friday(16, 0), true},
+		{"start is included", nil, "15:00", "18:00", friday(15, 0), true},
+		{"end is excluded", nil, "15:00", "18:00", friday(18, 0), false},
+		{"other weekday", []int{6}, "15:00", "18:00", friday(16, 0), false},
+		{"before midnight", []int{5}, "22:00", "02:00", friday(23, 0), true},
+		{"after midnight belongs to the previous day", []int{5}, "22:00", "02:00", friday(25, 0), true},
+		{"after midnight of the listed day", []int{5}, "22:00", "02:00", friday(1, 0), false},
+		{"after the window crossing midnight", []int{5}, "22:00", "02:00", friday(26, 0), false},
+		{"before the window crossing midnight", nil, "22:00", "02:00", friday(21, 59), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := inTimeWindow(test.weekdays, test.start, test.end, test.at); got != test.want {
+				t.Fatalf("inTimeWindow(%v, %s, %s, %s) = %v, want %v", test.weekdays, test.start, test.end, test.at, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPriceOrder(t *testing.T) {
+	happyHour := promotion("late", "ITEM", "PERCENTAGE", 50)
+	happyHour.HappyHours = []models.HappyHour{{Weekdays: []int{5}, StartTime: "22:00", EndTime: "02:00"}}
+
+	inactive := promotion("off", "ITEM", "PERCENTAGE", 90)
+	inactive.Active = new(bool)
+
+	coded := promotion("coded", "ITEM", "PERCENTAGE", 90)
+	coded.Code = stringPtr("SAVE90")
+
+	tests := []struct {
+		name          string
+		lines         []PricedLine
+		promotions    []models.Promotion
+		discounts     []models.Discount
+		wantDiscounts []float64
+		wantTotal     float64
+	}{
+		{
+			name:      "no discounts",
+			lines:     []PricedLine{{OrderItemId: "a", UnitPrice: 10, Count: 2, OrderedAt: friday(12, 0)}},
+			wantTotal: 20,
+		},
+		{
+			name:          "the best item promotion of a line applies",
+			lines:         []PricedLine{{OrderItemId: "a", UnitPrice: 10, Count: 2, OrderedAt: friday(12, 0)}},
+			promotions:    []models.Promotion{promotion("tenth", "ITEM", "PERCENTAGE", 10), promotion("fixed", "ITEM", "FIXED", 1.5)},
+			wantDiscounts: []float64{3},
+			wantTotal:     17,
+		},
+		{
+			name:       "inactive and coded promotions only apply when entered",
+			lines:      []PricedLine{{OrderItemId: "a", UnitPrice: 10, Count: 1, OrderedAt: friday(12, 0)}},
+			promotions: []models.Promotion{inactive, coded},
+			wantTotal:  10,
+		},
+		{
+			name:       "item, order and manual discounts stack in order",
+			lines:      []PricedLine{{OrderItemId: "a", UnitPrice: 10, Count: 2, OrderedAt: friday(12, 0)}},
+			promotions: []models.Promotion{promotion("tenth", "ITEM", "PERCENTAGE", 10), promotion("order", "ORDER", "PERCENTAGE", 10)},
+			discounts: []models.Discount{
+				{Source: models.DiscountSourceManual, DiscountType: stringPtr("FIXED"), Value: floatPtr(5), DiscountId: "manual"},
+			},
+			wantDiscounts: []float64{2, 1.8, 5},
+			wantTotal:     11.2,
+		},
+		{
+			name:  "a manual item discount only takes off its line",
+			lines: []PricedLine{{OrderItemId: "a", UnitPrice: 10, Count: 1}, {OrderItemId: "b", UnitPrice: 4, Count: 1}},
+			discounts: []models.Discount{
+				{Source: models.DiscountSourceManual, DiscountType: stringPtr("PERCENTAGE"), Value: floatPtr(50), OrderItemId: stringPtr("b"), DiscountId: "manual"},
+			},
+			wantDiscounts: []float64{2},
+			wantTotal:     12,
+		},
+		{
+			name:  "discounts never take the order below zero",
+			lines: []PricedLine{{OrderItemId: "a", UnitPrice: 10, Count: 2}},
+			discounts: []models.Discount{
+				{Source: models.DiscountSourceManual, DiscountType: stringPtr("FIXED"), Value: floatPtr(50), DiscountId: "first"},
+				{Source: models.DiscountSourceManual, DiscountType: stringPtr("FIXED"), Value: floatPtr(5), DiscountId: "second"},
+			},
+			wantDiscounts: []float64{20},
+			wantTotal:     0,
+		},
+		{
+			name:          "a happy hour crossing midnight applies after midnight",
+			lines:         []PricedLine{{OrderItemId: "a", UnitPrice: 10, Count: 1, OrderedAt: friday(25, 30)}},
+			promotions:    []models.Promotion{happyHour},
+			wantDiscounts: []float64{5},
+			wantTotal:     5,
+		},
+		{
+			name:       "a happy hour crossing midnight ends the next morning",
+			lines:      []PricedLine{{OrderItemId: "a", UnitPrice: 10, Count: 1, OrderedAt: friday(26, 30)}},
+			promotions: []models.Promotion{happyHour},
+			wantTotal:  10,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pricing := PriceOrder(test.lines, test.promotions, test.discounts, friday(12, 0))
+
+			amounts := []float64{}
+			for _, discount := range pricing.Discounts {
+				amounts = append(amounts, discount.Amount)
+			}
+			wantDiscounts := test.wantDiscounts
+			if wantDiscounts == nil {
+				wantDiscounts = []float64{}
+			}
+			if !reflect.DeepEqual(amounts, wantDiscounts) {
+				t.Fatalf("discounts = %v, want %v", amounts, wantDiscounts)
+			}
+			if pricing.Total != test.wantTotal {
+				t.Fatalf("total = %v, want %v", pricing.Total, test.wantTotal)
+			}
+			if RoundMoney(pricing.Subtotal-pricing.DiscountTotal) != pricing.Total {
+				t.Fatalf("subtotal %v minus discounts %v is not the total %v", pricing.Subtotal, pricing.DiscountTotal, pricing.Total)
+			}
+		})
+	}
+}
+
+func TestApplyTax(t *testing.T) {
+	pricing := OrderPricing{Subtotal: 20, DiscountTotal: 2.5}
+	ApplyTax(&pricing, 8)
+
+	if pricing.Tax != 1.4 || pricing.Total != 18.9 {
+		t.Fatalf("tax = %v and total = %v, want 1.4 and 18.9", pricing.Tax, pricing.Total)
+	}
+}
+
+func TestValidateHappyHours(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   string
+		end     string
+		wantErr bool
+	}{
+		{"same day", "15:00", "18:00", false},
+		{"crossing midnight", "22:00", "02:00", false},
+		{"hour out of range", "25:00", "02:00", true},
+		{"single digit hour", "9:00", "12:00", true},
+		{"not a time", "noon", "14:00", true},
+		{"empty window", "15:00", "15:00", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateHappyHours([]models.HappyHour{{StartTime: test.start, EndTime: test.end}})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ValidateHappyHours(%s, %s) = %v, want an error: %v", test.start, test.end, err, test.wantErr)
+			}
+		})
+	}
+}
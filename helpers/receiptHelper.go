@@ -0,0 +1,289 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type ReceiptPayment struct {
+	Method string  `json:"method"`
+	Amount float64 `json:"amount"`
+}
+
+// Receipt is everything printed on a customer receipt, independent of the output format.
+type Receipt struct {
+	RestaurantName    string            `json:"restaurant_name"`
+	RestaurantAddress string            `json:"restaurant_address"`
+	RestaurantPhone   string            `json:"restaurant_phone"`
+	InvoiceNumber     string            `json:"invoice_number"`
+	IssuedAt          time.Time         `json:"issued_at"`
+	TableNumber       string            `json:"table_number"`
+	Status            string            `json:"status"`
+	Lines             []PricedLine      `json:"lines"`
+	Discounts         []AppliedDiscount `json:"discounts"`
+	Subtotal          float64           `json:"subtotal"`
+	DiscountTotal     float64           `json:"discount_total"`
+	TaxRate           float64           `json:"tax_rate"`
+	Tax               float64           `json:"tax"`
+	Total             float64           `json:"total"`
+	Payments          []ReceiptPayment  `json:"payments"`
+	Footer            string            `json:"footer"`
+}
+
+var RESTAURANT_NAME string = envOrDefault("RESTAURANT_NAME", "Restaurant")
+var RESTAURANT_ADDRESS string = envOrDefault("RESTAURANT_ADDRESS", "")
+var RESTAURANT_PHONE string = envOrDefault("RESTAURANT_PHONE", "")
+var RECEIPT_FOOTER string = envOrDefault("RECEIPT_FOOTER", "Thank you for your visit!")
+
+// NewReceipt fills the restaurant header and footer of a receipt from the environment.
+func NewReceipt() Receipt {
+	return Receipt{
+		RestaurantName:    RESTAURANT_NAME,
+		RestaurantAddress: RESTAURANT_ADDRESS,
+		RestaurantPhone:   RESTAURANT_PHONE,
+		Footer:            RECEIPT_FOOTER,
+	}
+}
+
+// RenderReceiptText lays the receipt out in fixed columns for thermal printers, usually 40 or 48 wide.
+func RenderReceiptText(receipt Receipt, width int) []byte {
+	var lines []string
+	separator := strings.Repeat("-", width)
+
+	for _, header := range []string{receipt.RestaurantName, receipt.RestaurantAddress, receipt.RestaurantPhone} {
+		if header != "" {
+			lines = append(lines, centerText(header, width))
+		}
+	}
+	lines = append(lines, separator)
+
+	lines = append(lines, twoColumns("Invoice", receipt.InvoiceNumber, width)...)
+	lines = append(lines, twoColumns("Date", receipt.IssuedAt.Format("2006-01-02 15:04"), width)...)
+	if receipt.TableNumber != "" {
+		lines = append(lines, twoColumns("Table", receipt.TableNumber, width)...)
+	}
+	lines = append(lines, separator)
+
+	for _, line := range receipt.Lines {
+		lines = append(lines, twoColumns(fmt.Sprintf("%d x %s", line.Count, lineName(line)), money(line.Total), width)...)
+		for _, modifier := range line.Modifiers {
+			lines = append(lines, "  + "+modifier)
+		}
+		// the shares of a combo are printed in brackets, they are part of the combo total
+		for _, component := range line.Components {
+			lines = append(lines, twoColumns("  - "+componentName(component), "("+money(component.Allocated)+")", width)...)
+			for _, modifier := range component.Modifiers {
+				lines = append(lines, "    + "+modifier)
+			}
+		}
+		if line.Count > 1 {
+			lines = append(lines, fmt.Sprintf("    @ %s", money(line.UnitPrice)))
+		}
+	}
+	for _, discount := range receipt.Discounts {
+		lines = append(lines, twoColumns(discount.Description, money(-discount.Amount), width)...)
+	}
+	lines = append(lines, separator)
+
+	lines = append(lines, twoColumns("Subtotal", money(receipt.Subtotal), width)...)
+	if receipt.DiscountTotal > 0 {
+		lines = append(lines, twoColumns("Discounts", money(-receipt.DiscountTotal), width)...)
+	}
+	if receipt.TaxRate > 0 {
+		lines = append(lines, twoColumns(fmt.Sprintf("Tax %g%%", receipt.TaxRate), money(receipt.Tax), width)...)
+	}
+	lines = append(lines, twoColumns("TOTAL", money(receipt.Total), width)...)
+
+	if len(receipt.Payments) > 0 {
+		lines = append(lines, separator)
+		for _, payment := range receipt.Payments {
+			lines = append(lines, twoColumns(payment.Method, money(payment.Amount), width)...)
+		}
+	}
+	if receipt.Status != "" && receipt.Status != "PAID" {
+		lines = append(lines, twoColumns("Status", receipt.Status, width)...)
+	}
+
+	if receipt.Footer != "" {
+		lines = append(lines, separator, centerText(receipt.Footer, width))
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+var receiptTemplate = template.Must(template.New("receipt").Funcs(template.FuncMap{
+	"money":         money,
+	"lineName":      lineName,
+	"componentName": componentName,
+	"neg":           func(amount float64) float64 { return -amount },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.InvoiceNumber}}</title>
+<style>
+body { font-family: monospace; max-width: 26em; margin: 1em auto; }
+header, footer { text-align: center; }
+table { width: 100%; border-collapse: collapse; }
+td.amount { text-align: right; white-space: nowrap; }
+tr.total td { font-weight: bold; border-top: 1px solid; }
+tr.component td { color: #555; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.RestaurantName}}</h1>
+{{if .RestaurantAddress}}<div>{{.RestaurantAddress}}</div>{{end}}
+{{if .RestaurantPhone}}<div>{{.RestaurantPhone}}</div>{{end}}
+</header>
+<p>Invoice {{.InvoiceNumber}}<br>{{.IssuedAt.Format "2006-01-02 15:04"}}{{if .TableNumber}}<br>Table {{.TableNumber}}{{end}}</p>
+<table>
+{{range .Lines}}<tr><td>{{.Count}} x {{lineName .}}{{range .Modifiers}}<br>&nbsp;&nbsp;+ {{.}}{{end}}</td><td class="amount">{{money .Total}}</td></tr>
+{{range .Components}}<tr class="component"><td>&nbsp;&nbsp;- {{componentName .}}{{range .Modifiers}}<br>&nbsp;&nbsp;&nbsp;&nbsp;+ {{.}}{{end}}</td><td class="amount">({{money .Allocated}})</td></tr>
+{{end}}{{end}}{{range .Discounts}}<tr><td>{{.Description}}</td><td class="amount">{{money (neg .Amount)}}</td></tr>
+{{end}}<tr><td>Subtotal</td><td class="amount">{{money .Subtotal}}</td></tr>
+{{if .DiscountTotal}}<tr><td>Discounts</td><td class="amount">{{money (neg .DiscountTotal)}}</td></tr>
+{{end}}{{if .TaxRate}}<tr><td>Tax {{.TaxRate}}%</td><td class="amount">{{money .Tax}}</td></tr>
+{{end}}<tr class="total"><td>TOTAL</td><td class="amount">{{money .Total}}</td></tr>
+{{range .Payments}}<tr><td>{{.Method}}</td><td class="amount">{{money .Amount}}</td></tr>
+{{end}}</table>
+{{if and .Status (ne .Status "PAID")}}<p>Status: {{.Status}}</p>{{end}}
+{{if .Footer}}<footer>{{.Footer}}</footer>{{end}}
+</body>
+</html>
+`))
+
+func RenderReceiptHTML(receipt Receipt) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := receiptTemplate.Execute(&buf, receipt); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderReceiptPDF prints the 48 column text layout on a single page the size of a receipt roll,
+// using the built-in Courier font so that no font has to be embedded.
+func RenderReceiptPDF(receipt Receipt) []byte {
+	const width = 48
+	const fontSize = 9.0
+	const leading = 11.0
+	const margin = 18.0
+
+	lines := strings.Split(strings.TrimRight(string(RenderReceiptText(receipt, width)), "\n"), "\n")
+	pageWidth := margin*2 + width*fontSize*0.6
+	pageHeight := margin*2 + float64(len(lines))*leading
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT\n/F1 %g Tf\n%g TL\n%g %g Td\n", fontSize, leading, margin, pageHeight-margin-fontSize+leading)
+	for _, line := range lines {
+		fmt.Fprintf(&content, "(%s) '\n", pdfString(line))
+	}
+	content.WriteString("ET\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return pdf.Bytes()
+}
+
+// pdfString escapes a line for a PDF string literal. Characters outside Latin-1 cannot be shown by the
+// standard fonts and are replaced.
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func lineName(line PricedLine) string {
+	if line.Portion == "" {
+		return line.FoodName
+	}
+	return fmt.Sprintf("%s (%s)", line.FoodName, line.Portion)
+}
+
+func componentName(component PricedComponent) string {
+	if component.Portion == "" {
+		return component.FoodName
+	}
+	return fmt.Sprintf("%s (%s)", component.FoodName, component.Portion)
+}
+
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+func centerText(text string, width int) string {
+	length := utf8.RuneCountInString(text)
+	if length >= width {
+		return text
+	}
+	return strings.Repeat(" ", (width-length)/2) + text
+}
+
+// twoColumns puts left and right on the same line, wrapping left when both do not fit.
+func twoColumns(left string, right string, width int) []string {
+	var lines []string
+	available := width - utf8.RuneCountInString(right) - 1
+
+	words := strings.Fields(left)
+	current := ""
+	for _, word := range words {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if utf8.RuneCountInString(candidate) > available && current != "" {
+			lines = append(lines, current)
+			current = "  " + word
+			continue
+		}
+		current = candidate
+	}
+
+	padding := width - utf8.RuneCountInString(current) - utf8.RuneCountInString(right)
+	if padding < 1 {
+		lines = append(lines, current)
+		current = ""
+		padding = width - utf8.RuneCountInString(right)
+	}
+
+	return append(lines, current+strings.Repeat(" ", padding)+right)
+}
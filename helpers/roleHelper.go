@@ -0,0 +1,15 @@
+package helpers
+
+import "net/http"
+
+// HasRole reports whether the authenticated user of the request holds one of the given roles.
+// The role is put into the request headers by middleware.Authentication.
+func HasRole(r *http.Request, roles ...string) bool {
+	role := r.Header.Get("role")
+	for _, allowed := range roles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
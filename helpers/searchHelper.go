@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// SearchField is a text a search looks at, matches in fields with a higher Weight rank first.
+type SearchField struct {
+	Text   string
+	Weight float64
+}
+
+// SearchTerms splits a text into lowercase words without accents, so "Crème brûlée" is found as "creme brulee".
+func SearchTerms(text string) []string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+	if err != nil {
+		folded = text
+	}
+	return strings.FieldsFunc(strings.ToLower(folded), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	})
+}
+
+// SearchScore ranks the fields against the query terms, it is 0 unless every term is found in some field.
+// A term matches a word exactly, as its prefix ("burg" finds "burger") or with typos: one for terms of 4
+// letters and two for terms of 8. Each term counts with the best match it has, weighted by its field.
+func SearchScore(terms []string, fields []SearchField) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+
+	fieldWords := make([][]string, len(fields))
+	for i, field := range fields {
+		fieldWords[i] = SearchTerms(field.Text)
+	}
+
+	score := 0.0
+	for _, term := range terms {
+		best := 0.0
+		for i, field := range fields {
+			for _, word := range fieldWords[i] {
+				if match := termMatch(term, word) * field.Weight; match > best {
+					best = match
+				}
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		score += best
+	}
+
+	// the whole query as a phrase, e.g. "fish and chips"
+	if len(terms) > 1 {
+		phrase := strings.Join(terms, " ")
+		for i, field := range fields {
+			if strings.Contains(strings.Join(fieldWords[i], " "), phrase) {
+				score += field.Weight
+				break
+			}
+		}
+	}
+	return score
+}
+
+// termMatch tells how well a query term matches a word, from 1 for the same word down to 0.
+func termMatch(term string, word string) float64 {
+	if term == word {
+		return 1
+	}
+	if strings.HasPrefix(word, term) {
+		return 0.8
+	}
+
+	allowed := allowedTypos(term)
+	if allowed == 0 {
+		return 0
+	}
+	if editDistance(term, word) <= allowed {
+		return 0.6
+	}
+	// a misspelt prefix while typing, e.g. "buger" for "burgers"
+	if prefix := []rune(word); len(prefix) > len([]rune(term)) && editDistance(term, string(prefix[:len([]rune(term))])) <= allowed {
+		return 0.4
+	}
+	return 0
+}
+
+func allowedTypos(term string) int {
+	switch length := len([]rune(term)); {
+	case length >= 8:
+		return 2
+	case length >= 4:
+		return 1
+	}
+	return 0
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of neighbouring letters that turn
+// a into b.
+func editDistance(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(s)][len(t)]
+}
@@ -18,6 +18,7 @@ type SignedDetails struct {
 	FirstName        string
 	SecondName       string
 	Uid              string
+	Role             string
 	RegisteredClaims jwt.RegisteredClaims
 }
 
@@ -25,12 +26,13 @@ var userCollection = database.OpenCollection(database.Client, "user")
 
 var SECRET_KEY string = os.Getenv("SECRET_KEY")
 
-func GenerateAllTokens(email string, firstName string, secondName string, uid string) (signedToken, signedRefreshToken string, err error) {
+func GenerateAllTokens(email string, firstName string, secondName string, uid string, role string) (signedToken, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
 		Email:      email,
 		FirstName:  firstName,
 		SecondName: secondName,
 		Uid:        uid,
+		Role:       role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Local().Add(time.Hour * time.Duration(24))),
 		},
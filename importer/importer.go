@@ -0,0 +1,467 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+var menuCollection = database.OpenCollection(database.Client, "menu")
+var categoryCollection = database.OpenCollection(database.Client, "category")
+var foodCollection = database.OpenCollection(database.Client, "food")
+var foodPriceCollection = database.OpenCollection(database.Client, "food_price")
+var validate = validator.New()
+
+const (
+	TypeMenu     = "MENU"
+	TypeCategory = "CATEGORY"
+	TypeFood     = "FOOD"
+)
+
+// Batch is the content of an import file. Rows refer to each other, and to what was imported before, by sku:
+// foods by menu_sku and category_sku, categories by parent_sku. Existing records can also be referred to by
+// their menu_id, category_id or parent_id.
+type Batch struct {
+	Menus      []MenuRow     `json:"menus"`
+	Categories []CategoryRow `json:"categories"`
+	Foods      []FoodRow     `json:"foods"`
+	// Errors are the rows that could not be read
+	Errors []RowError `json:"-"`
+}
+
+type MenuRow struct {
+	models.Menu
+	Row int `json:"-"`
+}
+
+type CategoryRow struct {
+	models.Category
+	ParentSku string `json:"parent_sku"`
+	Row       int    `json:"-"`
+}
+
+type FoodRow struct {
+	models.Food
+	MenuSku     string `json:"menu_sku"`
+	CategorySku string `json:"category_sku"`
+	Row         int    `json:"-"`
+}
+
+// RowError is a row that cannot be imported, Row is the line of a CSV file or the position in its list of a
+// JSON file, counted from 1.
+type RowError struct {
+	Type    string `json:"type"`
+	Row     int    `json:"row"`
+	Sku     string `json:"sku"`
+	Message string `json:"message"`
+}
+
+type Counts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// Result tells what an import did, or would do on a dry run. Nothing is written when there are errors.
+type Result struct {
+	DryRun     bool       `json:"dry_run"`
+	Menus      Counts     `json:"menus"`
+	Categories Counts     `json:"categories"`
+	Foods      Counts     `json:"foods"`
+	Errors     []RowError `json:"errors"`
+}
+
+// plan is a validated batch with every row resolved to the record it creates or replaces.
+type plan struct {
+	menus      []planned
+	categories []planned
+	foods      []planned
+	prices     []models.FoodPrice
+}
+
+type planned struct {
+	id       primitive.ObjectID
+	document interface{}
+	existing bool
+}
+
+// Run validates the batch with the rules of the models and, unless it is a dry run or a row has errors,
+// creates the new records and replaces the ones with a known sku in one transaction. Importing the same
+// batch twice changes nothing the second time.
+func Run(ctx context.Context, batch Batch, dryRun bool, uid string) (Result, error) {
+	result := Result{DryRun: dryRun, Errors: append([]RowError{}, batch.Errors...)}
+
+	imported, err := prepare(ctx, batch, uid, &result)
+	if err != nil || dryRun || len(result.Errors) > 0 {
+		return result, err
+	}
+
+	session, err := database.Client.StartSession()
+	if err != nil {
+		return result, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		for _, step := range []struct {
+			collection *mongo.Collection
+			records    []planned
+		}{
+			{menuCollection, imported.menus},
+			{categoryCollection, imported.categories},
+			{foodCollection, imported.foods},
+		} {
+			for _, record := range step.records {
+				if err := save(sessCtx, step.collection, record); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, price := range imported.prices {
+			if _, err := foodPriceCollection.InsertOne(sessCtx, price); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	return result, err
+}
+
+func save(ctx context.Context, collection *mongo.Collection, record planned) error {
+	if record.existing {
+		_, err := collection.ReplaceOne(ctx, bson.M{"_id": record.id}, record.document)
+		return err
+	}
+	_, err := collection.InsertOne(ctx, record.document)
+	return err
+}
+
+// prepare checks every row and resolves the references, the row errors are added to the result.
+func prepare(ctx context.Context, batch Batch, uid string, result *Result) (plan, error) {
+	var imported plan
+	now, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+
+	var menus []models.Menu
+	var categories []models.Category
+	var foods []models.Food
+	if err := findAll(ctx, menuCollection, &menus); err != nil {
+		return imported, err
+	}
+	if err := findAll(ctx, categoryCollection, &categories); err != nil {
+		return imported, err
+	}
+	if err := findBySku(ctx, foodCollection, foodSkus(batch.Foods), &foods); err != nil {
+		return imported, err
+	}
+
+	rowError := func(kind string, row int, sku *string, format string, args ...interface{}) {
+		result.Errors = append(result.Errors, RowError{Type: kind, Row: row, Sku: value(sku), Message: fmt.Sprintf(format, args...)})
+	}
+
+	// menus
+	menuIds := map[string]bool{}
+	menusBySku := map[string]models.Menu{}
+	for _, menu := range menus {
+		menuIds[menu.MenuId] = true
+		if menu.Sku != nil {
+			menusBySku[*menu.Sku] = menu
+		}
+	}
+	seen := map[string]bool{}
+	for _, row := range batch.Menus {
+		menu := row.Menu
+		if !checkSku(menu.Sku, seen, func(message string) { rowError(TypeMenu, row.Row, menu.Sku, message) }) {
+			continue
+		}
+		if err := validate.Struct(menu); err != nil {
+			rowError(TypeMenu, row.Row, menu.Sku, "%s", err)
+			continue
+		}
+		if err := helpers.ValidateDayparts(menu.Dayparts); err != nil {
+			rowError(TypeMenu, row.Row, menu.Sku, "%s", err)
+			continue
+		}
+		if menu.StartDate != nil && menu.EndDate != nil && !menu.EndDate.After(*menu.StartDate) {
+			rowError(TypeMenu, row.Row, menu.Sku, "end_date must be after start_date")
+			continue
+		}
+
+		current, existing := menusBySku[*menu.Sku]
+		if existing {
+			menu.ID, menu.MenuId, menu.CreatedAt = current.ID, current.MenuId, current.CreatedAt
+			menu.PublishedVersionId = current.PublishedVersionId
+			result.Menus.Updated++
+		} else {
+			menu.ID = primitive.NewObjectID()
+			menu.MenuId = menu.ID.Hex()
+			menu.CreatedAt = now
+			result.Menus.Created++
+		}
+		menu.UpdatedAt = now
+		menusBySku[*menu.Sku] = menu
+		menuIds[menu.MenuId] = true
+		imported.menus = append(imported.menus, planned{id: menu.ID, document: menu, existing: existing})
+	}
+
+	// categories, the imported ones replace their current version before the hierarchy is checked
+	byId := map[string]models.Category{}
+	categoriesBySku := map[string]models.Category{}
+	for _, category := range categories {
+		byId[category.CategoryId] = category
+		if category.Sku != nil {
+			categoriesBySku[*category.Sku] = category
+		}
+	}
+	seen = map[string]bool{}
+	var importedCategories []CategoryRow
+	for _, row := range batch.Categories {
+		if !checkSku(row.Sku, seen, func(message string) { rowError(TypeCategory, row.Row, row.Sku, message) }) {
+			continue
+		}
+		if current, ok := categoriesBySku[*row.Sku]; ok {
+			row.ID, row.CategoryId, row.CreatedAt = current.ID, current.CategoryId, current.CreatedAt
+		} else {
+			row.ID = primitive.NewObjectID()
+			row.CategoryId = row.ID.Hex()
+			row.CreatedAt = now
+		}
+		row.UpdatedAt = now
+		categoriesBySku[*row.Sku] = row.Category
+		importedCategories = append(importedCategories, row)
+	}
+	for i := range importedCategories {
+		row := &importedCategories[i]
+		if row.ParentSku != "" {
+			parent, ok := categoriesBySku[row.ParentSku]
+			if !ok {
+				rowError(TypeCategory, row.Row, row.Sku, "parent category %s was not found", row.ParentSku)
+				continue
+			}
+			row.ParentId = &parent.CategoryId
+		}
+		if row.ParentId != nil && *row.ParentId == "" {
+			row.ParentId = nil
+		}
+		byId[row.CategoryId] = row.Category
+	}
+	siblings := map[string]string{}
+	for _, category := range byId {
+		if category.Name != nil {
+			siblings[value(category.ParentId)+"/"+strings.ToLower(*category.Name)] = category.CategoryId
+		}
+	}
+	for _, row := range importedCategories {
+		category := row.Category
+		if err := validate.Struct(category); err != nil {
+			rowError(TypeCategory, row.Row, category.Sku, "%s", err)
+			continue
+		}
+		if category.ParentId != nil {
+			if _, ok := byId[*category.ParentId]; !ok {
+				rowError(TypeCategory, row.Row, category.Sku, "parent category %s was not found", *category.ParentId)
+				continue
+			}
+			// a path that does not end at a top level category runs in a circle
+			path := helpers.CategoryPath(byId, category.CategoryId)
+			if top := path[len(path)-1]; top.ParentId != nil {
+				rowError(TypeCategory, row.Row, category.Sku, "a category cannot be moved below itself")
+				continue
+			}
+		}
+		if siblings[value(category.ParentId)+"/"+strings.ToLower(*category.Name)] != category.CategoryId {
+			rowError(TypeCategory, row.Row, category.Sku, "category %s already exists there", *category.Name)
+			continue
+		}
+
+		_, existing := findCategory(categories, category.CategoryId)
+		if existing {
+			result.Categories.Updated++
+		} else {
+			result.Categories.Created++
+		}
+		imported.categories = append(imported.categories, planned{id: category.ID, document: category, existing: existing})
+	}
+
+	// foods
+	foodsBySku := map[string]models.Food{}
+	for _, food := range foods {
+		foodsBySku[*food.Sku] = food
+	}
+	seen = map[string]bool{}
+	for _, row := range batch.Foods {
+		food := row.Food
+		if !checkSku(food.Sku, seen, func(message string) { rowError(TypeFood, row.Row, food.Sku, message) }) {
+			continue
+		}
+		if row.MenuSku != "" {
+			menu, ok := menusBySku[row.MenuSku]
+			if !ok {
+				rowError(TypeFood, row.Row, food.Sku, "menu %s was not found", row.MenuSku)
+				continue
+			}
+			food.MenuId = &menu.MenuId
+		}
+		if food.MenuId != nil && !menuIds[*food.MenuId] {
+			rowError(TypeFood, row.Row, food.Sku, "menu %s was not found", *food.MenuId)
+			continue
+		}
+		if row.CategorySku != "" {
+			category, ok := categoriesBySku[row.CategorySku]
+			if !ok {
+				rowError(TypeFood, row.Row, food.Sku, "category %s was not found", row.CategorySku)
+				continue
+			}
+			food.CategoryId = &category.CategoryId
+		}
+		if food.CategoryId != nil && *food.CategoryId == "" {
+			food.CategoryId = nil
+		}
+		if food.CategoryId != nil {
+			if _, ok := byId[*food.CategoryId]; !ok {
+				rowError(TypeFood, row.Row, food.Sku, "category %s was not found", *food.CategoryId)
+				continue
+			}
+		}
+		if err := validate.Struct(food); err != nil {
+			rowError(TypeFood, row.Row, food.Sku, "%s", err)
+			continue
+		}
+		if err := helpers.ValidateVariants(food.Variants); err != nil {
+			rowError(TypeFood, row.Row, food.Sku, "%s", err)
+			continue
+		}
+
+		current, existing := foodsBySku[*food.Sku]
+		if existing {
+			keepModifierIds(food.ModifierGroups, current.ModifierGroups)
+		}
+		if err := helpers.PrepareModifierGroups(food.ModifierGroups); err != nil {
+			rowError(TypeFood, row.Row, food.Sku, "%s", err)
+			continue
+		}
+
+		price := helpers.RoundMoney(*food.Price)
+		food.Price = &price
+		food.UpdatedAt = now
+		if existing {
+			// the state of the kitchen and the uploaded image are not part of the menu
+			food.ID, food.FoodId, food.CreatedAt = current.ID, current.FoodId, current.CreatedAt
+			food.Image, food.OutOfStock, food.Available, food.RemainingPortions = current.Image, current.OutOfStock, current.Available, current.RemainingPortions
+			if food.FoodImage == nil {
+				food.FoodImage = current.FoodImage
+			}
+			result.Foods.Updated++
+		} else {
+			food.ID = primitive.NewObjectID()
+			food.FoodId = food.ID.Hex()
+			food.CreatedAt = now
+			food.Image = nil
+			result.Foods.Created++
+		}
+		imported.foods = append(imported.foods, planned{id: food.ID, document: food, existing: existing})
+
+		if !existing || current.Price == nil || *current.Price != price || helpers.VariantsChanged(current.Variants, food.Variants) {
+			foodPrice := models.FoodPrice{
+				FoodId:        food.FoodId,
+				Price:         food.Price,
+				Variants:      food.Variants,
+				EffectiveFrom: now,
+				Source:        models.FoodPriceSourceImport,
+				Applied:       true,
+				ChangedBy:     uid,
+				CreatedAt:     now,
+			}
+			foodPrice.ID = primitive.NewObjectID()
+			foodPrice.FoodPriceId = foodPrice.ID.Hex()
+			imported.prices = append(imported.prices, foodPrice)
+		}
+	}
+
+	return imported, nil
+}
+
+// checkSku makes sure a row has a sku that no earlier row of its type used.
+func checkSku(sku *string, seen map[string]bool, fail func(message string)) bool {
+	switch {
+	case sku == nil || strings.TrimSpace(*sku) == "":
+		fail("sku is required")
+		return false
+	case len(*sku) > 64:
+		fail("sku must not be longer than 64 characters")
+		return false
+	case seen[*sku]:
+		fail(fmt.Sprintf("sku %s is used by an earlier row", *sku))
+		return false
+	}
+	seen[*sku] = true
+	return true
+}
+
+// keepModifierIds gives the groups and options of a reimported food the ids they already have, matched by
+// name, so orders keep pointing at the same choices.
+func keepModifierIds(groups []models.ModifierGroup, current []models.ModifierGroup) {
+	for i := range groups {
+		for _, currentGroup := range current {
+			if groups[i].GroupId != "" || !strings.EqualFold(groups[i].Name, currentGroup.Name) {
+				continue
+			}
+			groups[i].GroupId = currentGroup.GroupId
+			for j := range groups[i].Options {
+				for _, currentOption := range currentGroup.Options {
+					if groups[i].Options[j].OptionId == "" && strings.EqualFold(groups[i].Options[j].Name, currentOption.Name) {
+						groups[i].Options[j].OptionId = currentOption.OptionId
+					}
+				}
+			}
+		}
+	}
+}
+
+func findCategory(categories []models.Category, categoryId string) (models.Category, bool) {
+	for _, category := range categories {
+		if category.CategoryId == categoryId {
+			return category, true
+		}
+	}
+	return models.Category{}, false
+}
+
+func foodSkus(rows []FoodRow) []string {
+	skus := []string{}
+	for _, row := range rows {
+		if row.Sku != nil {
+			skus = append(skus, *row.Sku)
+		}
+	}
+	return skus
+}
+
+func findAll(ctx context.Context, collection *mongo.Collection, results interface{}) error {
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+func findBySku(ctx context.Context, collection *mongo.Collection, skus []string, results interface{}) error {
+	cursor, err := collection.Find(ctx, bson.M{"sku": bson.M{"$in": skus}})
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+func value(text *string) string {
+	if text == nil {
+		return ""
+	}
+	return *text
+}
@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvColumns are the columns a CSV import may have, only type and sku are required. Lists like allergens are
+// separated by "|". Variants, modifier groups, translations and dayparts can only be imported from JSON.
+var csvColumns = []string{
+	"type", "sku", "name", "description", "price", "category", "menu_sku", "menu_id", "category_sku", "category_id",
+	"parent_sku", "parent_id", "display_order", "station", "allergens", "dietary_tags", "spice_level", "food_image",
+}
+
+// ParseJSON reads a batch like {"menus": [...], "categories": [...], "foods": [...]}, the rows have the fields
+// of the models plus the sku references.
+func ParseJSON(r io.Reader) (Batch, error) {
+	var batch Batch
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&batch); err != nil {
+		return batch, err
+	}
+
+	for i := range batch.Menus {
+		batch.Menus[i].Row = i + 1
+	}
+	for i := range batch.Categories {
+		batch.Categories[i].Row = i + 1
+	}
+	for i := range batch.Foods {
+		batch.Foods[i].Row = i + 1
+	}
+	return batch, nil
+}
+
+// ParseCSV reads a batch with one menu, category or food per line, told apart by the type column. Values that
+// cannot be read are reported as row errors of the batch, rows are numbered by their line.
+func ParseCSV(r io.Reader) (Batch, error) {
+	var batch Batch
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return batch, fmt.Errorf("the CSV file has no header: %s", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !containsString(csvColumns, name) {
+			return batch, fmt.Errorf("column %s must be one of %s", name, strings.Join(csvColumns, ", "))
+		}
+		index[name] = i
+	}
+	if _, ok := index["type"]; !ok {
+		return batch, fmt.Errorf("the CSV file needs a type column")
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return batch, err
+		}
+		// blank lines are skipped by the reader, so the line is asked for instead of counted
+		line, _ := reader.FieldPos(0)
+
+		row := csvRow{record: record, index: index}
+		if row.empty() {
+			continue
+		}
+
+		switch strings.ToUpper(row.text("type")) {
+		case TypeMenu:
+			menu := MenuRow{Row: line}
+			menu.Sku = row.pointer("sku")
+			menu.Name = row.text("name")
+			menu.Category = row.text("category")
+			batch.Menus = append(batch.Menus, menu)
+		case TypeCategory:
+			category := CategoryRow{Row: line, ParentSku: row.text("parent_sku")}
+			category.Sku = row.pointer("sku")
+			category.Name = row.pointer("name")
+			category.ParentId = row.pointer("parent_id")
+			category.Station = row.text("station")
+			if category.DisplayOrder, err = row.integer("display_order"); err == nil {
+				batch.Categories = append(batch.Categories, category)
+			}
+		case TypeFood:
+			food := FoodRow{Row: line, MenuSku: row.text("menu_sku"), CategorySku: row.text("category_sku")}
+			food.Sku = row.pointer("sku")
+			food.Name = row.pointer("name")
+			food.Description = row.pointer("description")
+			food.MenuId = row.pointer("menu_id")
+			food.CategoryId = row.pointer("category_id")
+			food.FoodImage = row.pointer("food_image")
+			food.Allergens = row.list("allergens")
+			food.DietaryTags = row.list("dietary_tags")
+			if food.DisplayOrder, err = row.integer("display_order"); err == nil {
+				if food.Price, err = row.number("price"); err == nil {
+					food.SpiceLevel, err = row.optionalInteger("spice_level")
+				}
+			}
+			if err == nil {
+				batch.Foods = append(batch.Foods, food)
+			}
+		default:
+			err = fmt.Errorf("type must be one of %s, %s, %s", TypeMenu, TypeCategory, TypeFood)
+		}
+		if err != nil {
+			batch.Errors = append(batch.Errors, RowError{Type: strings.ToUpper(row.text("type")), Row: line, Sku: row.text("sku"), Message: err.Error()})
+		}
+	}
+	return batch, nil
+}
+
+type csvRow struct {
+	record []string
+	index  map[string]int
+}
+
+func (r csvRow) text(column string) string {
+	i, ok := r.index[column]
+	if !ok || i >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[i])
+}
+
+func (r csvRow) empty() bool {
+	for _, value := range r.record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (r csvRow) pointer(column string) *string {
+	value := r.text(column)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func (r csvRow) list(column string) []string {
+	var values []string
+	for _, value := range strings.Split(r.text(column), "|") {
+		if value = strings.ToUpper(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (r csvRow) integer(column string) (int, error) {
+	value := r.text(column)
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a whole number", column)
+	}
+	return number, nil
+}
+
+func (r csvRow) optionalInteger(column string) (*int, error) {
+	if r.text(column) == "" {
+		return nil, nil
+	}
+	number, err := r.integer(column)
+	return &number, err
+}
+
+func (r csvRow) number(column string) (*float64, error) {
+	value := r.text(column)
+	if value == "" {
+		return nil, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", column)
+	}
+	return &number, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
 	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/migrations"
 	"github.com/menyasosali/restaurant-manage-backend-go/routes"
+	"github.com/menyasosali/restaurant-manage-backend-go/storage"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -17,6 +24,23 @@ func main() {
 		port = "8000"
 	}
 
+	// the indexes are on the migrated field names, so the migrations run first
+	if os.Getenv("MIGRATE_ON_START") != "false" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		if _, err := migrations.Up(ctx, 0); err != nil {
+			log.Panicf("cannot migrate the database: %s", err)
+		}
+		cancel()
+	} else if pending, err := migrations.Pending(context.Background()); err == nil && len(pending) > 0 {
+		log.Printf("%d migrations are pending, run restaurantctl migrate up", len(pending))
+	}
+
+	if err := database.EnsureIndexes(database.Client); err != nil {
+		log.Panicf("cannot create database indexes: %s", err)
+	}
+
+	go controllers.RunMenuPublisher(time.Minute)
+
 	router := mux.NewRouter()
 
 	router.Use(func(h http.Handler) http.Handler {
@@ -27,12 +51,32 @@ func main() {
 
 	routes.FoodRoutes(router)
 	routes.MenuRoutes(router)
+	routes.CategoryRoutes(router)
+	routes.ComboRoutes(router)
+	routes.SearchRoutes(router)
 	routes.TableRoutes(router)
 	routes.OrderRoutes(router)
 	routes.OrderItemRoutes(router)
 	routes.InvoiceRoutes(router)
+	routes.PromotionRoutes(router)
+	routes.NoteRoutes(router)
+	routes.InventoryRoutes(router)
+	routes.TranslationRoutes(router)
+	routes.ReportRoutes(router)
+	routes.AnalyticsRoutes(router)
+	routes.ExportRoutes(router)
+	routes.ImportRoutes(router)
+
+	// uploaded images are loaded by <img> tags, which cannot send a token
+	handler := http.Handler(router)
+	if local, ok := controllers.Blobs.(*storage.LocalStore); ok && strings.HasPrefix(local.PublicURL, "/") {
+		server := http.NewServeMux()
+		server.Handle(local.PublicURL+"/", local.Handler())
+		server.Handle("/", router)
+		handler = server
+	}
 
-	if err := http.ListenAndServe(":"+port, router); err != nil {
+	if err := http.ListenAndServe(":"+port, handler); err != nil {
 		log.Panicf("cannot start server on port %s: %s", port, err)
 	}
 }
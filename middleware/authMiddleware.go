@@ -27,6 +27,7 @@ func Authentication(next http.Handler) http.Handler {
 		r.Header.Set("first_name", claims.FirstName)
 		r.Header.Set("second_name", claims.SecondName)
 		r.Header.Set("uid", claims.Uid)
+		r.Header.Set("role", claims.Role)
 
 		next.ServeHTTP(w, r)
 	})
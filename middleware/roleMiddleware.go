@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"net/http"
+	"strings"
+)
+
+func RequireRole(next http.HandlerFunc, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !helpers.HasRole(r, roles...) {
+			msg := fmt.Sprintf("this action requires one of the roles: %s", strings.Join(roles, ", "))
+			http.Error(w, msg, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
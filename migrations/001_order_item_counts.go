@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// orderItemCounts converts order items stored with an S/M/L quantity into a portion with a count of one. An
+// item ordered several times cannot be stored as a quantity again, so it is not undone.
+var orderItemCounts = Migration{
+	Version: 1,
+	Name:    "order_item_counts",
+	Up: func(ctx context.Context) error {
+		_, err := collection("orderItem").UpdateMany(
+			ctx,
+			bson.M{"count": bson.M{"$exists": false}},
+			mongo.Pipeline{
+				{{"$set", bson.D{
+					{"portion", "$quantity"},
+					{"count", 1},
+				}}},
+				{{"$unset", "quantity"}},
+			},
+		)
+		return err
+	},
+}
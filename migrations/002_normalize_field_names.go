@@ -0,0 +1,135 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+)
+
+// renamedField is a field stored under its snake_case name, formerly kept under the legacy names: the name the
+// driver derived for a field without a bson tag, like menuid, and keys that updates wrote by mistake, like
+// update_at. The legacy names are listed with the preferred value first.
+type renamedField struct {
+	name   string
+	legacy []string
+	// latest keeps the newest of the values, for timestamps written under several names
+	latest bool
+}
+
+var timestampFields = []renamedField{
+	{name: "created_at", legacy: []string{"createdat"}},
+	{name: "updated_at", legacy: []string{"updatedat", "update_at"}, latest: true},
+}
+
+// renamedFields are the fields to normalize by collection.
+var renamedFields = map[string][]renamedField{
+	"food": append([]renamedField{
+		{name: "food_id", legacy: []string{"foodid"}},
+		{name: "food_image", legacy: []string{"foodimage"}},
+		// updates of the menu were written to "menu", which is newer than the menu set on creation
+		{name: "menu_id", legacy: []string{"menu", "menuid"}},
+	}, timestampFields...),
+	"invoice": append([]renamedField{
+		{name: "invoice_id", legacy: []string{"invoiceid"}},
+		{name: "order_id", legacy: []string{"orderid"}},
+		{name: "payment_method", legacy: []string{"paymentmethod"}},
+		{name: "payment_status", legacy: []string{"paymentstatus"}},
+		{name: "payment_due_date", legacy: []string{"paymentduedate"}},
+	}, timestampFields...),
+	"menu": append([]renamedField{
+		{name: "menu_id", legacy: []string{"menuid"}},
+		{name: "start_date", legacy: []string{"startdate"}},
+		{name: "end_date", legacy: []string{"enddate"}},
+	}, timestampFields...),
+	"orderItem": append([]renamedField{
+		{name: "order_item_id", legacy: []string{"orderitemid"}},
+		{name: "order_id", legacy: []string{"orderid"}},
+		{name: "food_id", legacy: []string{"foodid"}},
+		{name: "unit_price", legacy: []string{"unitprice"}},
+	}, timestampFields...),
+	"order": append([]renamedField{
+		{name: "order_id", legacy: []string{"orderid"}},
+		{name: "order_date", legacy: []string{"orderdate"}},
+		// updates of the table were written to "menu"
+		{name: "table_id", legacy: []string{"menu", "tableid"}},
+	}, timestampFields...),
+	"table": append([]renamedField{
+		{name: "table_id", legacy: []string{"tableid"}},
+		{name: "table_number", legacy: []string{"tablenumber"}},
+		{name: "number_of_guests", legacy: []string{"numberofguests"}},
+	}, timestampFields...),
+	"user": append([]renamedField{
+		{name: "user_id", legacy: []string{"userid"}},
+		{name: "first_name", legacy: []string{"firstname"}},
+		{name: "second_name", legacy: []string{"secondname"}},
+		{name: "refresh_token", legacy: []string{"refreshtoken"}},
+	}, timestampFields...),
+}
+
+// normalizeFieldNames moves the fields of the models that had no bson tag to the snake_case names the queries
+// use. A value already stored under the new name was written by an update and wins over the legacy ones.
+var normalizeFieldNames = Migration{
+	Version: 2,
+	Name:    "normalize_field_names",
+	Up: func(ctx context.Context) error {
+		for name, fields := range renamedFields {
+			var matches bson.A
+			var unset bson.A
+			set := bson.D{}
+			for _, field := range fields {
+				values := bson.A{"$" + field.name}
+				for _, legacy := range field.legacy {
+					matches = append(matches, bson.M{legacy: bson.M{"$exists": true}})
+					unset = append(unset, legacy)
+					values = append(values, "$"+legacy)
+				}
+				set = append(set, bson.E{field.name, firstValue(values, field.latest)})
+			}
+
+			_, err := collection(name).UpdateMany(
+				ctx,
+				bson.M{"$or": matches},
+				mongo.Pipeline{
+					{{"$set", set}},
+					{{"$unset", unset}},
+				},
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// the fields go back to the names the driver derives from the untagged fields, the mistaken keys are not
+	// written again
+	Down: func(ctx context.Context) error {
+		for name, fields := range renamedFields {
+			var matches bson.A
+			rename := bson.D{}
+			for _, field := range fields {
+				matches = append(matches, bson.M{field.name: bson.M{"$exists": true}})
+				rename = append(rename, bson.E{field.name, strings.ReplaceAll(field.name, "_", "")})
+			}
+
+			_, err := collection(name).UpdateMany(ctx, bson.M{"$or": matches}, bson.D{{"$rename", rename}})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// firstValue picks the first of the values that is set, or the newest with latest. $max and $ifNull skip
+// missing fields, $ifNull is nested for servers before 5.0 that take only two arguments.
+func firstValue(values bson.A, latest bool) interface{} {
+	if latest {
+		return bson.D{{"$max", values}}
+	}
+	value := values[len(values)-1]
+	for i := len(values) - 2; i >= 0; i-- {
+		value = bson.D{{"$ifNull", bson.A{values[i], value}}}
+	}
+	return value
+}
@@ -0,0 +1,112 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"os"
+	"time"
+)
+
+var lockCollection = database.OpenCollection(database.Client, "migration_lock")
+
+const (
+	// lockLease is how long the lock is held without a renewal, a crashed instance blocks the others this long
+	lockLease = 2 * time.Minute
+	lockRenew = 30 * time.Second
+	lockPoll  = time.Second
+	lockId    = "migrations"
+)
+
+// withLock runs fn while holding the migration lock, waiting for another holder until ctx ends. The lock is a
+// single document with a lease that is renewed while fn runs, so only one instance migrates at a time.
+func withLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	owner := lockOwner()
+
+	waiting := false
+	for {
+		acquired, err := acquireLock(ctx, owner)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+		if !waiting {
+			log.Printf("waiting for another instance to finish migrating")
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the migration lock was not released: %s", ctx.Err())
+		case <-time.After(lockPoll):
+		}
+	}
+
+	renewCtx, stopRenewing := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		renewLock(renewCtx, owner)
+	}()
+
+	err := fn(ctx)
+
+	stopRenewing()
+	<-renewed
+	if _, releaseErr := lockCollection.DeleteOne(ctx, bson.M{"_id": lockId, "owner": owner}); releaseErr != nil && err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// acquireLock takes the lock when nobody holds it or the lease of the holder ran out. A held lock does not
+// match the filter, so the upsert fails on the existing _id.
+func acquireLock(ctx context.Context, owner string) (bool, error) {
+	now := time.Now()
+	_, err := lockCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": lockId, "locked_until": bson.M{"$lt": now}},
+		bson.D{{"$set", bson.D{
+			{"owner", owner},
+			{"locked_at", now},
+			{"locked_until", now.Add(lockLease)},
+		}}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func renewLock(ctx context.Context, owner string) {
+	ticker := time.NewTicker(lockRenew)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := lockCollection.UpdateOne(
+				ctx,
+				bson.M{"_id": lockId, "owner": owner},
+				bson.D{{"$set", bson.D{{"locked_until", time.Now().Add(lockLease)}}}},
+			)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("the migration lock was not renewed: %s", err)
+			}
+		}
+	}
+}
+
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+}
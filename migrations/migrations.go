@@ -0,0 +1,195 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"sort"
+	"time"
+)
+
+var migrationCollection = database.OpenCollection(database.Client, "migrations")
+
+// Migration changes the stored documents from one version of the models to the next and Down changes them
+// back, Down is nil when the change cannot be undone. The steps are not run in a transaction, so they are
+// written to be safe to run again: a migration that fails halfway is run from the start the next time.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context) error
+	Down    func(ctx context.Context) error
+}
+
+// Record is the document of an applied migration.
+type Record struct {
+	Version   int       `bson:"version" json:"version"`
+	Name      string    `bson:"name" json:"name"`
+	AppliedAt time.Time `bson:"applied_at" json:"applied_at"`
+}
+
+// Status is a known migration and, when it was applied, its record.
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// all are the migrations by version, a new migration gets the next version and is appended.
+var all = []Migration{
+	orderItemCounts,
+	normalizeFieldNames,
+}
+
+func init() {
+	// a mistake in the list would skip or repeat a migration, so it is caught on start
+	if !sort.SliceIsSorted(all, func(i, j int) bool { return all[i].Version < all[j].Version }) {
+		panic("migrations must be listed by version")
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Version == all[i-1].Version {
+			panic(fmt.Sprintf("migration version %d is used twice", all[i].Version))
+		}
+	}
+}
+
+// Latest is the version of the newest migration.
+func Latest() int {
+	return all[len(all)-1].Version
+}
+
+// List tells for every migration whether it was applied.
+func List(ctx context.Context) ([]Status, error) {
+	applied, err := appliedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(all))
+	for i, migration := range all {
+		statuses[i] = Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies the migrations up to the target version in order, 0 is the latest. It waits for other instances
+// that are migrating and returns the migrations it applied, none when they got there first.
+func Up(ctx context.Context, target int) ([]Migration, error) {
+	if target == 0 {
+		target = Latest()
+	}
+
+	var done []Migration
+	err := withLock(ctx, func(ctx context.Context) error {
+		applied, err := appliedRecords(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range all {
+			if migration.Version > target {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			log.Printf("applying migration %d %s", migration.Version, migration.Name)
+			if err := migration.Up(ctx); err != nil {
+				return fmt.Errorf("migration %d %s failed: %s", migration.Version, migration.Name, err)
+			}
+			appliedAt, _ := time.Parse(time.RFC822, time.Now().Format(time.RFC822))
+			record := Record{Version: migration.Version, Name: migration.Name, AppliedAt: appliedAt}
+			if _, err := migrationCollection.InsertOne(ctx, record); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down undoes the applied migrations above the target version, newest first, and returns them.
+func Down(ctx context.Context, target int) ([]Migration, error) {
+	var done []Migration
+	err := withLock(ctx, func(ctx context.Context) error {
+		applied, err := appliedRecords(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(all) - 1; i >= 0; i-- {
+			migration := all[i]
+			if migration.Version <= target {
+				break
+			}
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d %s cannot be undone", migration.Version, migration.Name)
+			}
+
+			log.Printf("undoing migration %d %s", migration.Version, migration.Name)
+			if err := migration.Down(ctx); err != nil {
+				return fmt.Errorf("undoing migration %d %s failed: %s", migration.Version, migration.Name, err)
+			}
+			if _, err := migrationCollection.DeleteOne(ctx, bson.M{"version": migration.Version}); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Pending returns the migrations that were not applied yet.
+func Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := appliedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range all {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+func appliedRecords(ctx context.Context) (map[int]Record, error) {
+	var records []Record
+
+	cursor, err := migrationCollection.Find(ctx, bson.M{"version": bson.M{"$exists": true}}, options.Find().SetSort(bson.D{{"version", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]Record{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// collection opens a collection for a migration step, the migrations use the names the documents are stored
+// under rather than the models.
+func collection(name string) *mongo.Collection {
+	return database.OpenCollection(database.Client, name)
+}
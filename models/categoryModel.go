@@ -0,0 +1,27 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Category groups foods, categories nest through ParentId (Drinks > Wine > Red). Categories are shown by
+// DisplayOrder, then name. The kitchen station of a category is inherited from its parent when empty. Sku is the
+// identifier of the category in an external system, bulk imports match categories by it.
+type Category struct {
+	ID           primitive.ObjectID             `bson:"_id"`
+	Name         *string                        `bson:"name" json:"name" validate:"required,min=2,max=100"`
+	ParentId     *string                        `bson:"parent_id" json:"parent_id"`
+	DisplayOrder int                            `bson:"display_order" json:"display_order"`
+	Station      string                         `bson:"station" json:"station" validate:"max=50"`
+	Translations map[string]CategoryTranslation `bson:"translations,omitempty" json:"translations" validate:"dive,keys,bcp47_language_tag,endkeys"`
+	CreatedAt    time.Time                      `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time                      `bson:"updated_at" json:"updated_at"`
+	CategoryId   string                         `bson:"category_id" json:"category_id"`
+	Sku          *string                        `bson:"sku,omitempty" json:"sku" validate:"omitempty,max=64"`
+}
+
+// CategoryTranslation holds the localized name of a category.
+type CategoryTranslation struct {
+	Name string `bson:"name,omitempty" json:"name,omitempty" validate:"omitempty,min=2,max=100"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Combo is a bundle of foods sold at one price, e.g. a lunch deal of a main, a side and a drink. Every slot
+// is filled with Choices of its options when the combo is ordered. The combo is orderable while its menu is
+// served, or always without a menu.
+type Combo struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `bson:"name" json:"name" validate:"required,min=2,max=100"`
+	Description  *string            `bson:"description" json:"description" validate:"omitempty,max=1000"`
+	Price        *float64           `bson:"price" json:"price" validate:"required,gte=0"`
+	Slots        []ComboSlot        `bson:"slots" json:"slots" validate:"required,min=1,dive"`
+	MenuId       *string            `bson:"menu_id" json:"menu_id"`
+	CategoryId   *string            `bson:"category_id" json:"category_id"`
+	DisplayOrder int                `bson:"display_order" json:"display_order"`
+	Available    *bool              `bson:"available" json:"available"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	ComboId      string             `bson:"combo_id" json:"combo_id"`
+}
+
+type ComboSlot struct {
+	SlotId  string            `bson:"slot_id" json:"slot_id"`
+	Name    string            `bson:"name" json:"name" validate:"required,max=100"`
+	Choices int               `bson:"choices" json:"choices" validate:"min=1,max=10"`
+	Options []ComboSlotOption `bson:"options" json:"options" validate:"required,min=1,dive"`
+}
+
+// ComboSlotOption is a food that can fill a slot, in a fixed Portion when set. Upcharge is added to the combo
+// price for premium choices.
+type ComboSlotOption struct {
+	FoodId   string  `bson:"food_id" json:"food_id" validate:"required"`
+	Portion  *string `bson:"portion" json:"portion" validate:"omitempty,max=20"`
+	Upcharge float64 `bson:"upcharge" json:"upcharge" validate:"gte=0"`
+}
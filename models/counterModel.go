@@ -0,0 +1,7 @@
+package models
+
+// Counter holds the last value handed out for a named sequence, see helpers.NextSequence.
+type Counter struct {
+	ID  string `bson:"_id" json:"id"`
+	Seq int64  `bson:"seq" json:"seq"`
+}
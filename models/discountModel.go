@@ -0,0 +1,40 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Discount is a discount attached to an order, either by entering a promo code or manually by a manager.
+// A Discount without OrderItemId applies to the whole order.
+type Discount struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Source       string             `bson:"source" json:"source"`
+	DiscountType *string            `bson:"discount_type" json:"discount_type" validate:"required,eq=PERCENTAGE|eq=FIXED"`
+	Value        *float64           `bson:"value" json:"value" validate:"required,gt=0"`
+	Reason       *string            `bson:"reason" json:"reason"`
+	PromotionId  *string            `bson:"promotion_id" json:"promotion_id"`
+	Code         *string            `bson:"code" json:"code"`
+	OrderItemId  *string            `bson:"order_item_id" json:"order_item_id"`
+	ApprovedBy   *string            `bson:"approved_by" json:"approved_by"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	DiscountId   string             `bson:"discount_id" json:"discount_id"`
+	OrderId      string             `bson:"order_id" json:"order_id" validate:"required"`
+}
+
+const (
+	DiscountSourcePromoCode = "PROMO_CODE"
+	DiscountSourceManual    = "MANUAL"
+	DiscountSourceAutomatic = "AUTOMATIC"
+)
+
+// AppliedDiscount is a discount as it was taken off a priced order, invoices keep them once they are paid.
+type AppliedDiscount struct {
+	Source      string  `bson:"source" json:"source"`
+	Description string  `bson:"description" json:"description"`
+	PromotionId string  `bson:"promotion_id,omitempty" json:"promotion_id,omitempty"`
+	DiscountId  string  `bson:"discount_id,omitempty" json:"discount_id,omitempty"`
+	Code        string  `bson:"code,omitempty" json:"code,omitempty"`
+	OrderItemId string  `bson:"order_item_id,omitempty" json:"order_item_id,omitempty"`
+	Amount      float64 `bson:"amount" json:"amount"`
+}
@@ -5,13 +5,68 @@ import (
 	"time"
 )
 
+// Food is orderable unless Available is false (86'd by the kitchen), it is out of stock or RemainingPortions,
+// when counted, has run out. Foods are listed within their category by DisplayOrder. FoodImage is a URL
+// given by the client or the URL of the uploaded Image. Sku is the identifier of the food in an external
+// system, bulk imports match foods by it.
 type Food struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	Name      *string            `json:"name" validate:"required,min=2,max=100"`
-	Price     *float64           `json:"price" validate:"required"`
-	FoodImage *string            `json:"food_Image" validate:"required"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
-	FoodId    string             `json:"food_id"`
-	MenuId    *string            `json:"menu_id" validate:"required"`
+	ID                primitive.ObjectID         `bson:"_id"`
+	Name              *string                    `bson:"name" json:"name" validate:"required,min=2,max=100"`
+	Price             *float64                   `bson:"price" json:"price" validate:"required"`
+	Description       *string                    `bson:"description" json:"description" validate:"omitempty,max=1000"`
+	Translations      map[string]FoodTranslation `bson:"translations,omitempty" json:"translations" validate:"dive,keys,bcp47_language_tag,endkeys"`
+	FoodImage         *string                    `bson:"food_image" json:"food_image"`
+	Image             *Image                     `bson:"image" json:"image"`
+	Variants          []FoodVariant              `bson:"variants" json:"variants" validate:"dive"`
+	ModifierGroups    []ModifierGroup            `bson:"modifier_groups" json:"modifier_groups" validate:"dive"`
+	Allergens         []string                   `bson:"allergens" json:"allergens" validate:"dive,oneof=GLUTEN CRUSTACEANS EGGS FISH PEANUTS SOYBEANS MILK NUTS CELERY MUSTARD SESAME SULPHITES LUPIN MOLLUSCS"`
+	DietaryTags       []string                   `bson:"dietary_tags" json:"dietary_tags" validate:"dive,oneof=VEGAN VEGETARIAN HALAL GLUTEN_FREE"`
+	SpiceLevel        *int                       `bson:"spice_level" json:"spice_level" validate:"omitempty,min=0,max=5"`
+	OutOfStock        bool                       `bson:"out_of_stock" json:"out_of_stock"`
+	Available         *bool                      `bson:"available" json:"available"`
+	RemainingPortions *int                       `bson:"remaining_portions" json:"remaining_portions" validate:"omitempty,gte=0"`
+	CreatedAt         time.Time                  `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time                  `bson:"updated_at" json:"updated_at"`
+	FoodId            string                     `bson:"food_id" json:"food_id"`
+	MenuId            *string                    `bson:"menu_id" json:"menu_id" validate:"required"`
+	CategoryId        *string                    `bson:"category_id" json:"category_id"`
+	DisplayOrder      int                        `bson:"display_order" json:"display_order"`
+	Sku               *string                    `bson:"sku,omitempty" json:"sku" validate:"omitempty,max=64"`
+}
+
+// FoodTranslation holds the localized texts of a food, empty fields fall back to the untranslated ones.
+type FoodTranslation struct {
+	Name        string `bson:"name,omitempty" json:"name,omitempty" validate:"omitempty,min=2,max=100"`
+	Description string `bson:"description,omitempty" json:"description,omitempty" validate:"max=1000"`
+}
+
+// Allergens are the 14 allergens that have to be declared in the EU.
+var Allergens = []string{
+	"GLUTEN", "CRUSTACEANS", "EGGS", "FISH", "PEANUTS", "SOYBEANS", "MILK",
+	"NUTS", "CELERY", "MUSTARD", "SESAME", "SULPHITES", "LUPIN", "MOLLUSCS",
+}
+
+var DietaryTags = []string{"VEGAN", "VEGETARIAN", "HALAL", "GLUTEN_FREE"}
+
+// FoodVariant is a portion or size of a food with its own price, e.g. "S", "M", "L" or "glass", "bottle".
+// Foods without variants are sold at Price.
+type FoodVariant struct {
+	Name  string   `bson:"name" json:"name" validate:"required,max=20"`
+	Price *float64 `bson:"price" json:"price" validate:"required,gte=0"`
+}
+
+// ModifierGroup is a choice offered with a food, e.g. "Doneness" or "Extras". Between MinSelections and
+// MaxSelections options of the group have to be picked; a group with MinSelections 0 is optional.
+type ModifierGroup struct {
+	GroupId       string           `bson:"group_id" json:"group_id"`
+	Name          string           `bson:"name" json:"name" validate:"required,max=100"`
+	MinSelections int              `bson:"min_selections" json:"min_selections" validate:"min=0"`
+	MaxSelections int              `bson:"max_selections" json:"max_selections" validate:"min=1,gtefield=MinSelections"`
+	Options       []ModifierOption `bson:"options" json:"options" validate:"required,min=1,dive"`
+}
+
+type ModifierOption struct {
+	OptionId   string  `bson:"option_id" json:"option_id"`
+	Name       string  `bson:"name" json:"name" validate:"required,max=100"`
+	PriceDelta float64 `bson:"price_delta" json:"price_delta"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// FoodPrice is an entry of the price history of a food. The price of a food at a moment is the entry with
+// the latest EffectiveFrom before it; entries in the future are scheduled price changes.
+type FoodPrice struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	FoodId        string             `bson:"food_id" json:"food_id"`
+	Price         *float64           `bson:"price" json:"price" validate:"required,gte=0"`
+	Variants      []FoodVariant      `bson:"variants" json:"variants" validate:"dive"`
+	EffectiveFrom time.Time          `bson:"effective_from" json:"effective_from"`
+	Source        string             `bson:"source" json:"source"`
+	Reason        *string            `bson:"reason" json:"reason" validate:"omitempty,max=200"`
+	Applied       bool               `bson:"applied" json:"applied"`
+	ChangedBy     string             `bson:"changed_by" json:"changed_by"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	FoodPriceId   string             `bson:"food_price_id" json:"food_price_id"`
+}
+
+const (
+	FoodPriceSourceCreate      = "CREATE"
+	FoodPriceSourceUpdate      = "UPDATE"
+	FoodPriceSourceScheduled   = "SCHEDULED"
+	FoodPriceSourceMenuVersion = "MENU_VERSION"
+	FoodPriceSourceImport      = "IMPORT"
+)
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Image is an uploaded picture with the renditions made from it. Url is the upload as it was sent, the
+// thumbnail and WebP renditions are scaled down to fit the sizes of helpers.ProcessImage.
+type Image struct {
+	Url              string    `bson:"url" json:"url"`
+	ThumbnailUrl     string    `bson:"thumbnail_url" json:"thumbnail_url"`
+	WebpUrl          string    `bson:"webp_url" json:"webp_url"`
+	ThumbnailWebpUrl string    `bson:"thumbnail_webp_url" json:"thumbnail_webp_url"`
+	ContentType      string    `bson:"content_type" json:"content_type"`
+	Width            int       `bson:"width" json:"width"`
+	Height           int       `bson:"height" json:"height"`
+	Size             int       `bson:"size" json:"size"`
+	Keys             []string  `bson:"keys" json:"-"`
+	UploadedAt       time.Time `bson:"uploaded_at" json:"uploaded_at"`
+}
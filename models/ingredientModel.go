@@ -0,0 +1,54 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Ingredient is a stock item of the kitchen. Stock, thresholds and recipe quantities are all counted in Unit.
+type Ingredient struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Name              *string            `bson:"name" json:"name" validate:"required,min=2,max=100"`
+	Unit              *string            `bson:"unit" json:"unit" validate:"required,eq=g|eq=kg|eq=ml|eq=l|eq=pcs"`
+	Stock             float64            `bson:"stock" json:"stock"`
+	LowStockThreshold float64            `bson:"low_stock_threshold" json:"low_stock_threshold" validate:"gte=0"`
+	CostPerUnit       float64            `bson:"cost_per_unit" json:"cost_per_unit" validate:"gte=0"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+	IngredientId      string             `bson:"ingredient_id" json:"ingredient_id"`
+}
+
+// Recipe lists the ingredients used for one portion of a food.
+type Recipe struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	FoodId      string             `bson:"food_id" json:"food_id"`
+	Ingredients []RecipeIngredient `bson:"ingredients" json:"ingredients" validate:"required,dive"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type RecipeIngredient struct {
+	IngredientId string  `bson:"ingredient_id" json:"ingredient_id" validate:"required"`
+	Quantity     float64 `bson:"quantity" json:"quantity" validate:"gt=0"`
+}
+
+// StockMovement records every change of an ingredient stock. Sales are kept per order item so that a void
+// can give back exactly what was taken.
+type StockMovement struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	IngredientId    string             `bson:"ingredient_id" json:"ingredient_id"`
+	Quantity        float64            `bson:"quantity" json:"quantity" validate:"ne=0"`
+	Reason          string             `bson:"reason" json:"reason" validate:"required,eq=SALE|eq=VOID|eq=DELIVERY|eq=WASTE|eq=COUNT"`
+	Comment         *string            `bson:"comment" json:"comment" validate:"omitempty,max=200"`
+	OrderItemId     *string            `bson:"order_item_id" json:"order_item_id"`
+	CreatedBy       string             `bson:"created_by" json:"created_by"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	StockMovementId string             `bson:"stock_movement_id" json:"stock_movement_id"`
+}
+
+const (
+	StockMovementSale     = "SALE"
+	StockMovementVoid     = "VOID"
+	StockMovementDelivery = "DELIVERY"
+	StockMovementWaste    = "WASTE"
+	StockMovementCount    = "COUNT"
+)
@@ -5,13 +5,36 @@ import (
 	"time"
 )
 
+// Invoice bills an order. Subtotal, Discounts, DiscountTotal, TaxRate, Tax and Total keep the pricing of the order
+// as it was billed, they are refreshed with every payment and frozen once the invoice is paid or void.
+// A refund while the invoice is pending lowers AmountPaid, AmountRefunded only counts refunds of a paid invoice.
 type Invoice struct {
 	ID             primitive.ObjectID `bson:"_id"`
-	InvoiceId      string             `json:"invoice_id"`
-	OrderId        string             `json:"order_id"`
-	PaymentMethod  *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
-	PaymentStatus  *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID"`
-	PaymentDueDate time.Time          `json:"payment_due_date"`
-	CreatedAt      time.Time          `json:"created_at"`
-	UpdatedAT      time.Time          `json:"updated_at"`
+	InvoiceId      string             `bson:"invoice_id" json:"invoice_id"`
+	InvoiceNumber  string             `bson:"invoice_number" json:"invoice_number"`
+	OrderId        string             `bson:"order_id" json:"order_id"`
+	PaymentMethod  *string            `bson:"payment_method" json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
+	PaymentStatus  *string            `bson:"payment_status" json:"payment_status" validate:"required,eq=PENDING|eq=PAID|eq=PARTIALLY_REFUNDED|eq=REFUNDED|eq=VOID"`
+	PaymentDueDate time.Time          `bson:"payment_due_date" json:"payment_due_date"`
+	AmountPaid     float64            `bson:"amount_paid" json:"amount_paid"`
+	AmountRefunded float64            `bson:"amount_refunded" json:"amount_refunded"`
+	Subtotal       float64            `bson:"subtotal" json:"subtotal"`
+	Discounts      []AppliedDiscount  `bson:"discounts" json:"discounts"`
+	DiscountTotal  float64            `bson:"discount_total" json:"discount_total"`
+	TaxRate        float64            `bson:"tax_rate" json:"tax_rate"`
+	Tax            float64            `bson:"tax" json:"tax"`
+	Total          float64            `bson:"total" json:"total"`
+	VoidReason     *string            `bson:"void_reason" json:"void_reason"`
+	VoidedBy       *string            `bson:"voided_by" json:"voided_by"`
+	VoidedAt       *time.Time         `bson:"voided_at" json:"voided_at"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAT      time.Time          `bson:"updated_at" json:"updated_at"`
 }
+
+const (
+	PaymentStatusPending           = "PENDING"
+	PaymentStatusPaid              = "PAID"
+	PaymentStatusPartiallyRefunded = "PARTIALLY_REFUNDED"
+	PaymentStatusRefunded          = "REFUNDED"
+	PaymentStatusVoid              = "VOID"
+)
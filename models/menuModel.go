@@ -5,13 +5,37 @@ import (
 	"time"
 )
 
+// Menu is orderable between StartDate and EndDate, when set, and during one of its Dayparts. A menu without
+// dayparts is served all day. Dayparts are evaluated in Timezone, or the restaurant timezone when empty.
+// Category is the free-form label of menus created before categories, foods are grouped by their CategoryId.
+// Sku is the identifier of the menu in an external system, bulk imports match menus by it.
 type Menu struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	Name      string             `json:"name" validate:"required"`
-	Category  string             `json:"category" validate:"required"`
-	StartDate *time.Time         `json:"start_date"`
-	EndDate   *time.Time         `json:"end_date"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
-	MenuId    string             `json:"food_id"`
+	ID                 primitive.ObjectID         `bson:"_id"`
+	Name               string                     `bson:"name" json:"name" validate:"required"`
+	Category           string                     `bson:"category" json:"category"`
+	Translations       map[string]MenuTranslation `bson:"translations,omitempty" json:"translations" validate:"dive,keys,bcp47_language_tag,endkeys"`
+	StartDate          *time.Time                 `bson:"start_date" json:"start_date"`
+	EndDate            *time.Time                 `bson:"end_date" json:"end_date"`
+	Dayparts           []Daypart                  `bson:"dayparts" json:"dayparts" validate:"dive"`
+	Timezone           *string                    `bson:"timezone" json:"timezone" validate:"omitempty,timezone"`
+	PublishedVersionId *string                    `bson:"published_version_id" json:"published_version_id"`
+	Sku                *string                    `bson:"sku,omitempty" json:"sku" validate:"omitempty,max=64"`
+	CreatedAt          time.Time                  `bson:"created_at" json:"created_at"`
+	UpdatedAt          time.Time                  `bson:"updated_at" json:"updated_at"`
+	MenuId             string                     `bson:"menu_id" json:"menu_id"`
+}
+
+// MenuTranslation holds the localized texts of a menu, empty fields fall back to the untranslated ones.
+type MenuTranslation struct {
+	Name     string `bson:"name,omitempty" json:"name,omitempty"`
+	Category string `bson:"category,omitempty" json:"category,omitempty"`
+}
+
+// Daypart is a recurring serving window, e.g. breakfast 07:00-11:00 on weekdays. StartTime and EndTime are
+// "15:04" clock times, an empty Weekdays list means every day and 0 is Sunday.
+type Daypart struct {
+	Name      string `bson:"name" json:"name" validate:"required,max=50"`
+	Weekdays  []int  `bson:"weekdays" json:"weekdays" validate:"dive,min=0,max=6"`
+	StartTime string `bson:"start_time" json:"start_time" validate:"required,len=5"`
+	EndTime   string `bson:"end_time" json:"end_time" validate:"required,len=5"`
 }
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// MenuVersion is a snapshot of a menu and the names, prices and membership of its foods. Drafts are edited
+// and previewed without affecting the live menu, publishing copies the snapshot onto the menu and its foods.
+type MenuVersion struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	MenuId        string             `bson:"menu_id" json:"menu_id"`
+	Version       int64              `bson:"version" json:"version"`
+	Status        string             `bson:"status" json:"status"`
+	Name          string             `bson:"name" json:"name" validate:"required"`
+	Category      string             `bson:"category" json:"category"`
+	Foods         []MenuVersionFood  `bson:"foods" json:"foods" validate:"dive"`
+	BasedOn       *string            `bson:"based_on" json:"based_on"`
+	PublishAt     *time.Time         `bson:"publish_at" json:"publish_at"`
+	PublishedAt   *time.Time         `bson:"published_at" json:"published_at"`
+	PublishedBy   *string            `bson:"published_by" json:"published_by"`
+	CreatedBy     string             `bson:"created_by" json:"created_by"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+	MenuVersionId string             `bson:"menu_version_id" json:"menu_version_id"`
+}
+
+// MenuVersionFood is a food as the version lists it. Foods without CategoryId keep their live category.
+type MenuVersionFood struct {
+	FoodId       string        `bson:"food_id" json:"food_id" validate:"required"`
+	Name         string        `bson:"name" json:"name" validate:"required,min=2,max=100"`
+	Price        float64       `bson:"price" json:"price" validate:"gte=0"`
+	Variants     []FoodVariant `bson:"variants" json:"variants" validate:"dive"`
+	CategoryId   *string       `bson:"category_id" json:"category_id"`
+	DisplayOrder int           `bson:"display_order" json:"display_order"`
+}
+
+const (
+	MenuVersionDraft     = "DRAFT"
+	MenuVersionScheduled = "SCHEDULED"
+	MenuVersionPublished = "PUBLISHED"
+	MenuVersionArchived  = "ARCHIVED"
+)
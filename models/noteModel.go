@@ -5,11 +5,33 @@ import (
 	"time"
 )
 
+// Note is a free text attached to any entity through TargetType and TargetId. Reservations and customers
+// are managed outside of this service, their notes are only keyed by the external id.
+// ALLERGY notes can list the allergens from the food labels so that conflicting orders are detected.
 type Note struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	Text      string             `json:"text"`
-	Title     string             `json:"title"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
-	NoteId    string             `json:"note_id"`
+	ID         primitive.ObjectID `bson:"_id"`
+	Text       string             `bson:"text" json:"text" validate:"required,max=1000"`
+	Title      string             `bson:"title" json:"title" validate:"max=100"`
+	Kind       string             `bson:"kind" json:"kind" validate:"omitempty,eq=GENERAL|eq=ALLERGY|eq=REQUEST"`
+	Allergens  []string           `bson:"allergens" json:"allergens" validate:"dive,oneof=GLUTEN CRUSTACEANS EGGS FISH PEANUTS SOYBEANS MILK NUTS CELERY MUSTARD SESAME SULPHITES LUPIN MOLLUSCS"`
+	Pinned     bool               `bson:"pinned" json:"pinned"`
+	AuthorId   string             `bson:"author_id" json:"author_id"`
+	AuthorName string             `bson:"author_name" json:"author_name"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	NoteId     string             `bson:"note_id" json:"note_id"`
+	TargetType string             `bson:"target_type" json:"target_type" validate:"required,eq=ORDER|eq=ORDER_ITEM|eq=TABLE|eq=RESERVATION|eq=CUSTOMER"`
+	TargetId   string             `bson:"target_id" json:"target_id" validate:"required"`
 }
+
+const (
+	NoteTargetOrder       = "ORDER"
+	NoteTargetOrderItem   = "ORDER_ITEM"
+	NoteTargetTable       = "TABLE"
+	NoteTargetReservation = "RESERVATION"
+	NoteTargetCustomer    = "CUSTOMER"
+
+	NoteKindGeneral = "GENERAL"
+	NoteKindAllergy = "ALLERGY"
+	NoteKindRequest = "REQUEST"
+)
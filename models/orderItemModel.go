@@ -5,13 +5,43 @@ import (
 	"time"
 )
 
+// OrderItem is a food or a combo on an order. An ordered combo is an item with the combo price and one child
+// item per chosen food, linked by ParentItemId. Children are priced at zero, their share of the combo price is
+// kept in AllocatedPrice.
 type OrderItem struct {
-	ID          primitive.ObjectID `bson:"_id"`
-	Quantity    *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
-	UnitPrice   *float64           `json:"unit_price" validate:"required"`
-	CreatedAt   time.Time          `json:"created_at"`
-	UpdatedAt   time.Time          `json:"update_at"`
-	FoodId      *string            `json:"food_id" validate:"required"`
-	OrderItemId string             `json:"order_item_id"`
-	OrderId     string             `json:"order_id" validate:"required`
+	ID                primitive.ObjectID `bson:"_id"`
+	Portion           *string            `bson:"portion" json:"portion" validate:"omitempty,max=20"`
+	Count             int                `bson:"count" json:"count" validate:"min=1,max=100"`
+	UnitPrice         *float64           `bson:"unit_price" json:"unit_price" validate:"omitempty,gte=0"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+	FoodId            *string            `bson:"food_id" json:"food_id" validate:"required_without=ComboId"`
+	ComboId           *string            `bson:"combo_id" json:"combo_id"`
+	ParentItemId      *string            `bson:"parent_item_id" json:"parent_item_id"`
+	SlotId            *string            `bson:"slot_id" json:"slot_id"`
+	AllocatedPrice    *float64           `bson:"allocated_price" json:"allocated_price"`
+	Modifiers         []SelectedModifier `bson:"modifiers" json:"modifiers" validate:"dive"`
+	MenuVersionId     *string            `bson:"menu_version_id" json:"menu_version_id"`
+	PriceOverriddenBy *string            `bson:"price_overridden_by" json:"price_overridden_by"`
+	Status            string             `bson:"status" json:"status"`
+	VoidReason        *string            `bson:"void_reason" json:"void_reason"`
+	VoidedBy          *string            `bson:"voided_by" json:"voided_by"`
+	VoidedAt          *time.Time         `bson:"voided_at" json:"voided_at"`
+	OrderItemId       string             `bson:"order_item_id" json:"order_item_id"`
+	OrderId           string             `bson:"order_id" json:"order_id" validate:"required"`
+}
+
+const (
+	OrderItemStatusOrdered = "ORDERED"
+	OrderItemStatusVoid    = "VOID"
+)
+
+// SelectedModifier is an option picked from one of the food modifier groups. The names and the price delta
+// are copied from the food when the item is ordered.
+type SelectedModifier struct {
+	GroupId    string  `bson:"group_id" json:"group_id" validate:"required"`
+	OptionId   string  `bson:"option_id" json:"option_id" validate:"required"`
+	GroupName  string  `bson:"group_name" json:"group_name"`
+	Name       string  `bson:"name" json:"name"`
+	PriceDelta float64 `bson:"price_delta" json:"price_delta"`
 }
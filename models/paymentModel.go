@@ -0,0 +1,64 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Payment settles part of an invoice. Tip is paid on top of Amount and does not count towards the invoice.
+type Payment struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	PaymentMethod  *string            `bson:"payment_method" json:"payment_method" validate:"required,eq=CARD|eq=CASH"`
+	Amount         *float64           `bson:"amount" json:"amount" validate:"required,gt=0"`
+	Tip            float64            `bson:"tip" json:"tip" validate:"gte=0"`
+	RefundedAmount float64            `bson:"refunded_amount" json:"refunded_amount"`
+	ReceivedBy     string             `bson:"received_by" json:"received_by"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	PaymentId      string             `bson:"payment_id" json:"payment_id"`
+	InvoiceId      string             `bson:"invoice_id" json:"invoice_id"`
+}
+
+// Refund gives back part or all of a single payment. Every refund is documented by a credit note.
+type Refund struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Amount       *float64           `bson:"amount" json:"amount" validate:"omitempty,gt=0"`
+	Reason       *string            `bson:"reason" json:"reason" validate:"required,min=3"`
+	AuthorizedBy string             `bson:"authorized_by" json:"authorized_by"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	RefundId     string             `bson:"refund_id" json:"refund_id"`
+	CreditNoteId string             `bson:"credit_note_id" json:"credit_note_id"`
+	PaymentId    string             `bson:"payment_id" json:"payment_id" validate:"required"`
+	InvoiceId    string             `bson:"invoice_id" json:"invoice_id"`
+}
+
+// CreditNote is the accounting document issued against an invoice when it is refunded or voided.
+type CreditNote struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Amount       float64            `bson:"amount" json:"amount"`
+	Reason       string             `bson:"reason" json:"reason"`
+	IssuedBy     string             `bson:"issued_by" json:"issued_by"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	CreditNoteId string             `bson:"credit_note_id" json:"credit_note_id"`
+	InvoiceId    string             `bson:"invoice_id" json:"invoice_id"`
+	RefundId     *string            `bson:"refund_id" json:"refund_id"`
+}
+
+// InvoiceEvent is an entry of the invoice audit trail.
+type InvoiceEvent struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Action    string             `bson:"action" json:"action"`
+	Amount    *float64           `bson:"amount" json:"amount"`
+	Reason    *string            `bson:"reason" json:"reason"`
+	UserId    string             `bson:"user_id" json:"user_id"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	EventId   string             `bson:"event_id" json:"event_id"`
+	InvoiceId string             `bson:"invoice_id" json:"invoice_id"`
+}
+
+const (
+	InvoiceEventCreated = "CREATED"
+	InvoiceEventUpdated = "UPDATED"
+	InvoiceEventPayment = "PAYMENT"
+	InvoiceEventRefund  = "REFUND"
+	InvoiceEventVoid    = "VOID"
+)
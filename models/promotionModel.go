@@ -0,0 +1,37 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Promotion describes a discount rule. Promotions with a Code are applied to an order only when the code
+// is entered, the others apply automatically to every matching item, limited to HappyHours when set.
+// Categories match food categories and their subcategories by id or name, and the category label of menus.
+type Promotion struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `bson:"name" json:"name" validate:"required,min=2,max=100"`
+	DiscountType *string            `bson:"discount_type" json:"discount_type" validate:"required,eq=PERCENTAGE|eq=FIXED"`
+	Value        *float64           `bson:"value" json:"value" validate:"required,gt=0"`
+	Scope        *string            `bson:"scope" json:"scope" validate:"required,eq=ORDER|eq=ITEM"`
+	FoodIds      []string           `bson:"food_ids" json:"food_ids"`
+	Categories   []string           `bson:"categories" json:"categories"`
+	Code         *string            `bson:"code" json:"code"`
+	UsageLimit   *int               `bson:"usage_limit" json:"usage_limit" validate:"omitempty,min=1"`
+	UsageCount   int                `bson:"usage_count" json:"usage_count"`
+	ValidFrom    *time.Time         `bson:"valid_from" json:"valid_from"`
+	ValidUntil   *time.Time         `bson:"valid_until" json:"valid_until"`
+	HappyHours   []HappyHour        `bson:"happy_hours" json:"happy_hours" validate:"dive"`
+	Active       *bool              `bson:"active" json:"active"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	PromotionId  string             `bson:"promotion_id" json:"promotion_id"`
+}
+
+// HappyHour is a recurring time window, StartTime and EndTime are "15:04" clock times.
+// An empty Weekdays list means every day, 0 is Sunday.
+type HappyHour struct {
+	Weekdays  []int  `bson:"weekdays" json:"weekdays" validate:"dive,min=0,max=6"`
+	StartTime string `bson:"start_time" json:"start_time" validate:"required,len=5"`
+	EndTime   string `bson:"end_time" json:"end_time" validate:"required,len=5"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// SalesReport holds the sales of a period. Sales, discounts and taxes are counted on the invoices created in the
+// period, payments, tips and refunds when they were taken and voids when they happened. A Z report closes the
+// period since the previous one and is stored under a gap-free Number, an X report shows the running period
+// without closing it and a summary covers any range of days, broken down by day.
+type SalesReport struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	ReportType      string             `bson:"report_type" json:"report_type"`
+	Number          int64              `bson:"number,omitempty" json:"number,omitempty"`
+	PeriodStart     time.Time          `bson:"period_start" json:"period_start"`
+	PeriodEnd       time.Time          `bson:"period_end" json:"period_end"`
+	GrossSales      float64            `bson:"gross_sales" json:"gross_sales"`
+	Discounts       float64            `bson:"discounts" json:"discounts"`
+	NetSales        float64            `bson:"net_sales" json:"net_sales"`
+	Taxes           float64            `bson:"taxes" json:"taxes"`
+	Total           float64            `bson:"total" json:"total"`
+	Tips            float64            `bson:"tips" json:"tips"`
+	InvoiceCount    int                `bson:"invoice_count" json:"invoice_count"`
+	Covers          int                `bson:"covers" json:"covers"`
+	AverageCheck    float64            `bson:"average_check" json:"average_check"`
+	AveragePerCover float64            `bson:"average_per_cover" json:"average_per_cover"`
+	Payments        []PaymentTotal     `bson:"payments" json:"payments"`
+	Refunds         CountTotal         `bson:"refunds" json:"refunds"`
+	ItemVoids       CountTotal         `bson:"item_voids" json:"item_voids"`
+	InvoiceVoids    CountTotal         `bson:"invoice_voids" json:"invoice_voids"`
+	Days            []DailySales       `bson:"days,omitempty" json:"days,omitempty"`
+	GeneratedBy     string             `bson:"generated_by" json:"generated_by"`
+	GeneratedAt     time.Time          `bson:"generated_at" json:"generated_at"`
+	ReportId        string             `bson:"report_id" json:"report_id"`
+}
+
+// PaymentTotal sums the payments and refunds of one payment method, Net is what stays in the till or account.
+type PaymentTotal struct {
+	PaymentMethod string  `bson:"payment_method" json:"payment_method"`
+	Count         int     `bson:"count" json:"count"`
+	Amount        float64 `bson:"amount" json:"amount"`
+	Tips          float64 `bson:"tips" json:"tips"`
+	Refunded      float64 `bson:"refunded" json:"refunded"`
+	Net           float64 `bson:"net" json:"net"`
+}
+
+type CountTotal struct {
+	Count  int     `bson:"count" json:"count"`
+	Amount float64 `bson:"amount" json:"amount"`
+}
+
+// DailySales are the sales of one day of a summary, in the restaurant timezone.
+type DailySales struct {
+	Date         string  `bson:"date" json:"date"`
+	GrossSales   float64 `bson:"gross_sales" json:"gross_sales"`
+	Discounts    float64 `bson:"discounts" json:"discounts"`
+	NetSales     float64 `bson:"net_sales" json:"net_sales"`
+	Taxes        float64 `bson:"taxes" json:"taxes"`
+	Total        float64 `bson:"total" json:"total"`
+	Tips         float64 `bson:"tips" json:"tips"`
+	InvoiceCount int     `bson:"invoice_count" json:"invoice_count"`
+	Covers       int     `bson:"covers" json:"covers"`
+}
+
+const (
+	ReportTypeZ       = "Z"
+	ReportTypeX       = "X"
+	ReportTypeSummary = "SUMMARY"
+)
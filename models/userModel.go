@@ -7,15 +7,23 @@ import (
 
 type User struct {
 	ID           primitive.ObjectID `bson:"_id"`
-	FirstName    *string            `json:"first_name" validate:"required,min=2,max=100"`
-	SecondName   *string            `json:"second_name" validate:"required,min=2,max=100"`
-	Password     *string            `json:"password" validate:"required,min=6"`
-	Email        *string            `json:"email" validate:"email,required"`
-	Avatar       *string            `json:"avatar"`
-	Phone        *string            `json:"phone" validate:"required"`
-	Token        *string            `json:"token"`
-	RefreshToken *string            `json:"refresh_token"`
-	CreatedAt    time.Time          `json:"created_at"`
-	UpdatedAt    time.Time          `json:"update_at"`
-	UserId       string             `json:"user_id"`
+	FirstName    *string            `bson:"first_name" json:"first_name" validate:"required,min=2,max=100"`
+	SecondName   *string            `bson:"second_name" json:"second_name" validate:"required,min=2,max=100"`
+	Password     *string            `bson:"password" json:"password" validate:"required,min=6"`
+	Email        *string            `bson:"email" json:"email" validate:"email,required"`
+	Avatar       *string            `bson:"avatar" json:"avatar"`
+	AvatarImage  *Image             `bson:"avatar_image" json:"avatar_image"`
+	Phone        *string            `bson:"phone" json:"phone" validate:"required"`
+	Role         *string            `bson:"role" json:"role" validate:"omitempty,eq=ADMIN|eq=MANAGER|eq=STAFF"`
+	Token        *string            `bson:"token" json:"token"`
+	RefreshToken *string            `bson:"refresh_token" json:"refresh_token"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	UserId       string             `bson:"user_id" json:"user_id"`
 }
+
+const (
+	RoleAdmin   = "ADMIN"
+	RoleManager = "MANAGER"
+	RoleStaff   = "STAFF"
+)
@@ -0,0 +1,86 @@
+package printing
+
+import "bytes"
+
+const (
+	AlignLeft   = 0
+	AlignCenter = 1
+	AlignRight  = 2
+)
+
+// Builder assembles an ESC/POS command stream.
+type Builder struct {
+	buf bytes.Buffer
+}
+
+// NewBuilder starts a stream with the initialize command, which resets any mode left over by a previous job.
+func NewBuilder() *Builder {
+	b := &Builder{}
+	b.buf.Write([]byte{0x1b, '@'})
+	return b
+}
+
+func (b *Builder) Align(align byte) *Builder {
+	b.buf.Write([]byte{0x1b, 'a', align})
+	return b
+}
+
+func (b *Builder) Bold(on bool) *Builder {
+	var n byte
+	if on {
+		n = 1
+	}
+	b.buf.Write([]byte{0x1b, 'E', n})
+	return b
+}
+
+// Size sets the character magnification, 1 to 8 in each direction.
+func (b *Builder) Size(width int, height int) *Builder {
+	b.buf.Write([]byte{0x1d, '!', byte((clamp(width)-1)<<4 | (clamp(height) - 1))})
+	return b
+}
+
+// Line prints a line of text. Printers use single-byte code pages, so anything outside ASCII is replaced.
+func (b *Builder) Line(text string) *Builder {
+	for _, r := range text {
+		if r < 0x20 || r > 0x7e {
+			r = '?'
+		}
+		b.buf.WriteByte(byte(r))
+	}
+	b.buf.WriteByte('\n')
+	return b
+}
+
+// Raw prints text that is already laid out, keeping its line breaks.
+func (b *Builder) Raw(text []byte) *Builder {
+	for _, line := range bytes.Split(bytes.TrimRight(text, "\n"), []byte("\n")) {
+		b.Line(string(line))
+	}
+	return b
+}
+
+func (b *Builder) Feed(lines int) *Builder {
+	b.buf.Write([]byte{0x1b, 'd', byte(lines)})
+	return b
+}
+
+// Cut feeds the paper up to the cutter and makes a partial cut.
+func (b *Builder) Cut() *Builder {
+	b.buf.Write([]byte{0x1d, 'V', 66, 0})
+	return b
+}
+
+func (b *Builder) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+func clamp(n int) int {
+	if n < 1 {
+		return 1
+	}
+	if n > 8 {
+		return 8
+	}
+	return n
+}
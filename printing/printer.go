@@ -0,0 +1,81 @@
+package printing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Printer sends a raw ESC/POS byte stream to a device.
+type Printer interface {
+	Print(ctx context.Context, data []byte) error
+}
+
+// TCPPrinter talks to a network printer in raw mode, which is port 9100 on virtually every model.
+type TCPPrinter struct {
+	Addr string
+}
+
+func (p TCPPrinter) Print(ctx context.Context, data []byte) error {
+	addr := p.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "9100")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	_, err = conn.Write(data)
+	return err
+}
+
+// FilePrinter appends everything it prints to a file, it stands in for real printers during local testing.
+type FilePrinter struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (p *FilePrinter) Print(ctx context.Context, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := os.OpenFile(p.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "\n===== %s =====\n", time.Now().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	return err
+}
+
+// ParsePrinter builds a printer from its address: "tcp://host[:port]" or "host[:port]" for network printers,
+// "file://path" for the file printer.
+func ParsePrinter(address string) (Printer, error) {
+	switch {
+	case strings.HasPrefix(address, "file://"):
+		return &FilePrinter{Path: strings.TrimPrefix(address, "file://")}, nil
+	case strings.HasPrefix(address, "tcp://"):
+		return TCPPrinter{Addr: strings.TrimPrefix(address, "tcp://")}, nil
+	case strings.Contains(address, "://"):
+		return nil, fmt.Errorf("unsupported printer address %q", address)
+	case address == "":
+		return nil, fmt.Errorf("empty printer address")
+	}
+	return TCPPrinter{Addr: address}, nil
+}
@@ -0,0 +1,79 @@
+package printing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrQueueFull = errors.New("print queue is full")
+
+const printTimeout = 10 * time.Second
+const maxBackoff = time.Minute
+
+// Queue prints jobs on a single printer one after another in a background goroutine. A failed job is
+// retried with exponential backoff and blocks the jobs behind it, so tickets never come out of order.
+type Queue struct {
+	name        string
+	printer     Printer
+	jobs        chan []byte
+	maxAttempts int
+	backoff     time.Duration
+	done        chan struct{}
+}
+
+func NewQueue(name string, printer Printer, size int, maxAttempts int, backoff time.Duration) *Queue {
+	q := &Queue{
+		name:        name,
+		printer:     printer,
+		jobs:        make(chan []byte, size),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		done:        make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *Queue) Enqueue(data []byte) error {
+	select {
+	case q.jobs <- data:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting jobs and waits until the queued ones are printed or given up on.
+func (q *Queue) Close() {
+	close(q.jobs)
+	<-q.done
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	for data := range q.jobs {
+		delay := q.backoff
+		for attempt := 1; ; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), printTimeout)
+			err := q.printer.Print(ctx, data)
+			cancel()
+			if err == nil {
+				break
+			}
+
+			if attempt >= q.maxAttempts {
+				log.Printf("printer %s: giving up on job after %d attempts: %s", q.name, attempt, err)
+				break
+			}
+
+			log.Printf("printer %s: attempt %d failed, retrying in %s: %s", q.name, attempt, delay, err)
+			time.Sleep(delay)
+			if delay *= 2; delay > maxBackoff {
+				delay = maxBackoff
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package printing
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Router sends print jobs to the printer of a station, food categories are mapped to stations.
+type Router struct {
+	queues         map[string]*Queue
+	categories     map[string]string
+	defaultStation string
+}
+
+func NewRouter(printers map[string]Printer, categories map[string]string, defaultStation string) *Router {
+	router := &Router{
+		queues:         map[string]*Queue{},
+		categories:     map[string]string{},
+		defaultStation: defaultStation,
+	}
+	for station, printer := range printers {
+		router.queues[station] = NewQueue(station, printer, 100, 8, 2*time.Second)
+	}
+	for category, station := range categories {
+		router.categories[strings.ToLower(category)] = station
+	}
+	return router
+}
+
+// NewRouterFromEnv configures the router from
+//
+//	PRINTERS="grill=tcp://10.0.0.21:9100,bar=10.0.0.22,cold=file:///tmp/cold.prn,receipt=10.0.0.20"
+//	PRINTER_CATEGORIES="burgers=grill,steaks=grill,drinks=bar,salads=cold"
+//	DEFAULT_PRINTER_STATION="grill"
+//
+// Without PRINTERS printing is disabled.
+func NewRouterFromEnv() *Router {
+	printers := map[string]Printer{}
+	for station, address := range parsePairs(os.Getenv("PRINTERS")) {
+		printer, err := ParsePrinter(address)
+		if err != nil {
+			log.Printf("printer %s ignored: %s", station, err)
+			continue
+		}
+		printers[station] = printer
+	}
+
+	defaultStation := os.Getenv("DEFAULT_PRINTER_STATION")
+	if defaultStation == "" {
+		defaultStation = "grill"
+	}
+
+	return NewRouter(printers, parsePairs(os.Getenv("PRINTER_CATEGORIES")), defaultStation)
+}
+
+// Enabled reports whether any printer is configured.
+func (r *Router) Enabled() bool {
+	return len(r.queues) > 0
+}
+
+// StationFor returns the station preparing the foods of a category.
+func (r *Router) StationFor(category string) string {
+	if station, ok := r.CategoryStation(category); ok {
+		return station
+	}
+	return r.defaultStation
+}
+
+// CategoryStation returns the station PRINTER_CATEGORIES maps the category to, if any.
+func (r *Router) CategoryStation(category string) (string, bool) {
+	station, ok := r.categories[strings.ToLower(category)]
+	return station, ok
+}
+
+func (r *Router) Print(station string, data []byte) error {
+	queue, ok := r.queues[station]
+	if !ok {
+		return fmt.Errorf("no printer configured for station %s", station)
+	}
+	return queue.Enqueue(data)
+}
+
+func parsePairs(value string) map[string]string {
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return pairs
+}
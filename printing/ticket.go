@@ -0,0 +1,66 @@
+package printing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const TicketWidth = 48
+
+type TicketItem struct {
+	Count     int
+	Name      string
+	Portion   string
+	Modifiers []string
+	Notes     []string
+}
+
+// KitchenTicket lists the items of one order that a single station has to prepare.
+type KitchenTicket struct {
+	Station     string
+	OrderId     string
+	TableNumber string
+	CreatedAt   time.Time
+	Items       []TicketItem
+	Notes       []string
+}
+
+func (t KitchenTicket) ESCPOS() []byte {
+	b := NewBuilder()
+
+	b.Align(AlignCenter).Size(2, 2).Bold(true).Line(strings.ToUpper(t.Station))
+	if t.TableNumber != "" {
+		b.Line("TABLE " + t.TableNumber)
+	}
+	b.Size(1, 1).Bold(false)
+	b.Line(t.CreatedAt.Format("2006-01-02 15:04"))
+	b.Line("Order " + t.OrderId)
+
+	b.Align(AlignLeft).Line(strings.Repeat("=", TicketWidth))
+	for _, note := range t.Notes {
+		b.Bold(true).Line("!! " + note).Bold(false)
+	}
+
+	for _, item := range t.Items {
+		name := item.Name
+		if item.Portion != "" {
+			name = fmt.Sprintf("%s (%s)", name, item.Portion)
+		}
+		b.Size(1, 2).Bold(true).Line(fmt.Sprintf("%d x %s", item.Count, name)).Size(1, 1).Bold(false)
+		for _, modifier := range item.Modifiers {
+			b.Line("   + " + modifier)
+		}
+		for _, note := range item.Notes {
+			b.Bold(true).Line("   !! " + note).Bold(false)
+		}
+	}
+
+	b.Line(strings.Repeat("=", TicketWidth))
+	return b.Feed(3).Cut().Bytes()
+}
+
+// Receipt wraps a receipt laid out as text into a printable stream.
+func Receipt(text []byte) []byte {
+	return NewBuilder().Raw(text).Feed(4).Cut().Bytes()
+}
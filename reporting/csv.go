@@ -0,0 +1,51 @@
+package reporting
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// WriteFoodSalesCSV writes the foods of a product mix, one row per food.
+func WriteFoodSalesCSV(w io.Writer, mix ProductMix) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"food_id", "name", "category", "units", "revenue", "average_price", "unit_cost", "cost", "margin", "unit_margin", "margin_rate", "mix_share", "quadrant"})
+	for _, food := range mix.Foods {
+		writer.Write([]string{
+			food.FoodId, food.Name, food.Category, strconv.Itoa(food.Units), money(food.Revenue), money(food.AveragePrice),
+			money(food.UnitCost), money(food.Cost), money(food.Margin), money(food.UnitMargin), money(food.MarginRate),
+			money(food.MixShare), food.Quadrant,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteCategorySalesCSV writes the categories of a product mix, one row per category.
+func WriteCategorySalesCSV(w io.Writer, mix ProductMix) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"category_id", "name", "units", "revenue", "cost", "margin", "margin_rate"})
+	for _, category := range mix.Categories {
+		writer.Write([]string{
+			category.CategoryId, category.Name, strconv.Itoa(category.Units), money(category.Revenue), money(category.Cost),
+			money(category.Margin), money(category.MarginRate),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteHeatmapCSV writes one row per hour of the week.
+func WriteHeatmapCSV(w io.Writer, heatmap []HeatmapCell) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"weekday", "hour", "orders", "units", "revenue"})
+	for _, cell := range heatmap {
+		writer.Write([]string{strconv.Itoa(cell.Weekday), strconv.Itoa(cell.Hour), strconv.Itoa(cell.Orders), strconv.Itoa(cell.Units), money(cell.Revenue)})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func money(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
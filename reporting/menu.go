@@ -0,0 +1,389 @@
+package reporting
+
+import (
+	"context"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sort"
+	"time"
+)
+
+var foodCollection = database.OpenCollection(database.Client, "food")
+var categoryCollection = database.OpenCollection(database.Client, "category")
+var recipeCollection = database.OpenCollection(database.Client, "recipe")
+
+// Menu engineering classes of a food, by popularity and contribution margin.
+const (
+	QuadrantStar      = "STAR"
+	QuadrantPlowhorse = "PLOWHORSE"
+	QuadrantPuzzle    = "PUZZLE"
+	QuadrantDog       = "DOG"
+)
+
+// ProductMixFilter narrows the analytics to the foods of a menu and to a category with its subcategories.
+type ProductMixFilter struct {
+	Start      time.Time
+	End        time.Time
+	MenuId     string
+	CategoryId string
+}
+
+// FoodSales are the sales of one food over a period. Revenue is counted before order discounts, foods sold in a
+// combo with their share of the combo price. Cost is the current recipe cost of a portion, so Margin is the
+// contribution of the food at today's ingredient prices.
+type FoodSales struct {
+	FoodId       string  `json:"food_id"`
+	Name         string  `json:"name"`
+	CategoryId   string  `json:"category_id"`
+	Category     string  `json:"category"`
+	Units        int     `json:"units"`
+	Revenue      float64 `json:"revenue"`
+	AveragePrice float64 `json:"average_price"`
+	UnitCost     float64 `json:"unit_cost"`
+	Cost         float64 `json:"cost"`
+	Margin       float64 `json:"margin"`
+	UnitMargin   float64 `json:"unit_margin"`
+	MarginRate   float64 `json:"margin_rate"`
+	MixShare     float64 `json:"mix_share"`
+	Quadrant     string  `json:"quadrant"`
+}
+
+type CategorySales struct {
+	CategoryId string  `json:"category_id"`
+	Name       string  `json:"name"`
+	Units      int     `json:"units"`
+	Revenue    float64 `json:"revenue"`
+	Cost       float64 `json:"cost"`
+	Margin     float64 `json:"margin"`
+	MarginRate float64 `json:"margin_rate"`
+}
+
+// ProductMix holds the food and category sales of a period. A food is popular when its share of the units sold
+// reaches 70% of an even share, and profitable when its unit margin reaches the average unit margin of all
+// units sold.
+type ProductMix struct {
+	PeriodStart         time.Time       `json:"period_start"`
+	PeriodEnd           time.Time       `json:"period_end"`
+	Units               int             `json:"units"`
+	Revenue             float64         `json:"revenue"`
+	Cost                float64         `json:"cost"`
+	Margin              float64         `json:"margin"`
+	PopularityThreshold float64         `json:"popularity_threshold"`
+	AverageUnitMargin   float64         `json:"average_unit_margin"`
+	Foods               []FoodSales     `json:"foods"`
+	Categories          []CategorySales `json:"categories"`
+}
+
+// HeatmapCell holds the sales of one hour of one weekday, 1 is Monday and 7 is Sunday.
+type HeatmapCell struct {
+	Weekday int     `json:"weekday"`
+	Hour    int     `json:"hour"`
+	Orders  int     `json:"orders"`
+	Units   int     `json:"units"`
+	Revenue float64 `json:"revenue"`
+}
+
+// ProductMixReport computes the sales, costs and menu engineering classes of the foods of the filter. Foods
+// that did not sell are listed with zero units.
+func ProductMixReport(ctx context.Context, filter ProductMixFilter) (ProductMix, error) {
+	mix := ProductMix{PeriodStart: filter.Start, PeriodEnd: filter.End, Foods: []FoodSales{}, Categories: []CategorySales{}}
+
+	foods, categories, err := filterFoods(ctx, filter)
+	if err != nil {
+		return mix, err
+	}
+	costs, err := FoodCosts(ctx)
+	if err != nil {
+		return mix, err
+	}
+
+	var sold []struct {
+		FoodId  string  `bson:"_id"`
+		Units   int     `bson:"units"`
+		Revenue float64 `bson:"revenue"`
+	}
+	pipeline := mongo.Pipeline{
+		{{"$match", soldItemsMatch(filter, foods)}},
+		{{"$group", bson.D{
+			{"_id", "$food_id"},
+			{"units", bson.D{{"$sum", itemUnits}}},
+			{"revenue", bson.D{{"$sum", itemRevenue}}},
+		}}},
+	}
+	if err := aggregate(ctx, orderItemCollection, pipeline, &sold); err != nil {
+		return mix, err
+	}
+
+	byFood := map[string]*FoodSales{}
+	for _, food := range foods {
+		sales := FoodSales{FoodId: food.FoodId, UnitCost: helpers.RoundMoney(costs[food.FoodId])}
+		if food.Name != nil {
+			sales.Name = *food.Name
+		}
+		if food.CategoryId != nil {
+			if category, ok := categories[*food.CategoryId]; ok {
+				sales.CategoryId = category.CategoryId
+				sales.Category = *category.Name
+			}
+		}
+		// unsold foods are judged by their menu price
+		if food.Price != nil {
+			sales.AveragePrice = *food.Price
+		}
+		mix.Foods = append(mix.Foods, sales)
+	}
+	for i := range mix.Foods {
+		byFood[mix.Foods[i].FoodId] = &mix.Foods[i]
+	}
+
+	for _, item := range sold {
+		sales, ok := byFood[item.FoodId]
+		if !ok {
+			continue
+		}
+		sales.Units = item.Units
+		sales.Revenue = helpers.RoundMoney(item.Revenue)
+		if item.Units > 0 {
+			sales.AveragePrice = helpers.RoundMoney(item.Revenue / float64(item.Units))
+		}
+	}
+
+	byCategory := map[string]*CategorySales{}
+	for i := range mix.Foods {
+		sales := &mix.Foods[i]
+		sales.Cost = helpers.RoundMoney(sales.UnitCost * float64(sales.Units))
+		sales.Margin = helpers.RoundMoney(sales.Revenue - sales.Cost)
+		sales.UnitMargin = helpers.RoundMoney(sales.AveragePrice - sales.UnitCost)
+		if sales.AveragePrice > 0 {
+			sales.MarginRate = helpers.RoundMoney(sales.UnitMargin / sales.AveragePrice * 100)
+		}
+
+		mix.Units += sales.Units
+		mix.Revenue += sales.Revenue
+		mix.Cost += sales.Cost
+
+		category := byCategory[sales.CategoryId]
+		if category == nil {
+			category = &CategorySales{CategoryId: sales.CategoryId, Name: sales.Category}
+			byCategory[sales.CategoryId] = category
+		}
+		category.Units += sales.Units
+		category.Revenue += sales.Revenue
+		category.Cost += sales.Cost
+	}
+	mix.Revenue = helpers.RoundMoney(mix.Revenue)
+	mix.Cost = helpers.RoundMoney(mix.Cost)
+	mix.Margin = helpers.RoundMoney(mix.Revenue - mix.Cost)
+
+	classifyFoods(&mix)
+
+	for _, category := range byCategory {
+		category.Revenue = helpers.RoundMoney(category.Revenue)
+		category.Cost = helpers.RoundMoney(category.Cost)
+		category.Margin = helpers.RoundMoney(category.Revenue - category.Cost)
+		if category.Revenue > 0 {
+			category.MarginRate = helpers.RoundMoney(category.Margin / category.Revenue * 100)
+		}
+		mix.Categories = append(mix.Categories, *category)
+	}
+
+	sort.SliceStable(mix.Foods, func(i, j int) bool {
+		if mix.Foods[i].Revenue != mix.Foods[j].Revenue {
+			return mix.Foods[i].Revenue > mix.Foods[j].Revenue
+		}
+		return mix.Foods[i].Name < mix.Foods[j].Name
+	})
+	sort.SliceStable(mix.Categories, func(i, j int) bool {
+		if mix.Categories[i].Revenue != mix.Categories[j].Revenue {
+			return mix.Categories[i].Revenue > mix.Categories[j].Revenue
+		}
+		return mix.Categories[i].Name < mix.Categories[j].Name
+	})
+
+	return mix, nil
+}
+
+// classifyFoods sorts the foods into the menu engineering quadrants.
+func classifyFoods(mix *ProductMix) {
+	if len(mix.Foods) == 0 {
+		return
+	}
+	mix.PopularityThreshold = helpers.RoundMoney(100 / float64(len(mix.Foods)) * 0.7)
+	if mix.Units > 0 {
+		mix.AverageUnitMargin = helpers.RoundMoney(mix.Margin / float64(mix.Units))
+	}
+
+	for i := range mix.Foods {
+		sales := &mix.Foods[i]
+		if mix.Units > 0 {
+			sales.MixShare = helpers.RoundMoney(float64(sales.Units) / float64(mix.Units) * 100)
+		}
+
+		popular := mix.Units > 0 && sales.MixShare >= mix.PopularityThreshold
+		profitable := sales.UnitMargin >= mix.AverageUnitMargin
+		switch {
+		case popular && profitable:
+			sales.Quadrant = QuadrantStar
+		case popular:
+			sales.Quadrant = QuadrantPlowhorse
+		case profitable:
+			sales.Quadrant = QuadrantPuzzle
+		default:
+			sales.Quadrant = QuadrantDog
+		}
+	}
+}
+
+// SalesHeatmap sums the sales of the filtered foods by weekday and hour in location. Every hour of the week is
+// listed, Monday 0:00 first.
+func SalesHeatmap(ctx context.Context, filter ProductMixFilter, location *time.Location) ([]HeatmapCell, error) {
+	foods, _, err := filterFoods(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	timezone := mongoTimezone(location, filter.Start)
+	var cells []struct {
+		Id struct {
+			Weekday int `bson:"weekday"`
+			Hour    int `bson:"hour"`
+		} `bson:"_id"`
+		Orders  []string `bson:"orders"`
+		Units   int      `bson:"units"`
+		Revenue float64  `bson:"revenue"`
+	}
+	pipeline := mongo.Pipeline{
+		{{"$match", soldItemsMatch(filter, foods)}},
+		{{"$group", bson.D{
+			{"_id", bson.D{
+				{"weekday", bson.D{{"$isoDayOfWeek", bson.D{{"date", "$created_at"}, {"timezone", timezone}}}}},
+				{"hour", bson.D{{"$hour", bson.D{{"date", "$created_at"}, {"timezone", timezone}}}}},
+			}},
+			{"orders", bson.D{{"$addToSet", "$order_id"}}},
+			{"units", bson.D{{"$sum", itemUnits}}},
+			{"revenue", bson.D{{"$sum", itemRevenue}}},
+		}}},
+	}
+	if err := aggregate(ctx, orderItemCollection, pipeline, &cells); err != nil {
+		return nil, err
+	}
+
+	heatmap := make([]HeatmapCell, 0, 7*24)
+	for weekday := 1; weekday <= 7; weekday++ {
+		for hour := 0; hour < 24; hour++ {
+			heatmap = append(heatmap, HeatmapCell{Weekday: weekday, Hour: hour})
+		}
+	}
+	for _, cell := range cells {
+		if cell.Id.Weekday < 1 || cell.Id.Weekday > 7 || cell.Id.Hour < 0 || cell.Id.Hour > 23 {
+			continue
+		}
+		heatmap[(cell.Id.Weekday-1)*24+cell.Id.Hour] = HeatmapCell{
+			Weekday: cell.Id.Weekday,
+			Hour:    cell.Id.Hour,
+			Orders:  len(cell.Orders),
+			Units:   cell.Units,
+			Revenue: helpers.RoundMoney(cell.Revenue),
+		}
+	}
+	return heatmap, nil
+}
+
+// FoodCosts computes the cost of one portion of every food with a recipe from the current ingredient costs.
+func FoodCosts(ctx context.Context) (map[string]float64, error) {
+	var costs []struct {
+		FoodId string  `bson:"_id"`
+		Cost   float64 `bson:"cost"`
+	}
+	pipeline := mongo.Pipeline{
+		{{"$unwind", "$ingredients"}},
+		{{"$lookup", bson.D{{"from", "ingredient"}, {"localField", "ingredients.ingredient_id"}, {"foreignField", "ingredient_id"}, {"as", "ingredient"}}}},
+		{{"$unwind", "$ingredient"}},
+		{{"$group", bson.D{
+			{"_id", "$food_id"},
+			{"cost", bson.D{{"$sum", bson.D{{"$multiply", bson.A{"$ingredients.quantity", "$ingredient.cost_per_unit"}}}}}},
+		}}},
+	}
+	if err := aggregate(ctx, recipeCollection, pipeline, &costs); err != nil {
+		return nil, err
+	}
+
+	byFood := make(map[string]float64, len(costs))
+	for _, cost := range costs {
+		byFood[cost.FoodId] = cost.Cost
+	}
+	return byFood, nil
+}
+
+// itemUnits and itemRevenue sum an order item, a food of a combo sells at its share of the combo price.
+var itemUnits = bson.D{{"$ifNull", bson.A{"$count", 1}}}
+var itemRevenue = bson.D{{"$multiply", bson.A{
+	bson.D{{"$cond", bson.A{
+		bson.D{{"$gt", bson.A{"$parent_item_id", nil}}},
+		bson.D{{"$ifNull", bson.A{"$allocated_price", 0}}},
+		bson.D{{"$ifNull", bson.A{"$unit_price", 0}}},
+	}}},
+	itemUnits,
+}}}
+
+// soldItemsMatch selects the food items sold in the period that were not voided. Combos are counted by the
+// foods they were made of.
+func soldItemsMatch(filter ProductMixFilter, foods []models.Food) bson.D {
+	match := bson.D{
+		{"created_at", bson.D{{"$gte", filter.Start}, {"$lt", filter.End}}},
+		{"status", bson.D{{"$ne", models.OrderItemStatusVoid}}},
+		{"food_id", bson.D{{"$ne", nil}}},
+	}
+	if filter.MenuId != "" || filter.CategoryId != "" {
+		foodIds := make([]string, 0, len(foods))
+		for _, food := range foods {
+			foodIds = append(foodIds, food.FoodId)
+		}
+		match = append(match, bson.E{"food_id", bson.D{{"$in", foodIds}}})
+	}
+	return match
+}
+
+// filterFoods loads the foods of the filter and all categories by id.
+func filterFoods(ctx context.Context, filter ProductMixFilter) ([]models.Food, map[string]models.Category, error) {
+	var allCategories []models.Category
+	var foods []models.Food
+
+	cursor, err := categoryCollection.Find(ctx, bson.M{})
+	if err == nil {
+		err = cursor.All(ctx, &allCategories)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	categories := make(map[string]models.Category, len(allCategories))
+	for _, category := range allCategories {
+		categories[category.CategoryId] = category
+	}
+
+	query := bson.M{}
+	if filter.MenuId != "" {
+		query["menu_id"] = filter.MenuId
+	}
+	if filter.CategoryId != "" {
+		categoryIds := []string{}
+		for id := range categories {
+			for _, ancestor := range helpers.CategoryPath(categories, id) {
+				if ancestor.CategoryId == filter.CategoryId {
+					categoryIds = append(categoryIds, id)
+					break
+				}
+			}
+		}
+		query["category_id"] = bson.M{"$in": categoryIds}
+	}
+
+	cursor, err = foodCollection.Find(ctx, query)
+	if err == nil {
+		err = cursor.All(ctx, &foods)
+	}
+	return foods, categories, err
+}
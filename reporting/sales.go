@@ -0,0 +1,390 @@
+package reporting
+
+import (
+	"context"
+	"github.com/menyasosali/restaurant-manage-backend-go/database"
+	"github.com/menyasosali/restaurant-manage-backend-go/helpers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"time"
+)
+
+var invoiceCollection = database.OpenCollection(database.Client, "invoice")
+var paymentCollection = database.OpenCollection(database.Client, "payment")
+var refundCollection = database.OpenCollection(database.Client, "refund")
+var orderItemCollection = database.OpenCollection(database.Client, "orderItem")
+var reportCollection = database.OpenCollection(database.Client, "sales_report")
+
+// salesTotals is a group of the sales pipeline.
+type salesTotals struct {
+	Id         string  `bson:"_id"`
+	GrossSales float64 `bson:"gross_sales"`
+	Discounts  float64 `bson:"discounts"`
+	Taxes      float64 `bson:"taxes"`
+	Total      float64 `bson:"total"`
+	Invoices   int     `bson:"invoices"`
+	Covers     int     `bson:"covers"`
+}
+
+// Sales computes the report of the period from start up to end.
+func Sales(ctx context.Context, reportType string, start time.Time, end time.Time) (models.SalesReport, error) {
+	report := models.SalesReport{ReportType: reportType, PeriodStart: start, PeriodEnd: end, Payments: []models.PaymentTotal{}}
+
+	var sales []salesTotals
+	if err := aggregate(ctx, invoiceCollection, salesPipeline(start, end, nil), &sales); err != nil {
+		return report, err
+	}
+	if len(sales) > 0 {
+		report.GrossSales = helpers.RoundMoney(sales[0].GrossSales)
+		report.Discounts = helpers.RoundMoney(sales[0].Discounts)
+		report.Taxes = helpers.RoundMoney(sales[0].Taxes)
+		report.Total = helpers.RoundMoney(sales[0].Total)
+		report.InvoiceCount = sales[0].Invoices
+		report.Covers = sales[0].Covers
+	}
+	report.NetSales = helpers.RoundMoney(report.GrossSales - report.Discounts)
+	if report.InvoiceCount > 0 {
+		report.AverageCheck = helpers.RoundMoney(report.NetSales / float64(report.InvoiceCount))
+	}
+	if report.Covers > 0 {
+		report.AveragePerCover = helpers.RoundMoney(report.NetSales / float64(report.Covers))
+	}
+
+	payments, err := paymentTotals(ctx, start, end)
+	if err != nil {
+		return report, err
+	}
+	for _, payment := range payments {
+		report.Payments = append(report.Payments, payment.PaymentTotal)
+		report.Tips = helpers.RoundMoney(report.Tips + payment.Tips)
+		report.Refunds.Count += payment.refundCount
+		report.Refunds.Amount = helpers.RoundMoney(report.Refunds.Amount + payment.Refunded)
+	}
+
+	// combo foods are voided with their combo and have no price of their own
+	itemVoids := mongo.Pipeline{
+		{{"$match", bson.D{
+			{"status", models.OrderItemStatusVoid},
+			{"voided_at", bson.D{{"$gte", start}, {"$lt", end}}},
+			{"parent_item_id", nil},
+		}}},
+		{{"$group", bson.D{
+			{"_id", nil},
+			{"count", bson.D{{"$sum", 1}}},
+			{"amount", bson.D{{"$sum", bson.D{{"$multiply", bson.A{
+				bson.D{{"$ifNull", bson.A{"$unit_price", 0}}},
+				bson.D{{"$ifNull", bson.A{"$count", 1}}},
+			}}}}}},
+		}}},
+	}
+	if report.ItemVoids, err = countTotal(ctx, orderItemCollection, itemVoids); err != nil {
+		return report, err
+	}
+
+	invoiceVoids := mongo.Pipeline{
+		{{"$match", bson.D{
+			{"payment_status", models.PaymentStatusVoid},
+			{"voided_at", bson.D{{"$gte", start}, {"$lt", end}}},
+		}}},
+		{{"$group", bson.D{
+			{"_id", nil},
+			{"count", bson.D{{"$sum", 1}}},
+			{"amount", bson.D{{"$sum", "$total"}}},
+		}}},
+	}
+	if report.InvoiceVoids, err = countTotal(ctx, invoiceCollection, invoiceVoids); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// Summary computes the report of the days from first to last, both in location, with the sales of each day.
+func Summary(ctx context.Context, first time.Time, last time.Time, location *time.Location) (models.SalesReport, error) {
+	start := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, location)
+	end := time.Date(last.Year(), last.Month(), last.Day()+1, 0, 0, 0, 0, location)
+
+	report, err := Sales(ctx, models.ReportTypeSummary, start, end)
+	if err != nil {
+		return report, err
+	}
+
+	timezone := mongoTimezone(location, start)
+	day := bson.D{{"$dateToString", bson.D{{"format", "%Y-%m-%d"}, {"date", "$created_at"}, {"timezone", timezone}}}}
+
+	var sales []salesTotals
+	if err := aggregate(ctx, invoiceCollection, salesPipeline(start, end, day), &sales); err != nil {
+		return report, err
+	}
+
+	var tips []struct {
+		Id   string  `bson:"_id"`
+		Tips float64 `bson:"tips"`
+	}
+	tipsPipeline := mongo.Pipeline{
+		{{"$match", bson.D{{"created_at", bson.D{{"$gte", start}, {"$lt", end}}}}}},
+		{{"$group", bson.D{{"_id", day}, {"tips", bson.D{{"$sum", "$tip"}}}}}},
+	}
+	if err := aggregate(ctx, paymentCollection, tipsPipeline, &tips); err != nil {
+		return report, err
+	}
+
+	// every day of the range is listed, also those without sales
+	days := map[string]*models.DailySales{}
+	for date := start; date.Before(end); date = date.AddDate(0, 0, 1) {
+		report.Days = append(report.Days, models.DailySales{Date: date.Format("2006-01-02")})
+	}
+	for i := range report.Days {
+		days[report.Days[i].Date] = &report.Days[i]
+	}
+
+	for _, totals := range sales {
+		if daily, ok := days[totals.Id]; ok {
+			daily.GrossSales = helpers.RoundMoney(totals.GrossSales)
+			daily.Discounts = helpers.RoundMoney(totals.Discounts)
+			daily.NetSales = helpers.RoundMoney(totals.GrossSales - totals.Discounts)
+			daily.Taxes = helpers.RoundMoney(totals.Taxes)
+			daily.Total = helpers.RoundMoney(totals.Total)
+			daily.InvoiceCount = totals.Invoices
+			daily.Covers = totals.Covers
+		}
+	}
+	for _, total := range tips {
+		if daily, ok := days[total.Id]; ok {
+			daily.Tips = helpers.RoundMoney(total.Tips)
+		}
+	}
+
+	return report, nil
+}
+
+// LastZReport returns the latest Z report, nil when the day has never been closed.
+func LastZReport(ctx context.Context) (*models.SalesReport, error) {
+	var report models.SalesReport
+
+	err := reportCollection.FindOne(
+		ctx,
+		bson.M{"report_type": models.ReportTypeZ},
+		options.FindOne().SetSort(bson.D{{"number", -1}}),
+	).Decode(&report)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ShiftStart is where the running period begins: the end of the last Z report or, before the first one, the
+// start of the day of at.
+func ShiftStart(ctx context.Context, at time.Time, location *time.Location) (time.Time, error) {
+	last, err := LastZReport(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if last != nil {
+		return last.PeriodEnd, nil
+	}
+	local := at.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location), nil
+}
+
+// CloseDay stores the Z report of the running period. The report number is allocated in the same transaction
+// as the insert, which also keeps two concurrent closes from reporting the same period twice.
+func CloseDay(ctx context.Context, at time.Time, location *time.Location, uid string) (models.SalesReport, error) {
+	var report models.SalesReport
+
+	session, err := database.Client.StartSession()
+	if err != nil {
+		return report, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		number, err := helpers.NextSequence(sessCtx, "z_report:"+helpers.RESTAURANT_ID)
+		if err != nil {
+			return nil, err
+		}
+
+		start, err := ShiftStart(sessCtx, at, location)
+		if err != nil {
+			return nil, err
+		}
+
+		report, err = Sales(sessCtx, models.ReportTypeZ, start, at)
+		if err != nil {
+			return nil, err
+		}
+		report.Number = number
+		report.GeneratedBy = uid
+		report.GeneratedAt = at
+		report.ID = primitive.NewObjectID()
+		report.ReportId = report.ID.Hex()
+
+		return reportCollection.InsertOne(sessCtx, report)
+	})
+	return report, err
+}
+
+// ZReports lists the stored Z reports, the latest first.
+func ZReports(ctx context.Context) ([]models.SalesReport, error) {
+	reports := []models.SalesReport{}
+
+	cursor, err := reportCollection.Find(ctx, bson.M{"report_type": models.ReportTypeZ}, options.Find().SetSort(bson.D{{"number", -1}}))
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &reports)
+	return reports, err
+}
+
+// ZReportsClosed lists the Z reports closed from start up to end, the earliest first.
+func ZReportsClosed(ctx context.Context, start time.Time, end time.Time) ([]models.SalesReport, error) {
+	reports := []models.SalesReport{}
+
+	cursor, err := reportCollection.Find(
+		ctx,
+		bson.M{"report_type": models.ReportTypeZ, "period_end": bson.M{"$gte": start, "$lt": end}},
+		options.Find().SetSort(bson.D{{"number", 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &reports)
+	return reports, err
+}
+
+// ZReport finds a stored Z report by id.
+func ZReport(ctx context.Context, reportId string) (models.SalesReport, error) {
+	var report models.SalesReport
+	err := reportCollection.FindOne(ctx, bson.M{"report_id": reportId, "report_type": models.ReportTypeZ}).Decode(&report)
+	return report, err
+}
+
+// salesPipeline sums the invoices created in the period that were not voided, grouped by groupId. The covers
+// are the guests of the tables the invoiced orders were served at.
+func salesPipeline(start time.Time, end time.Time, groupId interface{}) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{"$match", bson.D{
+			{"created_at", bson.D{{"$gte", start}, {"$lt", end}}},
+			{"payment_status", bson.D{{"$ne", models.PaymentStatusVoid}}},
+		}}},
+		{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}},
+		{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}},
+		{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}},
+		{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}},
+		{{"$group", bson.D{
+			{"_id", groupId},
+			{"gross_sales", bson.D{{"$sum", "$subtotal"}}},
+			{"discounts", bson.D{{"$sum", "$discount_total"}}},
+			{"taxes", bson.D{{"$sum", "$tax"}}},
+			{"total", bson.D{{"$sum", "$total"}}},
+			{"invoices", bson.D{{"$sum", 1}}},
+			{"covers", bson.D{{"$sum", bson.D{{"$ifNull", bson.A{"$table.number_of_guests", 0}}}}}},
+		}}},
+	}
+}
+
+type methodTotal struct {
+	models.PaymentTotal
+	refundCount int
+}
+
+// paymentTotals sums the payments and tips taken and the refunds given in the period by payment method.
+func paymentTotals(ctx context.Context, start time.Time, end time.Time) ([]methodTotal, error) {
+	var payments []struct {
+		Method string  `bson:"_id"`
+		Count  int     `bson:"count"`
+		Amount float64 `bson:"amount"`
+		Tips   float64 `bson:"tips"`
+	}
+	paymentsPipeline := mongo.Pipeline{
+		{{"$match", bson.D{{"created_at", bson.D{{"$gte", start}, {"$lt", end}}}}}},
+		{{"$group", bson.D{
+			{"_id", "$payment_method"},
+			{"count", bson.D{{"$sum", 1}}},
+			{"amount", bson.D{{"$sum", "$amount"}}},
+			{"tips", bson.D{{"$sum", "$tip"}}},
+		}}},
+	}
+	if err := aggregate(ctx, paymentCollection, paymentsPipeline, &payments); err != nil {
+		return nil, err
+	}
+
+	var refunds []struct {
+		Method string  `bson:"_id"`
+		Count  int     `bson:"count"`
+		Amount float64 `bson:"amount"`
+	}
+	refundsPipeline := mongo.Pipeline{
+		{{"$match", bson.D{{"created_at", bson.D{{"$gte", start}, {"$lt", end}}}}}},
+		{{"$lookup", bson.D{{"from", "payment"}, {"localField", "payment_id"}, {"foreignField", "payment_id"}, {"as", "payment"}}}},
+		{{"$unwind", "$payment"}},
+		{{"$group", bson.D{
+			{"_id", "$payment.payment_method"},
+			{"count", bson.D{{"$sum", 1}}},
+			{"amount", bson.D{{"$sum", "$amount"}}},
+		}}},
+	}
+	if err := aggregate(ctx, refundCollection, refundsPipeline, &refunds); err != nil {
+		return nil, err
+	}
+
+	byMethod := map[string]*methodTotal{}
+	method := func(name string) *methodTotal {
+		if byMethod[name] == nil {
+			byMethod[name] = &methodTotal{PaymentTotal: models.PaymentTotal{PaymentMethod: name}}
+		}
+		return byMethod[name]
+	}
+	for _, payment := range payments {
+		total := method(payment.Method)
+		total.Count = payment.Count
+		total.Amount = helpers.RoundMoney(payment.Amount)
+		total.Tips = helpers.RoundMoney(payment.Tips)
+	}
+	for _, refund := range refunds {
+		total := method(refund.Method)
+		total.refundCount = refund.Count
+		total.Refunded = helpers.RoundMoney(refund.Amount)
+	}
+
+	totals := make([]methodTotal, 0, len(byMethod))
+	for _, total := range byMethod {
+		total.Net = helpers.RoundMoney(total.Amount + total.Tips - total.Refunded)
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].PaymentMethod < totals[j].PaymentMethod })
+	return totals, nil
+}
+
+func countTotal(ctx context.Context, collection *mongo.Collection, pipeline mongo.Pipeline) (models.CountTotal, error) {
+	var totals []models.CountTotal
+	if err := aggregate(ctx, collection, pipeline, &totals); err != nil || len(totals) == 0 {
+		return models.CountTotal{}, err
+	}
+	totals[0].Amount = helpers.RoundMoney(totals[0].Amount)
+	return totals[0], nil
+}
+
+func aggregate(ctx context.Context, collection *mongo.Collection, pipeline mongo.Pipeline, results interface{}) error {
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+// mongoTimezone names the location for date operators, the server timezone has no name Mongo knows and is
+// passed as its offset at the given moment.
+func mongoTimezone(location *time.Location, at time.Time) string {
+	if location == time.Local || location.String() == "Local" {
+		return at.In(location).Format("-07:00")
+	}
+	return location.String()
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+)
+
+func AnalyticsRoutes(incomingRoutes *mux.Router) {
+	incomingRoutes.Handle("/analytics/product-mix", middleware.RequireRole(controller.GetProductMix, models.RoleManager, models.RoleAdmin)).Methods("GET")
+	incomingRoutes.Handle("/analytics/heatmap", middleware.RequireRole(controller.GetSalesHeatmap, models.RoleManager, models.RoleAdmin)).Methods("GET")
+}
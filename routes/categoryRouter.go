@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+)
+
+func CategoryRoutes(incomingRoutes *mux.Router) {
+	incomingRoutes.HandleFunc("/categories", controller.GetCategories).Methods("GET")
+	incomingRoutes.HandleFunc("/categories/{category_id}", controller.GetCategory).Methods("GET")
+	incomingRoutes.Handle("/categories", middleware.RequireRole(controller.CreateCategory, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.Handle("/categories/{category_id}", middleware.RequireRole(controller.UpdateCategory, models.RoleManager, models.RoleAdmin)).Methods("PATCH")
+	incomingRoutes.Handle("/categories/{category_id}", middleware.RequireRole(controller.DeleteCategory, models.RoleManager, models.RoleAdmin)).Methods("DELETE")
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+)
+
+func ComboRoutes(incomingRoutes *mux.Router) {
+	incomingRoutes.HandleFunc("/combos", controller.GetCombos).Methods("GET")
+	incomingRoutes.HandleFunc("/combos/{combo_id}", controller.GetCombo).Methods("GET")
+	incomingRoutes.Handle("/combos", middleware.RequireRole(controller.CreateCombo, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.Handle("/combos/{combo_id}", middleware.RequireRole(controller.UpdateCombo, models.RoleManager, models.RoleAdmin)).Methods("PATCH")
+}
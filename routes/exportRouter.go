@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+)
+
+func ExportRoutes(incomingRoutes *mux.Router) {
+	incomingRoutes.Handle("/exports/{dataset}", middleware.RequireRole(controller.ExportData, models.RoleManager, models.RoleAdmin)).Methods("GET")
+}
@@ -3,11 +3,21 @@ package routes
 import (
 	"github.com/gorilla/mux"
 	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
 )
 
 func FoodRoutes(incomingRoutes *mux.Router) {
 	incomingRoutes.HandleFunc("/foods", controller.GetFoods).Methods("GET")
-	incomingRoutes.HandleFunc("/foods/:food_id", controller.GetFood).Methods("GET")
+	incomingRoutes.HandleFunc("/foods/availability", controller.GetFoodAvailability).Methods("GET")
+	incomingRoutes.HandleFunc("/foods/availability/stream", controller.StreamFoodAvailability).Methods("GET")
+	incomingRoutes.HandleFunc("/foods/{food_id}", controller.GetFood).Methods("GET")
 	incomingRoutes.HandleFunc("/foods", controller.CreateFood).Methods("POST")
-	incomingRoutes.HandleFunc("/foods/:food_id", controller.UpdateFood).Methods("UPDATE")
+	incomingRoutes.HandleFunc("/foods/{food_id}", controller.UpdateFood).Methods("UPDATE")
+	incomingRoutes.HandleFunc("/foods/{food_id}/86", controller.EightySixFood).Methods("POST")
+	incomingRoutes.HandleFunc("/foods/{food_id}/86", controller.RestoreFood).Methods("DELETE")
+	incomingRoutes.HandleFunc("/foods/{food_id}/portions", controller.SetRemainingPortions).Methods("PUT")
+	incomingRoutes.Handle("/foods/{food_id}/image", middleware.RequireRole(controller.UploadFoodImage, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.HandleFunc("/foods/{food_id}/price-history", controller.GetFoodPriceHistory).Methods("GET")
+	incomingRoutes.Handle("/foods/{food_id}/prices", middleware.RequireRole(controller.CreateFoodPrice, models.RoleManager, models.RoleAdmin)).Methods("POST")
 }
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+)
+
+func InventoryRoutes(incomingRoutes *mux.Router) {
+	incomingRoutes.HandleFunc("/ingredients", controller.GetIngredients).Methods("GET")
+	incomingRoutes.HandleFunc("/ingredients/{ingredient_id}", controller.GetIngredient).Methods("GET")
+	incomingRoutes.Handle("/ingredients", middleware.RequireRole(controller.CreateIngredient, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.Handle("/ingredients/{ingredient_id}", middleware.RequireRole(controller.UpdateIngredient, models.RoleManager, models.RoleAdmin)).Methods("PATCH")
+	incomingRoutes.HandleFunc("/ingredients/{ingredient_id}/movements", controller.GetStockMovements).Methods("GET")
+	incomingRoutes.HandleFunc("/ingredients/{ingredient_id}/adjustments", controller.CreateStockAdjustment).Methods("POST")
+	incomingRoutes.HandleFunc("/foods/{food_id}/recipe", controller.GetRecipe).Methods("GET")
+	incomingRoutes.Handle("/foods/{food_id}/recipe", middleware.RequireRole(controller.UpdateRecipe, models.RoleManager, models.RoleAdmin)).Methods("PUT")
+	incomingRoutes.HandleFunc("/inventory/alerts", controller.GetInventoryAlerts).Methods("GET")
+}
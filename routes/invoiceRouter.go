@@ -3,11 +3,20 @@ package routes
 import (
 	"github.com/gorilla/mux"
 	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
 )
 
 func InvoiceRoutes(incomingRoutes *mux.Router) {
 	incomingRoutes.HandleFunc("/invoices", controller.GetInvoices).Methods("GET")
-	incomingRoutes.HandleFunc("/invoices/:invoice_id", controller.GetInvoice).Methods("GET")
+	incomingRoutes.HandleFunc("/invoices/{invoice_id}", controller.GetInvoice).Methods("GET")
 	incomingRoutes.HandleFunc("/invoices", controller.CreateInvoice).Methods("POST")
-	incomingRoutes.HandleFunc("/invoices/:invoice_id", controller.UpdateInvoice).Methods("UPDATE")
+	incomingRoutes.HandleFunc("/invoices/{invoice_id}", controller.UpdateInvoice).Methods("UPDATE")
+	incomingRoutes.HandleFunc("/invoices/{invoice_id}/payments", controller.GetInvoicePayments).Methods("GET")
+	incomingRoutes.HandleFunc("/invoices/{invoice_id}/payments", controller.CreatePayment).Methods("POST")
+	incomingRoutes.HandleFunc("/invoices/{invoice_id}/credit-notes", controller.GetInvoiceCreditNotes).Methods("GET")
+	incomingRoutes.HandleFunc("/invoices/{invoice_id}/history", controller.GetInvoiceHistory).Methods("GET")
+	incomingRoutes.HandleFunc("/invoices/{invoice_id}/print", controller.PrintInvoice).Methods("POST")
+	incomingRoutes.Handle("/invoices/{invoice_id}/refunds", middleware.RequireRole(controller.CreateRefund, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.Handle("/invoices/{invoice_id}/void", middleware.RequireRole(controller.VoidInvoice, models.RoleManager, models.RoleAdmin)).Methods("POST")
 }
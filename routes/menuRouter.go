@@ -3,11 +3,21 @@ package routes
 import (
 	"github.com/gorilla/mux"
 	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
 )
 
 func MenuRoutes(incomingRoutes *mux.Router) {
 	incomingRoutes.HandleFunc("/menus", controller.GetMenus).Methods("GET")
-	incomingRoutes.HandleFunc("/menus/:menu_id", controller.GetMenu).Methods("GET")
+	incomingRoutes.HandleFunc("/menus/active", controller.GetActiveMenus).Methods("GET")
+	incomingRoutes.HandleFunc("/menus/{menu_id}", controller.GetMenu).Methods("GET")
+	incomingRoutes.HandleFunc("/menus/{menu_id}/foods", controller.GetMenuFoods).Methods("GET")
 	incomingRoutes.HandleFunc("/menus", controller.CreateMenu).Methods("POST")
-	incomingRoutes.HandleFunc("/menus/:menu_id", controller.UpdateMenu).Methods("UPDATE")
+	incomingRoutes.HandleFunc("/menus/{menu_id}", controller.UpdateMenu).Methods("UPDATE")
+	incomingRoutes.HandleFunc("/menus/{menu_id}/versions", controller.GetMenuVersions).Methods("GET")
+	incomingRoutes.HandleFunc("/menus/{menu_id}/versions/{menu_version_id}", controller.GetMenuVersion).Methods("GET")
+	incomingRoutes.Handle("/menus/{menu_id}/versions", middleware.RequireRole(controller.CreateMenuVersion, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.Handle("/menus/{menu_id}/versions/{menu_version_id}", middleware.RequireRole(controller.UpdateMenuVersion, models.RoleManager, models.RoleAdmin)).Methods("PATCH")
+	incomingRoutes.Handle("/menus/{menu_id}/versions/{menu_version_id}/publish", middleware.RequireRole(controller.PublishMenuVersion, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.Handle("/menus/{menu_id}/rollback", middleware.RequireRole(controller.RollbackMenu, models.RoleManager, models.RoleAdmin)).Methods("POST")
 }
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+)
+
+func NoteRoutes(incomingRoutes *mux.Router) {
+	targets := []struct {
+		path       string
+		idVar      string
+		targetType string
+	}{
+		{"/orders/{order_id}/notes", "order_id", models.NoteTargetOrder},
+		{"/orderItems/{order_item_id}/notes", "order_item_id", models.NoteTargetOrderItem},
+		{"/tables/{table_id}/notes", "table_id", models.NoteTargetTable},
+		{"/reservations/{reservation_id}/notes", "reservation_id", models.NoteTargetReservation},
+		{"/customers/{customer_id}/notes", "customer_id", models.NoteTargetCustomer},
+	}
+
+	for _, target := range targets {
+		incomingRoutes.HandleFunc(target.path, controller.GetTargetNotes(target.targetType, target.idVar)).Methods("GET")
+		incomingRoutes.HandleFunc(target.path, controller.CreateTargetNote(target.targetType, target.idVar)).Methods("POST")
+	}
+
+	incomingRoutes.HandleFunc("/notes/{note_id}", controller.UpdateNote).Methods("PATCH")
+	incomingRoutes.HandleFunc("/notes/{note_id}", controller.DeleteNote).Methods("DELETE")
+}
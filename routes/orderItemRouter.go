@@ -3,12 +3,15 @@ package routes
 import (
 	"github.com/gorilla/mux"
 	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
 )
 
 func OrderItemRoutes(incomingRoutes *mux.Router) {
 	incomingRoutes.HandleFunc("/orderItems", controller.GetOrderItems).Methods("GET")
-	incomingRoutes.HandleFunc("/orderItems/:orderItem_id", controller.GetOrderItem).Methods("GET")
-	incomingRoutes.HandleFunc("/orderItems-order/:order_id", controller.GetOrderItemsByOrder).Methods("GET")
+	incomingRoutes.HandleFunc("/orderItems/{order_item_id}", controller.GetOrderItem).Methods("GET")
+	incomingRoutes.HandleFunc("/orderItems-order/{order_id}", controller.GetOrderItemsByOrder).Methods("GET")
 	incomingRoutes.HandleFunc("/orderItems", controller.CreateOrderItem).Methods("POST")
-	incomingRoutes.HandleFunc("/orderItems/:orderItem_id", controller.UpdateOrderItem).Methods("UPDATE")
+	incomingRoutes.HandleFunc("/orderItems/{order_item_id}", controller.UpdateOrderItem).Methods("UPDATE")
+	incomingRoutes.Handle("/orderItems/{order_item_id}/void", middleware.RequireRole(controller.VoidOrderItem, models.RoleManager, models.RoleAdmin)).Methods("POST")
 }
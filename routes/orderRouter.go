@@ -7,7 +7,7 @@ import (
 
 func OrderRoutes(incomingRoutes *mux.Router) {
 	incomingRoutes.HandleFunc("/orders", controller.GetOrders).Methods("GET")
-	incomingRoutes.HandleFunc("/orders/:order_id", controller.GetOrder).Methods("GET")
+	incomingRoutes.HandleFunc("/orders/{order_id}", controller.GetOrder).Methods("GET")
 	incomingRoutes.HandleFunc("/orders", controller.CreateOrder).Methods("POST")
-	incomingRoutes.HandleFunc("/orders/:order_id", controller.UpdateOrder).Methods("UPDATE")
+	incomingRoutes.HandleFunc("/orders/{order_id}", controller.UpdateOrder).Methods("UPDATE")
 }
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+)
+
+func PromotionRoutes(incomingRoutes *mux.Router) {
+	incomingRoutes.HandleFunc("/promotions", controller.GetPromotions).Methods("GET")
+	incomingRoutes.HandleFunc("/promotions/{promotion_id}", controller.GetPromotion).Methods("GET")
+	incomingRoutes.Handle("/promotions", middleware.RequireRole(controller.CreatePromotion, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.Handle("/promotions/{promotion_id}", middleware.RequireRole(controller.UpdatePromotion, models.RoleManager, models.RoleAdmin)).Methods("PATCH")
+	incomingRoutes.HandleFunc("/orders/{order_id}/discounts", controller.GetOrderDiscounts).Methods("GET")
+	incomingRoutes.HandleFunc("/orders/{order_id}/promo-code", controller.ApplyPromoCode).Methods("POST")
+	incomingRoutes.Handle("/orders/{order_id}/discounts", middleware.RequireRole(controller.CreateManualDiscount, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.Handle("/discounts/{discount_id}", middleware.RequireRole(controller.DeleteDiscount, models.RoleManager, models.RoleAdmin)).Methods("DELETE")
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+)
+
+func ReportRoutes(incomingRoutes *mux.Router) {
+	incomingRoutes.Handle("/reports/x", middleware.RequireRole(controller.GetXReport, models.RoleManager, models.RoleAdmin)).Methods("GET")
+	incomingRoutes.Handle("/reports/z", middleware.RequireRole(controller.GetZReports, models.RoleManager, models.RoleAdmin)).Methods("GET")
+	incomingRoutes.Handle("/reports/z", middleware.RequireRole(controller.CloseZReport, models.RoleManager, models.RoleAdmin)).Methods("POST")
+	incomingRoutes.Handle("/reports/z/{report_id}", middleware.RequireRole(controller.GetZReport, models.RoleManager, models.RoleAdmin)).Methods("GET")
+	incomingRoutes.Handle("/reports/summary", middleware.RequireRole(controller.GetSalesSummary, models.RoleManager, models.RoleAdmin)).Methods("GET")
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+)
+
+func SearchRoutes(incomingRoutes *mux.Router) {
+	incomingRoutes.HandleFunc("/search", controller.Search).Methods("GET")
+}
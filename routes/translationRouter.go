@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
+)
+
+func TranslationRoutes(incomingRoutes *mux.Router) {
+	incomingRoutes.HandleFunc("/translations/export", controller.ExportTranslations).Methods("GET")
+	incomingRoutes.Handle("/translations/import", middleware.RequireRole(controller.ImportTranslations, models.RoleManager, models.RoleAdmin)).Methods("POST")
+}
@@ -3,11 +3,15 @@ package routes
 import (
 	"github.com/gorilla/mux"
 	controller "github.com/menyasosali/restaurant-manage-backend-go/controllers"
+	"github.com/menyasosali/restaurant-manage-backend-go/middleware"
+	"github.com/menyasosali/restaurant-manage-backend-go/models"
 )
 
 func UserRoutes(incomingRoutes *mux.Router) {
 	incomingRoutes.HandleFunc("/users", controller.GetUsers).Methods("GET")
-	incomingRoutes.HandleFunc("/users/:user_id", controller.GetUser).Methods("GET")
+	incomingRoutes.HandleFunc("/users/{user_id}", controller.GetUser).Methods("GET")
 	incomingRoutes.HandleFunc("/users/signup", controller.SingUp).Methods("POST")
 	incomingRoutes.HandleFunc("/users/login", controller.Login).Methods("POST")
+	incomingRoutes.HandleFunc("/users/{user_id}/avatar", controller.UploadUserAvatar).Methods("POST")
+	incomingRoutes.Handle("/users/{user_id}/role", middleware.RequireRole(controller.UpdateUserRole, models.RoleAdmin)).Methods("PATCH")
 }
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var ErrNotFound = errors.New("blob was not found")
+
+// BlobStore keeps uploaded files. Keys are slash separated paths like "foods/<id>/thumb.webp".
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL is the address clients load the blob from.
+	URL(key string) string
+}
+
+// NewBlobStoreFromEnv creates the store selected by STORAGE_DRIVER, "local" (the default) or "s3".
+//
+// The local store writes below STORAGE_LOCAL_DIR (default "uploads") and is served under STORAGE_PUBLIC_URL
+// (default "/uploads"). The S3 store needs S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY; S3_ENDPOINT
+// and S3_REGION default to AWS us-east-1, set S3_ENDPOINT to use a compatible server such as MinIO.
+func NewBlobStoreFromEnv() (BlobStore, error) {
+	switch driver := envOrDefault("STORAGE_DRIVER", "local"); driver {
+	case "local":
+		return NewLocalStore(envOrDefault("STORAGE_LOCAL_DIR", "uploads"), envOrDefault("STORAGE_PUBLIC_URL", "/uploads")), nil
+	case "s3":
+		store := &S3Store{
+			Endpoint:        envOrDefault("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:          envOrDefault("S3_REGION", "us-east-1"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyId:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		}
+		if store.Bucket == "" || store.AccessKeyId == "" || store.SecretAccessKey == "" {
+			return nil, errors.New("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY have to be set for the s3 storage driver")
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}